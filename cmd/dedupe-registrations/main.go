@@ -0,0 +1,243 @@
+// Command dedupe-registrations merges the duplicate registrations some users
+// hold from before registrations were unique per user. For each such user it
+// keeps one registration, copies over the details only the duplicates have
+// and deletes the duplicates, returning their tickets and promo code uses.
+// Without -apply it only reports what it would do.
+//
+//	go run ./cmd/dedupe-registrations
+//	go run ./cmd/dedupe-registrations -apply
+//
+// Kept registrations keep their ID, so issued tickets and payment references
+// stay valid. Users with more than one paid registration are skipped and
+// must be refunded by hand.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/config"
+	"backend-ITC/internal/firebase"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
+
+	"github.com/joho/godotenv"
+)
+
+// actor identifies this command in the audit log
+const actor = "dedupe-registrations"
+
+func main() {
+	apply := flag.Bool("apply", false, "merge the duplicates instead of only reporting them")
+	configFile := flag.String("config", "", "path of a YAML config file, default $CONFIG_FILE")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: failed to load .env file: %v", err)
+	}
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx := context.Background()
+	fc, err := firebase.Initialize(ctx, cfg.FirebaseCredentialsFile)
+	if err != nil {
+		log.Fatalf("Failed to initialize Firebase: %v", err)
+	}
+	defer fc.Close()
+
+	repos := repository.NewFirestore(fc.Firestore)
+
+	byUser := make(map[string][]models.Registration)
+	err = repos.Registrations.Each(ctx, repository.RegistrationFilter{}, func(reg *models.Registration) error {
+		byUser[reg.UserID] = append(byUser[reg.UserID], *reg)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to list registrations: %v", err)
+	}
+
+	var uids []string
+	for uid, regs := range byUser {
+		if len(regs) > 1 {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Strings(uids)
+
+	merged, skipped, failed := 0, 0, 0
+	for _, uid := range uids {
+		keep, duplicates, err := plan(byUser[uid])
+		if err != nil {
+			log.Printf("Skipping %s: %v", uid, err)
+			skipped++
+			continue
+		}
+
+		log.Printf("%s: keeping %s, merging %s", uid, keep.ID, ids(duplicates))
+		if !*apply {
+			continue
+		}
+
+		if err := merge(ctx, repos, keep, duplicates); err != nil {
+			log.Printf("Failed to merge registrations of %s: %v", uid, err)
+			failed++
+			continue
+		}
+		merged++
+	}
+
+	if !*apply {
+		log.Printf("%d users have duplicate registrations, %d would be skipped; run with -apply to merge them", len(uids), skipped)
+		return
+	}
+	log.Printf("Merged the registrations of %d users, skipped %d, failed %d", merged, skipped, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// plan picks the registration to keep out of regs, the registrations of one
+// user, and returns it with the details of the others merged in, followed by
+// the registrations to delete. The kept registration is the checked-in or
+// paid one if there is one, otherwise the oldest.
+func plan(regs []models.Registration) (models.Registration, []models.Registration, error) {
+	paid := 0
+	for _, reg := range regs {
+		if reg.PaymentStatus == models.PaymentStatusCompleted {
+			paid++
+		}
+	}
+	if paid > 1 {
+		return models.Registration{}, nil, fmt.Errorf("%d registrations are paid", paid)
+	}
+
+	sort.SliceStable(regs, func(i, j int) bool {
+		a, b := regs[i], regs[j]
+		if a.IsCheckedIn() != b.IsCheckedIn() {
+			return a.IsCheckedIn()
+		}
+		if paidA, paidB := a.PaymentStatus == models.PaymentStatusCompleted, b.PaymentStatus == models.PaymentStatusCompleted; paidA != paidB {
+			return paidA
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	keep := regs[0]
+	duplicates := regs[1:]
+	for _, dup := range duplicates {
+		fillEmpty(&keep.Phone, dup.Phone)
+		fillEmpty(&keep.Organization, dup.Organization)
+		fillEmpty(&keep.JobTitle, dup.JobTitle)
+		fillEmpty(&keep.City, dup.City)
+		fillEmpty(&keep.DietaryReqs, dup.DietaryReqs)
+		fillEmpty(&keep.SpecialNeeds, dup.SpecialNeeds)
+		keep.SessionsOfInt = union(keep.SessionsOfInt, dup.SessionsOfInt)
+	}
+
+	return keep, duplicates, nil
+}
+
+// merge saves keep, then deletes the duplicates and returns their tickets
+// and promo code uses to inventory. Saving first makes an interrupted run
+// safe to repeat.
+func merge(ctx context.Context, repos *repository.Repositories, keep models.Registration, duplicates []models.Registration) error {
+	before, err := repos.Registrations.Get(ctx, keep.ID)
+	if err != nil {
+		return err
+	}
+
+	keep.UpdatedAt = time.Now()
+	if err := repos.Registrations.Update(ctx, &keep); err != nil {
+		return err
+	}
+	appendAudit(ctx, repos, "registration.update", keep.ID, before, &keep)
+
+	for i := range duplicates {
+		dup := &duplicates[i]
+		if dup.PaymentRef != "" && dup.PaymentStatus == models.PaymentStatusPending {
+			log.Printf("Warning: deleted registration %s has an open checkout %s", dup.ID, dup.PaymentRef)
+		}
+
+		if err := repos.Registrations.Delete(ctx, dup.ID); err != nil {
+			return err
+		}
+		appendAudit(ctx, repos, "registration.delete", dup.ID, dup, nil)
+
+		if err := repos.TicketTypes.Release(ctx, dup.TicketType); err != nil && !errors.Is(err, repository.ErrNotFound) {
+			log.Printf("Warning: failed to release ticket %s of %s: %v", dup.TicketType, dup.ID, err)
+		}
+		if dup.PromoCode != "" {
+			if err := repos.PromoCodes.Release(ctx, dup.PromoCode); err != nil && !errors.Is(err, repository.ErrNotFound) {
+				log.Printf("Warning: failed to release promo code %s of %s: %v", dup.PromoCode, dup.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// appendAudit records a change made by this command in the audit log
+func appendAudit(ctx context.Context, repos *repository.Repositories, action, id string, before, after *models.Registration) {
+	changes, err := audit.Diff(before, after)
+	if err != nil {
+		log.Printf("Warning: failed to diff audit entry of %s: %v", id, err)
+	}
+
+	entry := &models.AuditEntry{
+		Action:     action,
+		TargetType: audit.TargetRegistration,
+		TargetID:   id,
+		Changes:    changes,
+		RequestID:  actor,
+		CreatedAt:  time.Now(),
+	}
+	if err := repos.Audit.Append(ctx, entry); err != nil {
+		log.Printf("Warning: failed to append audit entry of %s: %v", id, err)
+	}
+}
+
+// fillEmpty sets *field to value if it is empty
+func fillEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+// union appends the values of b missing from a
+func union(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, v := range a {
+		seen[v] = true
+	}
+	for _, v := range b {
+		if !seen[v] {
+			seen[v] = true
+			a = append(a, v)
+		}
+	}
+	return a
+}
+
+// ids returns the IDs of regs
+func ids(regs []models.Registration) []string {
+	result := make([]string, len(regs))
+	for i, reg := range regs {
+		result[i] = reg.ID
+	}
+	return result
+}
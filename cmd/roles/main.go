@@ -0,0 +1,84 @@
+// Command roles grants or revokes user roles directly through Firebase.
+// It is used to bootstrap the first admin, who can then manage roles
+// through the admin API.
+//
+//	go run ./cmd/roles -uid <uid> -grant admin
+//	go run ./cmd/roles -uid <uid> -revoke organizer
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+
+	"backend-ITC/internal/config"
+	"backend-ITC/internal/firebase"
+	"backend-ITC/internal/models"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	uid := flag.String("uid", "", "Firebase UID of the user")
+	grant := flag.String("grant", "", "role to grant")
+	revoke := flag.String("revoke", "", "role to revoke")
+	configFile := flag.String("config", "", "path of a YAML config file, default $CONFIG_FILE")
+	flag.Parse()
+
+	if *uid == "" || (*grant == "") == (*revoke == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	role := *grant + *revoke
+	if !models.IsValidRole(role) {
+		log.Fatalf("Unknown role %q (valid roles: %v)", role, models.ValidRoles)
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: failed to load .env file: %v", err)
+	}
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	ctx := context.Background()
+	fc, err := firebase.Initialize(ctx, cfg.FirebaseCredentialsFile)
+	if err != nil {
+		log.Fatalf("Failed to initialize Firebase: %v", err)
+	}
+	defer fc.Close()
+
+	userRecord, err := fc.GetUser(ctx, *uid)
+	if err != nil {
+		log.Fatalf("Failed to get user: %v", err)
+	}
+
+	var roles []string
+	for _, r := range models.RolesFromClaims(userRecord.CustomClaims) {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	if *grant != "" {
+		roles = append(roles, role)
+	}
+
+	if err := fc.SetUserRoles(ctx, *uid, roles); err != nil {
+		log.Fatalf("Failed to update roles: %v", err)
+	}
+	// Sign the user out so tokens carrying the revoked role stop working
+	if *revoke != "" {
+		if err := fc.RevokeRefreshTokens(ctx, *uid); err != nil {
+			log.Fatalf("Failed to sign the user out: %v", err)
+		}
+	}
+
+	log.Printf("Roles for %s: %v", *uid, roles)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"backend-ITC/internal/config"
+	"backend-ITC/internal/devauth"
+	"backend-ITC/internal/firebase"
+	"backend-ITC/internal/logging"
+	"backend-ITC/internal/notify"
+	"backend-ITC/internal/router"
+
+	"github.com/joho/godotenv"
+)
+
+// shutdownTimeout bounds how long in-flight requests are given to finish
+// once a termination signal has been received.
+const shutdownTimeout = 15 * time.Second
+
+func main() {
+	configFile := flag.String("config", "", "path of a YAML config file, default $CONFIG_FILE; environment variables take precedence")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets masked and exit")
+	flag.Parse()
+
+	// Load .env if present; real environment variables take precedence
+	envErr := godotenv.Load()
+
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	cfg, err := config.Load(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	if *printConfig {
+		out, err := cfg.Masked().YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	// Route all logs, including those of the standard logger, through the
+	// structured JSON logger
+	slog.SetDefault(logging.New(os.Stdout, logging.ParseLevel(cfg.LogLevel)))
+
+	if envErr != nil && !errors.Is(envErr, os.ErrNotExist) {
+		slog.Warn("Failed to load .env file", "error", envErr)
+	}
+
+	// Refuse to start with settings that are invalid or unsafe for the
+	// environment
+	if err := cfg.Validate(); err != nil {
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+
+	// Dev mode needs neither a Firebase project nor credentials
+	var backend *router.Backend
+	closeBackend := func() error { return nil }
+	if cfg.DevMode {
+		slog.Warn("Dev mode: data is kept in memory and dev tokens from /dev/token are accepted")
+		backend = router.NewDevBackend(devauth.New(cfg.SessionSecret))
+	} else {
+		fc, err := firebase.Initialize(ctx, cfg.FirebaseCredentialsFile)
+		if err != nil {
+			slog.Error("Failed to initialize Firebase", "error", err)
+			os.Exit(1)
+		}
+		backend = router.NewFirebaseBackend(fc)
+		closeBackend = fc.Close
+	}
+
+	notifier, err := notify.New(cfg.ConferenceName, notify.SMTPConfig{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	})
+	if err != nil {
+		slog.Error("Failed to initialize notifications", "error", err)
+		os.Exit(1)
+	}
+
+	r, err := router.Setup(cfg, backend, notifier)
+	if err != nil {
+		slog.Error("Failed to set up routes", "error", err)
+		notifier.Close(ctx)
+		closeBackend()
+		os.Exit(1)
+	}
+
+	srv := &http.Server{
+		Addr:              net.JoinHostPort(cfg.ServerHost, cfg.ServerPort),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	// Start serving in the background so we can wait for signals below
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server listening", "addr", srv.Addr, "environment", cfg.Environment)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		if err != nil {
+			closeBackend()
+			slog.Error("Server failed", "error", err)
+			os.Exit(1)
+		}
+	case sig := <-quit:
+		slog.Info("Shutting down", "signal", sig.String())
+	}
+
+	// Drain in-flight requests before flushing queued emails and releasing
+	// Firebase resources
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server forced to shut down", "error", err)
+	}
+
+	if err := notifier.Close(shutdownCtx); err != nil {
+		slog.Error("Failed to flush notifications", "error", err)
+	}
+
+	if err := closeBackend(); err != nil {
+		slog.Error("Failed to close Firebase client", "error", err)
+	}
+
+	slog.Info("Server stopped")
+}
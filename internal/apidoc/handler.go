@@ -0,0 +1,48 @@
+package apidoc
+
+import (
+	"embed"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUI is a Swagger UI page that loads openapi.json next to it
+//
+//go:embed swagger.html
+var swaggerUI []byte
+
+// swaggerAssets are the Swagger UI scripts and styles, served by AssetHandler
+// so that the page loads nothing from third parties
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js
+var swaggerAssets embed.FS
+
+// SpecHandler serves the OpenAPI document as JSON
+func SpecHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, Spec())
+}
+
+// UIHandler serves the browsable documentation
+func UIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", swaggerUI)
+}
+
+// AssetHandler serves the Swagger UI asset named by the file parameter
+func AssetHandler(c *gin.Context) {
+	name := c.Param("file")
+	data, err := fs.ReadFile(swaggerAssets, path.Join("swagger-ui", name))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Asset not found",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, mime.TypeByExtension(path.Ext(name)), data)
+}
@@ -0,0 +1,232 @@
+// Package apidoc builds the OpenAPI 3 description of the API and serves it
+// together with a Swagger UI page. Schemas are generated from the Go types
+// that handlers bind and return, so they follow the models as they change.
+package apidoc
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Servers    []Server              `json:"servers,omitempty"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// Server is a base URL of the API
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations in the UI
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lowercase HTTP methods to the operations of one path
+type PathItem map[string]*Operation
+
+// Operation is one route
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the payload of an operation
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes one status code of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body in one content type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema as used by OpenAPI 3.0
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Components holds the reusable schemas and security schemes
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how clients authenticate
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// SecurityRequirement lists the schemes an operation accepts
+type SecurityRequirement map[string][]string
+
+// schemaBuilder turns Go types into schemas, collecting named structs as
+// components
+type schemaBuilder struct {
+	components map[string]*Schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ref returns the schema of the type of v, registering structs as components
+func (b *schemaBuilder) ref(v interface{}) *Schema {
+	return b.schema(reflect.TypeOf(v))
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() == reflect.Int64 || t.Kind() == reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: b.schema(t.Elem())}
+	case t.Kind() == reflect.Map && t.Key().Kind() == reflect.String:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		if _, ok := b.components[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			b.components[t.Name()] = &Schema{}
+			*b.components[t.Name()] = *b.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	}
+
+	// interface{} and other dynamic values accept anything
+	return &Schema{}
+}
+
+// object returns the schema of the exported, JSON-encoded fields of t. Fields
+// bound with binding:"required" are required.
+func (b *schemaBuilder) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop := b.schema(field.Type)
+		for _, rule := range strings.Split(field.Tag.Get("binding"), ",") {
+			key, value, _ := strings.Cut(rule, "=")
+			switch key {
+			case "required":
+				s.Required = append(s.Required, name)
+			case "email":
+				prop.Format = "email"
+			case "oneof":
+				prop.Enum = strings.Fields(value)
+			case "len":
+				if n, err := strconv.Atoi(value); err == nil {
+					prop.MinLength, prop.MaxLength = &n, &n
+				}
+			case "min":
+				if n, err := strconv.Atoi(value); err == nil {
+					f := float64(n)
+					prop.Minimum = &f
+				}
+			}
+		}
+
+		s.Properties[name] = prop
+	}
+
+	return s
+}
+
+// OpenAPIPath converts a Gin route pattern such as "/sessions/:id" to the
+// OpenAPI form "/sessions/{id}"
+func OpenAPIPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams returns the parameter names of a Gin route pattern
+func pathParams(ginPath string) []string {
+	var names []string
+	for _, segment := range strings.Split(ginPath, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+		}
+	}
+	return names
+}
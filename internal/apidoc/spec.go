@@ -0,0 +1,452 @@
+package apidoc
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"backend-ITC/internal/handlers"
+	"backend-ITC/internal/health"
+	"backend-ITC/internal/middleware"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/payment"
+)
+
+// Access levels of routes
+const (
+	public = iota
+	authenticated
+	metricsToken
+)
+
+// route documents one route of router.Setup
+type route struct {
+	method string
+	path   string // Gin pattern, e.g. /api/v1/sessions/:id
+	tag    string
+	id     string
+	// summary is the first line shown in the UI; description adds details
+	summary     string
+	description string
+	access      int
+	// roles lists the roles of which the caller needs at least one
+	roles  []string
+	query  []Parameter
+	header []Parameter
+	// request and response are zero values of the bound and returned types
+	request  interface{}
+	response interface{}
+	// status is the success status code, 200 when zero
+	status int
+	// also lists further success codes, with their meaning, that return the
+	// same body
+	also map[int]string
+	// content lists success bodies other than the JSON response
+	content map[string]MediaType
+	errors  []int
+}
+
+// Error responses and their descriptions
+var errorDescriptions = map[int]string{
+	http.StatusBadRequest:          "Invalid request",
+	http.StatusUnauthorized:        "Missing or invalid credentials",
+	http.StatusPaymentRequired:     "Registration not paid",
+	http.StatusForbidden:           "Insufficient permissions",
+	http.StatusNotFound:            "Not found",
+	http.StatusConflict:            "Conflicts with the current state",
+	http.StatusTooManyRequests:     "Rate limit exceeded; see Retry-After",
+	http.StatusInternalServerError: "Internal error",
+	http.StatusBadGateway:          "Payment provider failed",
+}
+
+// Query parameters shared by several routes
+var (
+	registrationFilterParams = []Parameter{
+		queryParam("ticketType", "Ticket type ID"),
+		queryParam("paymentStatus", "Payment status: pending, completed, failed or refunded"),
+		queryParam("country", "Country"),
+		queryParam("from", "First creation day (YYYY-MM-DD, UTC, inclusive)"),
+		queryParam("to", "Last creation day (YYYY-MM-DD, UTC, inclusive)"),
+	}
+	staffRoles = []string{models.RoleAdmin, models.RoleOrganizer}
+	adminRoles = []string{models.RoleAdmin}
+)
+
+// statusResponse is the body of routes that only report an outcome
+type statusResponse struct {
+	Success bool   `json:"success"`
+	Message string `json:"message"`
+}
+
+// authCacheStatsResponse is the body of GET /api/v1/admin/stats/auth-cache
+type authCacheStatsResponse struct {
+	Success bool                      `json:"success"`
+	Message string                    `json:"message"`
+	Stats   middleware.AuthCacheStats `json:"stats"`
+}
+
+// routes lists every route registered by router.Setup
+var routes = []route{
+	// Operations
+	{method: "GET", path: "/livez", tag: "Operations", id: "livez",
+		summary:     "Liveness probe",
+		description: "Reports that the process is serving requests without checking dependencies.",
+		response:    handlers.LivenessResponse{}},
+	{method: "GET", path: "/readyz", tag: "Operations", id: "readyz",
+		summary:     "Readiness probe",
+		description: "Reads from Firestore and looks up a user in Firebase Auth, each within READINESS_TIMEOUT, and reports the status and latency of every check.",
+		response:    health.Report{},
+		also:        map[int]string{http.StatusServiceUnavailable: "A dependency is down"}},
+	{method: "GET", path: "/health", tag: "Operations", id: "health",
+		summary: "Liveness probe (deprecated alias of /livez)", response: handlers.LivenessResponse{}},
+	{method: "GET", path: "/metrics", tag: "Operations", id: "metrics",
+		summary: "Prometheus metrics", access: metricsToken,
+		content: map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}},
+	{method: "GET", path: "/api/v1/openapi.json", tag: "Operations", id: "openAPI",
+		summary: "This OpenAPI document",
+		content: map[string]MediaType{"application/json": {Schema: &Schema{Type: "object"}}}},
+	{method: "GET", path: "/api/v1/docs", tag: "Operations", id: "apiDocs",
+		summary: "Browsable API documentation",
+		content: map[string]MediaType{"text/html": {Schema: &Schema{Type: "string"}}}},
+	{method: "GET", path: "/api/v1/docs/assets/:file", tag: "Operations", id: "apiDocsAsset",
+		summary: "Script or stylesheet of the browsable API documentation",
+		content: map[string]MediaType{
+			"text/javascript": {Schema: &Schema{Type: "string"}},
+			"text/css":        {Schema: &Schema{Type: "string"}},
+		}, errors: []int{404}},
+	{method: "GET", path: "/dev/token", tag: "Operations", id: "issueDevToken",
+		summary:     "Mint a dev ID token",
+		description: "Only registered in dev mode (DEV_MODE). The token is accepted in place of a Firebase ID token until it expires.",
+		query: []Parameter{
+			{Name: "uid", In: "query", Description: "User ID", Required: true, Schema: &Schema{Type: "string"}},
+			queryParam("role", "Role to grant; may be repeated. Given roles replace the user's roles and an empty role clears them"),
+			queryParam("email", "Email of a new user, derived from uid when omitted"),
+			queryParam("name", "Display name of a new user, uid when omitted"),
+		},
+		response: handlers.DevTokenResponse{}, errors: []int{400, 500}},
+
+	// Authentication
+	{method: "POST", path: "/api/v1/auth/google", tag: "Authentication", id: "googleLogin",
+		summary:     "Sign in with Google",
+		description: "Exchanges the Firebase ID token obtained after Google sign-in for the stored user profile, creating it on first login.",
+		request:     handlers.GoogleAuthRequest{}, response: handlers.AuthResponse{},
+		errors: []int{400, 401, 429, 500}},
+	{method: "POST", path: "/api/v1/auth/verify", tag: "Authentication", id: "verifyToken",
+		summary: "Verify an ID token", access: authenticated,
+		response: handlers.AuthResponse{}, errors: []int{401, 429, 500}},
+	{method: "POST", path: "/api/v1/auth/logout", tag: "Authentication", id: "logout",
+		summary:     "Sign out",
+		description: "Tokens are invalidated on the client; this endpoint only acknowledges the logout.",
+		response:    handlers.AuthResponse{}, errors: []int{429}},
+	{method: "GET", path: "/api/v1/me", tag: "Authentication", id: "getCurrentUser",
+		summary: "Get the current user", access: authenticated,
+		response: handlers.AuthResponse{}, errors: []int{401, 429, 500}},
+
+	// Sessions
+	{method: "GET", path: "/api/v1/sessions", tag: "Sessions", id: "listSessions",
+		summary: "List sessions",
+		query: []Parameter{
+			queryParam("track", "Track, e.g. technical"),
+			queryParam("tag", "Tag"),
+			queryParam("day", "Day of the session start (YYYY-MM-DD, UTC)"),
+		},
+		response: handlers.SessionResponse{}, errors: []int{400, 429, 500}},
+	{method: "GET", path: "/api/v1/sessions/:id", tag: "Sessions", id: "getSession",
+		summary: "Get a session", response: handlers.SessionResponse{}, errors: []int{404, 429, 500}},
+	{method: "POST", path: "/api/v1/admin/sessions", tag: "Sessions", id: "createSession",
+		summary: "Create a session", access: authenticated, roles: staffRoles,
+		request: models.SessionInput{}, response: handlers.SessionResponse{}, status: http.StatusCreated,
+		errors: []int{400, 401, 403, 429, 500}},
+	{method: "PUT", path: "/api/v1/admin/sessions/:id", tag: "Sessions", id: "updateSession",
+		summary:     "Update a session",
+		description: "Attendees are notified, and the waitlist is promoted when capacity grows.",
+		access:      authenticated, roles: staffRoles,
+		request: models.SessionInput{}, response: handlers.SessionResponse{},
+		errors: []int{400, 401, 403, 404, 409, 429, 500}},
+	{method: "DELETE", path: "/api/v1/admin/sessions/:id", tag: "Sessions", id: "deleteSession",
+		summary: "Delete a session", description: "Enrolled and waitlisted attendees are notified.",
+		access: authenticated, roles: staffRoles,
+		response: handlers.SessionResponse{}, errors: []int{401, 403, 404, 429, 500}},
+
+	// Enrollments
+	{method: "POST", path: "/api/v1/sessions/:id/enrollment", tag: "Enrollments", id: "enroll",
+		summary:     "Enroll in a session",
+		description: "Returns 201 with a seat, or 202 when the session is full and the user was waitlisted.",
+		access:      authenticated, response: handlers.EnrollmentResponse{}, status: http.StatusCreated,
+		also:   map[int]string{http.StatusAccepted: "Session full; added to the waitlist"},
+		errors: []int{401, 403, 404, 409, 429, 500}},
+	{method: "DELETE", path: "/api/v1/sessions/:id/enrollment", tag: "Enrollments", id: "dropEnrollment",
+		summary: "Leave a session or its waitlist", access: authenticated,
+		response: handlers.EnrollmentResponse{}, errors: []int{401, 404, 429, 500}},
+	{method: "GET", path: "/api/v1/sessions/:id/waitlist/position", tag: "Enrollments", id: "getWaitlistPosition",
+		summary: "Get the current user's waitlist position", access: authenticated,
+		response: handlers.EnrollmentResponse{}, errors: []int{401, 404, 429, 500}},
+	{method: "GET", path: "/api/v1/enrollments/me", tag: "Enrollments", id: "getMyEnrollments",
+		summary: "List the current user's enrollments", access: authenticated,
+		response: handlers.EnrollmentResponse{}, errors: []int{401, 429, 500}},
+	{method: "GET", path: "/api/v1/admin/sessions/:id/roster", tag: "Enrollments", id: "getSessionRoster",
+		summary: "Get the roster and waitlist of a session", access: authenticated, roles: staffRoles,
+		response: handlers.EnrollmentResponse{}, errors: []int{401, 403, 404, 429, 500}},
+
+	// Registrations
+	{method: "POST", path: "/api/v1/registrations", tag: "Registrations", id: "createRegistration",
+		summary: "Register for the conference", access: authenticated,
+		request: models.RegistrationInput{}, response: handlers.RegistrationResponse{}, status: http.StatusCreated,
+		errors: []int{400, 401, 403, 409, 429, 500}},
+	{method: "GET", path: "/api/v1/registrations/me", tag: "Registrations", id: "getMyRegistration",
+		summary: "Get the current user's registration", access: authenticated,
+		response: handlers.RegistrationResponse{}, errors: []int{401, 404, 429, 500}},
+	{method: "PUT", path: "/api/v1/registrations/me", tag: "Registrations", id: "updateRegistration",
+		summary: "Update the current user's registration", access: authenticated,
+		request: models.RegistrationInput{}, response: handlers.RegistrationResponse{},
+		errors: []int{400, 401, 403, 404, 409, 429, 500}},
+	{method: "DELETE", path: "/api/v1/registrations/me", tag: "Registrations", id: "deleteRegistration",
+		summary:     "Cancel the current user's registration",
+		description: "Paid and checked-in registrations cannot be cancelled; they need a refund from an admin.",
+		access:      authenticated,
+		response:    handlers.RegistrationResponse{}, errors: []int{401, 404, 409, 429, 500}},
+	{method: "GET", path: "/api/v1/admin/registrations", tag: "Registrations", id: "listRegistrations",
+		summary: "List registrations", access: authenticated, roles: staffRoles,
+		query: append(append([]Parameter(nil), registrationFilterParams...),
+			queryParam("sort", "createdAt or lastName, prefixed with - for descending order; lastName cannot be combined with from or to"),
+			queryParam("limit", "Page size, 1 to 200 (default 50)"),
+			queryParam("cursor", "nextCursor of the previous page"),
+		),
+		response: handlers.RegistrationListResponse{}, errors: []int{400, 401, 403, 429, 500}},
+	{method: "GET", path: "/api/v1/admin/registrations/export", tag: "Registrations", id: "exportRegistrations",
+		summary: "Export registrations as CSV or XLSX", access: authenticated, roles: staffRoles,
+		query: append([]Parameter{queryParam("format", "csv (default) or xlsx")}, registrationFilterParams...),
+		content: map[string]MediaType{
+			"text/csv": {Schema: &Schema{Type: "string"}},
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": {Schema: &Schema{Type: "string", Format: "binary"}},
+		},
+		errors: []int{400, 401, 403, 429}},
+
+	// Ticket types
+	{method: "GET", path: "/api/v1/ticket-types", tag: "Ticket types", id: "listTicketTypesOnSale",
+		summary: "List ticket types on sale", response: handlers.TicketTypeResponse{}, errors: []int{429, 500}},
+	{method: "GET", path: "/api/v1/admin/ticket-types", tag: "Ticket types", id: "listTicketTypes",
+		summary: "List all ticket types", access: authenticated, roles: adminRoles,
+		response: handlers.TicketTypeResponse{}, errors: []int{401, 403, 429, 500}},
+	{method: "POST", path: "/api/v1/admin/ticket-types", tag: "Ticket types", id: "createTicketType",
+		summary: "Create a ticket type", access: authenticated, roles: adminRoles,
+		request: models.TicketTypeInput{}, response: handlers.TicketTypeResponse{}, status: http.StatusCreated,
+		errors: []int{400, 401, 403, 409, 429, 500}},
+	{method: "PUT", path: "/api/v1/admin/ticket-types/:id", tag: "Ticket types", id: "updateTicketType",
+		summary: "Update a ticket type", access: authenticated, roles: adminRoles,
+		request: models.TicketTypeInput{}, response: handlers.TicketTypeResponse{},
+		errors: []int{400, 401, 403, 404, 409, 429, 500}},
+	{method: "DELETE", path: "/api/v1/admin/ticket-types/:id", tag: "Ticket types", id: "deleteTicketType",
+		summary: "Delete an unsold ticket type", access: authenticated, roles: adminRoles,
+		response: handlers.TicketTypeResponse{}, errors: []int{401, 403, 404, 409, 429, 500}},
+
+	// Promo codes
+	{method: "GET", path: "/api/v1/admin/promo-codes", tag: "Promo codes", id: "listPromoCodes",
+		summary: "List promo codes with their usage", access: authenticated, roles: adminRoles,
+		response: handlers.PromoCodeResponse{}, errors: []int{401, 403, 429, 500}},
+	{method: "POST", path: "/api/v1/admin/promo-codes", tag: "Promo codes", id: "createPromoCode",
+		summary: "Create a promo code", access: authenticated, roles: adminRoles,
+		request: models.PromoCodeInput{}, response: handlers.PromoCodeResponse{}, status: http.StatusCreated,
+		errors: []int{400, 401, 403, 409, 429, 500}},
+	{method: "PUT", path: "/api/v1/admin/promo-codes/:id", tag: "Promo codes", id: "updatePromoCode",
+		summary: "Update a promo code", access: authenticated, roles: adminRoles,
+		request: models.PromoCodeInput{}, response: handlers.PromoCodeResponse{},
+		errors: []int{400, 401, 403, 404, 409, 429, 500}},
+	{method: "DELETE", path: "/api/v1/admin/promo-codes/:id", tag: "Promo codes", id: "deletePromoCode",
+		summary: "Delete an unused promo code", access: authenticated, roles: adminRoles,
+		response: handlers.PromoCodeResponse{}, errors: []int{401, 403, 404, 409, 429, 500}},
+
+	// Payments
+	{method: "POST", path: "/api/v1/registrations/me/checkout", tag: "Payments", id: "createCheckout",
+		summary: "Start paying for the current user's registration", access: authenticated,
+		response: handlers.PaymentResponse{}, errors: []int{401, 404, 409, 429, 500, 502}},
+	{method: "POST", path: "/api/v1/payments/webhook", tag: "Payments", id: "paymentWebhook",
+		summary: "Receive a payment event from the provider",
+		description: "The raw body must be signed in the " + payment.SignatureHeader + " header, or in the " +
+			payment.StripeSignatureHeader + " header with the stripe provider.",
+		header: []Parameter{
+			{Name: payment.SignatureHeader, In: "header",
+				Description: "Signature of the raw body (fake provider)", Schema: &Schema{Type: "string"}},
+			{Name: payment.StripeSignatureHeader, In: "header",
+				Description: "Signature of the raw body (stripe provider)", Schema: &Schema{Type: "string"}},
+		},
+		request: payment.Event{}, response: handlers.PaymentResponse{},
+		errors: []int{400, 401, 404, 500}},
+	{method: "POST", path: "/api/v1/payments/fake/:sessionId", tag: "Payments", id: "simulatePayment",
+		summary:     "Simulate a payment event (fake provider)",
+		description: "Only registered in dev mode.",
+		request:     handlers.SimulatePaymentRequest{}, response: handlers.PaymentResponse{},
+		errors: []int{400, 404, 500}},
+
+	// Check-in
+	{method: "GET", path: "/api/v1/registrations/me/ticket", tag: "Check-in", id: "getMyTicket",
+		summary: "Get the signed ticket of the current user's paid registration", access: authenticated,
+		query:    []Parameter{queryParam("format", "png for a QR code image instead of JSON")},
+		response: handlers.CheckInResponse{},
+		content:  map[string]MediaType{"image/png": {Schema: &Schema{Type: "string", Format: "binary"}}},
+		errors:   []int{401, 402, 404, 429, 500}},
+	{method: "POST", path: "/api/v1/checkin/scan", tag: "Check-in", id: "scanTicket",
+		summary: "Check in an attendee by ticket", access: authenticated,
+		roles:   []string{models.RoleCheckInStaff, models.RoleOrganizer, models.RoleAdmin},
+		request: models.CheckInInput{}, response: handlers.CheckInResponse{},
+		errors: []int{400, 401, 402, 403, 404, 409, 429, 500}},
+
+	// Administration
+	{method: "GET", path: "/api/v1/admin/users/:uid/roles", tag: "Administration", id: "getUserRoles",
+		summary: "Get a user's roles", access: authenticated, roles: adminRoles,
+		response: handlers.RolesResponse{}, errors: []int{401, 403, 404, 429}},
+	{method: "POST", path: "/api/v1/admin/users/:uid/roles", tag: "Administration", id: "grantRole",
+		summary: "Grant a role", access: authenticated, roles: adminRoles,
+		request: models.RoleInput{}, response: handlers.RolesResponse{},
+		errors: []int{400, 401, 403, 404, 429, 500}},
+	{method: "DELETE", path: "/api/v1/admin/users/:uid/roles/:role", tag: "Administration", id: "revokeRole",
+		summary:     "Revoke a role",
+		description: "Also revokes the user's tokens, so they are signed out and must sign in again. Revoking a role the user does not have changes nothing.",
+		access:      authenticated,
+		roles:       adminRoles,
+		response:    handlers.RolesResponse{}, errors: []int{400, 401, 403, 404, 429, 500}},
+	{method: "GET", path: "/api/v1/admin/audit", tag: "Administration", id: "listAuditEntries",
+		summary: "List audit entries, newest first", access: authenticated, roles: adminRoles,
+		query: []Parameter{
+			queryParam("actor", "UID of the user who made the change"),
+			queryParam("targetType", "Kind of the changed object"),
+			queryParam("target", "ID of the changed object"),
+			queryParam("from", "Start as an RFC 3339 timestamp or YYYY-MM-DD date (UTC)"),
+			queryParam("to", "End as an RFC 3339 timestamp (exclusive) or YYYY-MM-DD date (inclusive)"),
+			queryParam("limit", "Number of entries, up to 500 (default 50)"),
+			queryParam("cursor", "nextCursor of the previous page"),
+		},
+		response: handlers.AuditResponse{}, errors: []int{400, 401, 403, 429, 500}},
+	{method: "GET", path: "/api/v1/admin/stats/auth-cache", tag: "Administration", id: "getAuthCacheStats",
+		summary: "Get auth cache statistics", access: authenticated, roles: adminRoles,
+		response: authCacheStatsResponse{}, errors: []int{401, 403, 429}},
+}
+
+var (
+	specOnce sync.Once
+	spec     *Document
+)
+
+// Spec returns the OpenAPI document of the API
+func Spec() *Document {
+	specOnce.Do(func() {
+		spec = build()
+	})
+	return spec
+}
+
+// build assembles the document from routes
+func build() *Document {
+	b := &schemaBuilder{components: make(map[string]*Schema)}
+	errorSchema := b.ref(statusResponse{})
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Conference API",
+			Description: "Registration, sessions, ticketing and check-in for the conference. Authenticated routes expect a Firebase ID token.",
+			Version:     "1.0.0",
+		},
+		Servers: []Server{{URL: "/"}},
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas: b.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				"firebaseAuth": {
+					Type:         "http",
+					Scheme:       "bearer",
+					BearerFormat: "JWT",
+					Description:  "Firebase ID token obtained after sign-in",
+				},
+				"metricsToken": {
+					Type:        "http",
+					Scheme:      "bearer",
+					Description: "METRICS_TOKEN, when configured",
+				},
+			},
+		},
+	}
+
+	tags := make(map[string]bool)
+	for _, r := range routes {
+		if !tags[r.tag] {
+			tags[r.tag] = true
+			doc.Tags = append(doc.Tags, Tag{Name: r.tag})
+		}
+
+		op := &Operation{
+			Tags:        []string{r.tag},
+			Summary:     r.summary,
+			Description: r.description,
+			OperationID: r.id,
+			Responses:   make(map[string]*Response),
+		}
+
+		if len(r.roles) > 0 {
+			note := "Requires one of the roles: " + strings.Join(r.roles, ", ") + "."
+			if op.Description != "" {
+				note = op.Description + "\n\n" + note
+			}
+			op.Description = note
+		}
+
+		switch r.access {
+		case authenticated:
+			op.Security = []SecurityRequirement{{"firebaseAuth": {}}}
+		case metricsToken:
+			op.Security = []SecurityRequirement{{"metricsToken": {}}, {}}
+		}
+
+		for _, name := range pathParams(r.path) {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+			})
+		}
+		op.Parameters = append(op.Parameters, r.query...)
+		op.Parameters = append(op.Parameters, r.header...)
+
+		if r.request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: b.ref(r.request)}},
+			}
+		}
+
+		status := r.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		content := make(map[string]MediaType, len(r.content)+1)
+		for mediaType, media := range r.content {
+			content[mediaType] = media
+		}
+		if r.response != nil {
+			content["application/json"] = MediaType{Schema: b.ref(r.response)}
+		}
+		op.Responses[strconv.Itoa(status)] = &Response{Description: http.StatusText(status), Content: content}
+		for code, description := range r.also {
+			op.Responses[strconv.Itoa(code)] = &Response{Description: description, Content: content}
+		}
+
+		for _, code := range r.errors {
+			op.Responses[strconv.Itoa(code)] = &Response{
+				Description: errorDescriptions[code],
+				Content:     map[string]MediaType{"application/json": {Schema: errorSchema}},
+			}
+		}
+
+		path := OpenAPIPath(r.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(PathItem)
+		}
+		doc.Paths[path][strings.ToLower(r.method)] = op
+	}
+
+	return doc
+}
+
+// queryParam documents an optional string query parameter
+func queryParam(name, description string) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: "string"}}
+}
@@ -0,0 +1,122 @@
+// Package audit records who changed what through the API in an append-only
+// log stored by repository.AuditRepository.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"reflect"
+	"sort"
+	"time"
+
+	"backend-ITC/internal/logging"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Target types of audit entries
+const (
+	TargetRegistration = "registration"
+	TargetSession      = "session"
+	TargetTicketType   = "ticket_type"
+	TargetPromoCode    = "promo_code"
+	TargetUser         = "user"
+)
+
+// Logger appends audit entries for the current request
+type Logger struct {
+	entries repository.AuditRepository
+}
+
+// NewLogger creates a new audit logger writing to entries
+func NewLogger(entries repository.AuditRepository) *Logger {
+	return &Logger{entries: entries}
+}
+
+// Record appends an entry for action on the target, listing the fields that
+// differ between before and after. before is nil for creations and after is
+// nil for deletions. The actor, IP and request ID are taken from c.
+// Failures are logged rather than returned because the change being audited
+// has already been applied.
+func (l *Logger) Record(c *gin.Context, action, targetType, targetID string, before, after interface{}) {
+	if l == nil {
+		return
+	}
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to diff audit entry", "action", action, "target_id", targetID, "error", err)
+	}
+
+	entry := &models.AuditEntry{
+		ActorUID:   c.GetString("uid"),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Changes:    changes,
+		IP:         c.ClientIP(),
+		RequestID:  logging.RequestID(c.Request.Context()),
+		CreatedAt:  time.Now(),
+	}
+
+	if err := l.entries.Append(context.WithoutCancel(c.Request.Context()), entry); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to append audit entry", "action", action, "target_id", targetID, "error", err)
+	}
+}
+
+// Diff compares the JSON representations of before and after and returns
+// the changed top-level fields ordered by name. Either value may be nil.
+func Diff(before, after interface{}) ([]models.AuditChange, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]struct{}, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names[name] = struct{}{}
+	}
+	for name := range afterFields {
+		names[name] = struct{}{}
+	}
+
+	changes := []models.AuditChange{}
+	for name := range names {
+		b, a := beforeFields[name], afterFields[name]
+		if reflect.DeepEqual(b, a) {
+			continue
+		}
+		changes = append(changes, models.AuditChange{Field: name, Before: b, After: a})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
+
+// fields returns the JSON object fields of v, or nil if v is nil
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,123 @@
+// Package cache provides a bounded in-memory cache with per-entry expiry.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are the counters of a cache since it was created
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+}
+
+// entry is a cached value with its key, kept in the LRU list
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// Cache is a least-recently-used cache holding at most a fixed number of
+// entries, each expiring after its own TTL. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	items      map[K]*list.Element
+	lru        *list.List // front is most recently used
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// New creates a cache holding at most maxEntries entries
+func New[K comparable, V any](maxEntries int) *Cache[K, V] {
+	return &Cache[K, V]{
+		maxEntries: maxEntries,
+		items:      make(map[K]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Get returns the value stored for key if it has not expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		if time.Now().Before(e.expiresAt) {
+			c.lru.MoveToFront(el)
+			c.hits.Add(1)
+			return e.value, true
+		}
+		c.remove(el)
+	}
+
+	c.misses.Add(1)
+	var zero V
+	return zero, false
+}
+
+// Set stores value for key for the given TTL, evicting the least recently
+// used entry when the cache is full. Non-positive TTLs are ignored.
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
+	if ttl <= 0 || c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = expiresAt
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.lru.PushFront(&entry[K, V]{key: key, value: value, expiresAt: expiresAt})
+
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// Delete removes the entry for key, if any
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Stats returns the current counters
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+	}
+}
+
+// remove unlinks el. Callers must hold c.mu.
+func (c *Cache[K, V]) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
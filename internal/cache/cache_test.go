@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheLRU(t *testing.T) {
+	tests := []struct {
+		name      string
+		ops       func(c *Cache[string, int])
+		present   []string
+		absent    []string
+		evictions uint64
+	}{
+		{
+			name: "evicts the oldest entry",
+			ops: func(c *Cache[string, int]) {
+				c.Set("a", 1, time.Hour)
+				c.Set("b", 2, time.Hour)
+				c.Set("c", 3, time.Hour)
+				c.Set("d", 4, time.Hour)
+			},
+			present:   []string{"b", "c", "d"},
+			absent:    []string{"a"},
+			evictions: 1,
+		},
+		{
+			name: "get marks an entry as used",
+			ops: func(c *Cache[string, int]) {
+				c.Set("a", 1, time.Hour)
+				c.Set("b", 2, time.Hour)
+				c.Set("c", 3, time.Hour)
+				c.Get("a")
+				c.Set("d", 4, time.Hour)
+			},
+			present:   []string{"a", "c", "d"},
+			absent:    []string{"b"},
+			evictions: 1,
+		},
+		{
+			name: "overwriting does not evict",
+			ops: func(c *Cache[string, int]) {
+				c.Set("a", 1, time.Hour)
+				c.Set("b", 2, time.Hour)
+				c.Set("c", 3, time.Hour)
+				c.Set("a", 10, time.Hour)
+				c.Set("d", 4, time.Hour)
+			},
+			present:   []string{"a", "c", "d"},
+			absent:    []string{"b"},
+			evictions: 1,
+		},
+		{
+			name: "delete frees a slot",
+			ops: func(c *Cache[string, int]) {
+				c.Set("a", 1, time.Hour)
+				c.Set("b", 2, time.Hour)
+				c.Set("c", 3, time.Hour)
+				c.Delete("b")
+				c.Set("d", 4, time.Hour)
+			},
+			present: []string{"a", "c", "d"},
+			absent:  []string{"b"},
+		},
+		{
+			name: "non-positive TTLs are ignored",
+			ops: func(c *Cache[string, int]) {
+				c.Set("a", 1, 0)
+				c.Set("b", 2, -time.Second)
+			},
+			absent: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New[string, int](3)
+			tt.ops(c)
+			for _, key := range tt.present {
+				if _, ok := c.Get(key); !ok {
+					t.Errorf("Get(%q) missed", key)
+				}
+			}
+			for _, key := range tt.absent {
+				if v, ok := c.Get(key); ok {
+					t.Errorf("Get(%q) = %d, want a miss", key, v)
+				}
+			}
+			if got := c.Stats().Evictions; got != tt.evictions {
+				t.Errorf("evictions = %d, want %d", got, tt.evictions)
+			}
+		})
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c := New[string, int](10)
+	c.Set("short", 1, 10*time.Millisecond)
+	c.Set("long", 2, time.Hour)
+
+	if v, ok := c.Get("short"); !ok || v != 1 {
+		t.Fatalf("Get(short) = %d, %v before expiry", v, ok)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("short"); ok {
+		t.Error("Get(short) hit after expiry")
+	}
+	if v, ok := c.Get("long"); !ok || v != 2 {
+		t.Errorf("Get(long) = %d, %v", v, ok)
+	}
+
+	// Setting again renews the TTL
+	c.Set("long", 3, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := c.Get("long"); ok {
+		t.Error("Get(long) hit after its renewed TTL")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Size != 0 || stats.Evictions != 0 {
+		t.Errorf("Stats() = %+v, want 2 hits, 2 misses, no entries or evictions", stats)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	c := New[string, int](0)
+	c.Set("a", 1, time.Hour)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a cache without entries stored a value")
+	}
+}
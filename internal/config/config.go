@@ -1,59 +1,346 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
-// Config holds all configuration for the application
+// Config holds all configuration for the application. Settings are read from
+// an optional YAML file, using the lowercase environment variable names as
+// keys, and environment variables take precedence over the file.
 type Config struct {
 	// Server configuration
-	ServerPort string
-	ServerHost string
+	ServerPort string `yaml:"server_port"`
+	ServerHost string `yaml:"server_host"`
+	// TrustedProxies lists the proxy addresses or CIDRs whose X-Forwarded-For
+	// header is used to find the client IP. Empty trusts no proxy.
+	TrustedProxies []string `yaml:"trusted_proxies"`
 
 	// Firebase configuration
-	FirebaseCredentialsFile string
-	FirebaseProjectID       string
+	FirebaseCredentialsFile string `yaml:"firebase_credentials_file"`
+	FirebaseProjectID       string `yaml:"firebase_project_id"`
 
 	// Google OAuth configuration
-	GoogleClientID     string
-	GoogleClientSecret string
-	GoogleRedirectURL  string
+	GoogleClientID     string `yaml:"google_client_id"`
+	GoogleClientSecret string `yaml:"google_client_secret"`
+	GoogleRedirectURL  string `yaml:"google_redirect_url"`
 
 	// Session configuration
-	SessionSecret string
+	SessionSecret string `yaml:"session_secret"`
+
+	// Payment configuration: the provider (fake or stripe), its secret API
+	// key and the secret its webhooks are signed with
+	PaymentProvider      string `yaml:"payment_provider"`
+	PaymentAPIKey        string `yaml:"payment_api_key"`
+	PaymentWebhookSecret string `yaml:"payment_webhook_secret"`
+
+	// Email configuration; notifications are only logged when SMTPHost is empty
+	SMTPHost       string `yaml:"smtp_host"`
+	SMTPPort       int    `yaml:"smtp_port"`
+	SMTPUsername   string `yaml:"smtp_username"`
+	SMTPPassword   string `yaml:"smtp_password"`
+	SMTPFrom       string `yaml:"smtp_from"`
+	ConferenceName string `yaml:"conference_name"`
+
+	// Rate limiting: sustained requests per second and burst size per client
+	// IP on public routes and per user on protected routes. A rate of 0
+	// disables the limiter.
+	PublicRateLimit float64 `yaml:"rate_limit_public_rps"`
+	PublicRateBurst int     `yaml:"rate_limit_public_burst"`
+	UserRateLimit   float64 `yaml:"rate_limit_user_rps"`
+	UserRateBurst   int     `yaml:"rate_limit_user_burst"`
+
+	// Caching of verified ID tokens and user profiles in the auth middleware
+	AuthCacheTTL  time.Duration `yaml:"auth_cache_ttl"`
+	AuthCacheSize int           `yaml:"auth_cache_size"`
+
+	// Metrics: bearer token required to scrape /metrics (empty leaves it
+	// open, which production rejects) and how often the registration gauges
+	// are recounted
+	MetricsToken           string        `yaml:"metrics_token"`
+	MetricsRefreshInterval time.Duration `yaml:"metrics_refresh_interval"`
+
+	// Readiness: how long each dependency check of /readyz may take
+	ReadinessTimeout time.Duration `yaml:"readiness_timeout"`
+
+	// Logging: minimum level of structured logs (debug, info, warn or error)
+	LogLevel string `yaml:"log_level"`
 
 	// Environment
-	Environment string
+	Environment string `yaml:"environment"`
+
+	// DevMode serves from memory and accepts locally minted dev tokens
+	// instead of Firebase, so the server runs offline
+	DevMode bool `yaml:"dev_mode"`
 
 	// Frontend URL for CORS and redirects
-	FrontendURL string
+	FrontendURL string `yaml:"frontend_url"`
+}
+
+// Development defaults of secrets. Validate rejects them in production.
+const (
+	defaultSessionSecret        = "your-secret-key-change-in-production"
+	defaultPaymentWebhookSecret = "dev-webhook-secret"
+)
+
+// placeholderValues are the example values of .env.example and
+// config.example.yaml, which must be replaced before running in production
+var placeholderValues = map[string]bool{
+	"your-secure-session-secret-change-in-production": true,
+	"your-payment-webhook-secret":                     true,
+	"your-firebase-project-id":                        true,
 }
 
-// Load loads configuration from environment variables
-func Load() *Config {
-	return &Config{
+// Load loads configuration from the YAML file at path, if path is not
+// empty, and from environment variables, which take precedence
+func Load(path string) (*Config, error) {
+	cfg := &Config{
 		// Server
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-		ServerHost: getEnv("SERVER_HOST", "0.0.0.0"),
+		ServerPort: "8080",
+		ServerHost: "0.0.0.0",
 
 		// Firebase
-		FirebaseCredentialsFile: getEnv("FIREBASE_CREDENTIALS_FILE", "firebase-service-account.json"),
-		FirebaseProjectID:       getEnv("FIREBASE_PROJECT_ID", ""),
+		FirebaseCredentialsFile: "firebase-service-account.json",
 
 		// Google OAuth
-		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
-		GoogleRedirectURL:  getEnv("GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/google/callback"),
+		GoogleRedirectURL: "http://localhost:8080/auth/google/callback",
 
 		// Session
-		SessionSecret: getEnv("SESSION_SECRET", "your-secret-key-change-in-production"),
+		SessionSecret: defaultSessionSecret,
+
+		// Payment
+		PaymentProvider:      "fake",
+		PaymentWebhookSecret: defaultPaymentWebhookSecret,
+
+		// Email
+		SMTPPort:       1025,
+		SMTPFrom:       "Conference <no-reply@localhost>",
+		ConferenceName: "the conference",
+
+		// Rate limiting
+		PublicRateLimit: 1,
+		PublicRateBurst: 20,
+		UserRateLimit:   5,
+		UserRateBurst:   50,
+
+		// Auth cache
+		AuthCacheTTL:  5 * time.Minute,
+		AuthCacheSize: 10000,
+
+		// Metrics
+		MetricsRefreshInterval: time.Minute,
+
+		// Readiness
+		ReadinessTimeout: 2 * time.Second,
+
+		// Logging
+		LogLevel: "info",
 
 		// Environment
-		Environment: getEnv("ENVIRONMENT", "development"),
+		Environment: "development",
 
 		// Frontend
-		FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+		FrontendURL: "http://localhost:3000",
+	}
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// loadFile overrides c with the settings of a YAML file. Unknown keys are
+// rejected so that typos do not go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides c with the environment variables that are set
+func (c *Config) loadEnv() error {
+	env := &envReader{}
+
+	// Server
+	c.ServerPort = env.get("SERVER_PORT", c.ServerPort)
+	c.ServerHost = env.get("SERVER_HOST", c.ServerHost)
+	c.TrustedProxies = env.getList("TRUSTED_PROXIES", c.TrustedProxies)
+
+	// Firebase
+	c.FirebaseCredentialsFile = env.get("FIREBASE_CREDENTIALS_FILE", c.FirebaseCredentialsFile)
+	c.FirebaseProjectID = env.get("FIREBASE_PROJECT_ID", c.FirebaseProjectID)
+
+	// Google OAuth
+	c.GoogleClientID = env.get("GOOGLE_CLIENT_ID", c.GoogleClientID)
+	c.GoogleClientSecret = env.get("GOOGLE_CLIENT_SECRET", c.GoogleClientSecret)
+	c.GoogleRedirectURL = env.get("GOOGLE_REDIRECT_URL", c.GoogleRedirectURL)
+
+	// Session
+	c.SessionSecret = env.get("SESSION_SECRET", c.SessionSecret)
+
+	// Payment
+	c.PaymentProvider = env.get("PAYMENT_PROVIDER", c.PaymentProvider)
+	c.PaymentAPIKey = env.get("PAYMENT_API_KEY", c.PaymentAPIKey)
+	c.PaymentWebhookSecret = env.get("PAYMENT_WEBHOOK_SECRET", c.PaymentWebhookSecret)
+
+	// Email
+	c.SMTPHost = env.get("SMTP_HOST", c.SMTPHost)
+	c.SMTPPort = env.getInt("SMTP_PORT", c.SMTPPort)
+	c.SMTPUsername = env.get("SMTP_USERNAME", c.SMTPUsername)
+	c.SMTPPassword = env.get("SMTP_PASSWORD", c.SMTPPassword)
+	c.SMTPFrom = env.get("SMTP_FROM", c.SMTPFrom)
+	c.ConferenceName = env.get("CONFERENCE_NAME", c.ConferenceName)
+
+	// Rate limiting
+	c.PublicRateLimit = env.getFloat("RATE_LIMIT_PUBLIC_RPS", c.PublicRateLimit)
+	c.PublicRateBurst = env.getInt("RATE_LIMIT_PUBLIC_BURST", c.PublicRateBurst)
+	c.UserRateLimit = env.getFloat("RATE_LIMIT_USER_RPS", c.UserRateLimit)
+	c.UserRateBurst = env.getInt("RATE_LIMIT_USER_BURST", c.UserRateBurst)
+
+	// Auth cache
+	c.AuthCacheTTL = env.getDuration("AUTH_CACHE_TTL", c.AuthCacheTTL)
+	c.AuthCacheSize = env.getInt("AUTH_CACHE_SIZE", c.AuthCacheSize)
+
+	// Metrics
+	c.MetricsToken = env.get("METRICS_TOKEN", c.MetricsToken)
+	c.MetricsRefreshInterval = env.getDuration("METRICS_REFRESH_INTERVAL", c.MetricsRefreshInterval)
+
+	// Readiness
+	c.ReadinessTimeout = env.getDuration("READINESS_TIMEOUT", c.ReadinessTimeout)
+
+	// Logging
+	c.LogLevel = env.get("LOG_LEVEL", c.LogLevel)
+
+	// Environment
+	c.Environment = env.get("ENVIRONMENT", c.Environment)
+	c.DevMode = env.getBool("DEV_MODE", c.DevMode)
+
+	// Frontend
+	c.FrontendURL = env.get("FRONTEND_URL", c.FrontendURL)
+
+	return errors.Join(env.errs...)
+}
+
+// Validate reports every setting that is invalid. In production it also
+// rejects development secrets, placeholder values and a missing Firebase
+// project ID.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("config: "+format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.ServerPort); err != nil || port < 1 || port > 65535 {
+		invalid("SERVER_PORT %q is not a port between 1 and 65535", c.ServerPort)
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			invalid("TRUSTED_PROXIES entry %q is not an IP address or CIDR", proxy)
+		}
+	}
+	if c.SMTPHost != "" && (c.SMTPPort < 1 || c.SMTPPort > 65535) {
+		invalid("SMTP_PORT %d is not a port between 1 and 65535", c.SMTPPort)
+	}
+	if c.PublicRateLimit < 0 || c.PublicRateBurst < 0 || c.UserRateLimit < 0 || c.UserRateBurst < 0 {
+		invalid("rate limits must not be negative")
+	}
+	if c.AuthCacheTTL < 0 || c.AuthCacheSize < 0 {
+		invalid("AUTH_CACHE_TTL and AUTH_CACHE_SIZE must not be negative")
+	}
+	if c.MetricsRefreshInterval < 0 {
+		invalid("METRICS_REFRESH_INTERVAL must not be negative")
+	}
+	if c.ReadinessTimeout <= 0 {
+		invalid("READINESS_TIMEOUT must be positive")
+	}
+	switch c.PaymentProvider {
+	case "", "fake":
+	case "stripe":
+		if c.PaymentAPIKey == "" {
+			invalid("PAYMENT_API_KEY is required with the %s payment provider", c.PaymentProvider)
+		}
+	default:
+		invalid("PAYMENT_PROVIDER %q must be fake or stripe", c.PaymentProvider)
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("LOG_LEVEL %q must be debug, info, warn or error", c.LogLevel)
 	}
+
+	if c.IsProduction() {
+		if c.DevMode {
+			invalid("DEV_MODE must not be enabled in production")
+		}
+		if c.SessionSecret == "" || c.SessionSecret == defaultSessionSecret || placeholderValues[c.SessionSecret] {
+			invalid("SESSION_SECRET must be set to a secret value in production")
+		}
+		if c.PaymentWebhookSecret == "" || c.PaymentWebhookSecret == defaultPaymentWebhookSecret || placeholderValues[c.PaymentWebhookSecret] {
+			invalid("PAYMENT_WEBHOOK_SECRET must be set to a secret value in production")
+		}
+		if c.PaymentProvider == "" || c.PaymentProvider == "fake" {
+			invalid("PAYMENT_PROVIDER must be stripe in production, not the fake provider")
+		}
+		if c.FirebaseProjectID == "" || placeholderValues[c.FirebaseProjectID] {
+			invalid("FIREBASE_PROJECT_ID is required in production")
+		}
+		if c.MetricsToken == "" {
+			invalid("METRICS_TOKEN must be set in production")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// masked replaces a set secret so that it can be shown
+const masked = "********"
+
+// Masked returns a copy of c with every secret replaced by asterisks
+func (c *Config) Masked() *Config {
+	m := *c
+	m.TrustedProxies = append([]string(nil), c.TrustedProxies...)
+	for _, secret := range []*string{
+		&m.GoogleClientSecret,
+		&m.SessionSecret,
+		&m.PaymentAPIKey,
+		&m.PaymentWebhookSecret,
+		&m.SMTPPassword,
+		&m.MetricsToken,
+	} {
+		if *secret != "" {
+			*secret = masked
+		}
+	}
+	return &m
+}
+
+// YAML renders c in the format of a config file
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
 }
 
 // IsDevelopment returns true if running in development mode
@@ -66,10 +353,87 @@ func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
 }
 
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
+// envReader reads typed environment variables, collecting the ones that
+// cannot be parsed instead of silently using the default
+type envReader struct {
+	errs []error
+}
+
+// get gets an environment variable or returns a default value
+func (r *envReader) get(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
 	}
 	return defaultValue
 }
+
+// getInt gets an integer environment variable or returns a default value
+func (r *envReader) getInt(key string, defaultValue int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("config: %s: %q is not an integer", key, value))
+			return defaultValue
+		}
+		return n
+	}
+	return defaultValue
+}
+
+// getFloat gets a floating point environment variable or returns a default value
+func (r *envReader) getFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("config: %s: %q is not a number", key, value))
+			return defaultValue
+		}
+		return f
+	}
+	return defaultValue
+}
+
+// getBool gets a boolean environment variable such as "true" or "1" or returns
+// a default value
+func (r *envReader) getBool(key string, defaultValue bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("config: %s: %q is not a boolean", key, value))
+			return defaultValue
+		}
+		return b
+	}
+	return defaultValue
+}
+
+// getDuration gets a duration environment variable such as "90s" or returns a
+// default value
+func (r *envReader) getDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("config: %s: %q is not a duration", key, value))
+			return defaultValue
+		}
+		return d
+	}
+	return defaultValue
+}
+
+// getList gets a comma-separated environment variable as a list, skipping empty
+// items, or returns a default value when the variable is unset
+func (r *envReader) getList(key string, defaultValue []string) []string {
+	raw, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(raw, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsetEnv clears keys for the duration of the test
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+// writeConfigFile writes a YAML config file and returns its path
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	unsetEnv(t, "SERVER_HOST", "LOG_LEVEL", "METRICS_REFRESH_INTERVAL", "RATE_LIMIT_USER_RPS")
+	path := writeConfigFile(t, `
+server_port: "9000"
+log_level: debug
+trusted_proxies: [10.0.0.0/8]
+metrics_refresh_interval: 30s
+rate_limit_user_rps: 2
+`)
+	t.Setenv("SERVER_PORT", "9100")
+	t.Setenv("TRUSTED_PROXIES", "1.2.3.4, ,5.6.7.8")
+	t.Setenv("RATE_LIMIT_PUBLIC_BURST", "7")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"env over YAML", cfg.ServerPort, "9100"},
+		{"env list over YAML", cfg.TrustedProxies, []string{"1.2.3.4", "5.6.7.8"}},
+		{"env over default", cfg.PublicRateBurst, 7},
+		{"YAML over default", cfg.LogLevel, "debug"},
+		{"YAML duration", cfg.MetricsRefreshInterval, 30 * time.Second},
+		{"YAML float", cfg.UserRateLimit, 2.0},
+		{"default", cfg.ServerHost, "0.0.0.0"},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(tt.got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		env  map[string]string
+		want string
+	}{
+		{"unknown YAML key", "server_prot: 80\n", nil, "server_prot"},
+		{"malformed YAML", "server_port: [\n", nil, "parse"},
+		{"invalid integer", "", map[string]string{"SMTP_PORT": "twenty-five"}, "SMTP_PORT"},
+		{"invalid number", "", map[string]string{"RATE_LIMIT_USER_RPS": "fast"}, "RATE_LIMIT_USER_RPS"},
+		{"invalid duration", "", map[string]string{"AUTH_CACHE_TTL": "5"}, "AUTH_CACHE_TTL"},
+		{"invalid boolean", "", map[string]string{"DEV_MODE": "maybe"}, "DEV_MODE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "SMTP_PORT", "RATE_LIMIT_USER_RPS", "AUTH_CACHE_TTL", "DEV_MODE")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load(writeConfigFile(t, tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load() of a missing file succeeded")
+	}
+}
+
+// validConfig returns a configuration that passes Validate in production
+func validConfig() *Config {
+	return &Config{
+		ServerPort:           "8080",
+		SessionSecret:        "a-production-session-secret",
+		PaymentProvider:      "stripe",
+		PaymentAPIKey:        "sk_live_key",
+		PaymentWebhookSecret: "whsec_production",
+		FirebaseProjectID:    "itc-conference",
+		MetricsToken:         "a-metrics-token",
+		ReadinessTimeout:     2 * time.Second,
+		LogLevel:             "info",
+		Environment:          "production",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string // substring of the error, empty for none
+	}{
+		{"valid production", func(c *Config) {}, ""},
+		{"development allows defaults", func(c *Config) {
+			c.Environment = "development"
+			c.SessionSecret = defaultSessionSecret
+			c.PaymentProvider = "fake"
+			c.MetricsToken = ""
+			c.FirebaseProjectID = ""
+		}, ""},
+		{"port out of range", func(c *Config) { c.ServerPort = "70000" }, "SERVER_PORT"},
+		{"port not a number", func(c *Config) { c.ServerPort = "http" }, "SERVER_PORT"},
+		{"trusted proxy", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "proxy.local"} }, "proxy.local"},
+		{"SMTP port", func(c *Config) { c.SMTPHost = "smtp.example.com" }, "SMTP_PORT"},
+		{"negative rate", func(c *Config) { c.UserRateLimit = -1 }, "rate limits"},
+		{"negative cache size", func(c *Config) { c.AuthCacheSize = -1 }, "AUTH_CACHE_SIZE"},
+		{"negative refresh", func(c *Config) { c.MetricsRefreshInterval = -time.Second }, "METRICS_REFRESH_INTERVAL"},
+		{"readiness timeout", func(c *Config) { c.ReadinessTimeout = 0 }, "READINESS_TIMEOUT"},
+		{"unknown provider", func(c *Config) { c.PaymentProvider = "paypal" }, "PAYMENT_PROVIDER"},
+		{"stripe without key", func(c *Config) { c.PaymentAPIKey = "" }, "PAYMENT_API_KEY"},
+		{"log level", func(c *Config) { c.LogLevel = "verbose" }, "LOG_LEVEL"},
+		{"dev mode in production", func(c *Config) { c.DevMode = true }, "DEV_MODE"},
+		{"default session secret", func(c *Config) { c.SessionSecret = defaultSessionSecret }, "SESSION_SECRET"},
+		{"example session secret", func(c *Config) {
+			c.SessionSecret = "your-secure-session-secret-change-in-production"
+		}, "SESSION_SECRET"},
+		{"default webhook secret", func(c *Config) { c.PaymentWebhookSecret = defaultPaymentWebhookSecret }, "PAYMENT_WEBHOOK_SECRET"},
+		{"fake provider in production", func(c *Config) { c.PaymentProvider = "fake" }, "PAYMENT_PROVIDER"},
+		{"example project ID", func(c *Config) { c.FirebaseProjectID = "your-firebase-project-id" }, "FIREBASE_PROJECT_ID"},
+		{"no metrics token in production", func(c *Config) { c.MetricsToken = "" }, "METRICS_TOKEN"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := validConfig()
+	cfg.ServerPort = "0"
+	cfg.LogLevel = "loud"
+	cfg.MetricsToken = ""
+
+	err := cfg.Validate()
+	for _, want := range []string{"SERVER_PORT", "LOG_LEVEL", "METRICS_TOKEN"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate() = %v, want an error mentioning %s", err, want)
+		}
+	}
+}
+
+func TestMasked(t *testing.T) {
+	cfg := validConfig()
+	cfg.SMTPPassword = ""
+	cfg.TrustedProxies = []string{"10.0.0.1"}
+
+	m := cfg.Masked()
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"session secret", m.SessionSecret, masked},
+		{"payment API key", m.PaymentAPIKey, masked},
+		{"webhook secret", m.PaymentWebhookSecret, masked},
+		{"metrics token", m.MetricsToken, masked},
+		{"unset secret stays empty", m.SMTPPassword, ""},
+		{"non-secret kept", m.FirebaseProjectID, "itc-conference"},
+		{"original untouched", cfg.SessionSecret, "a-production-session-secret"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	m.TrustedProxies[0] = "changed"
+	if cfg.TrustedProxies[0] != "10.0.0.1" {
+		t.Error("Masked shares TrustedProxies with the original")
+	}
+
+	out, err := m.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "a-production-session-secret") {
+		t.Error("YAML of the masked config contains a secret")
+	}
+}
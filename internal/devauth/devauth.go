@@ -0,0 +1,254 @@
+// Package devauth stands in for Firebase Auth in offline development. It
+// mints and verifies locally signed ID tokens and keeps users in memory.
+package devauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"backend-ITC/internal/models"
+
+	"firebase.google.com/go/auth"
+)
+
+// TokenTTL is how long minted tokens are valid, as for Firebase ID tokens
+const TokenTTL = time.Hour
+
+// tokenPrefix marks dev tokens so they are never mistaken for Firebase ones
+const tokenPrefix = "dev."
+
+// issuer is the iss claim of dev tokens
+const issuer = "devauth"
+
+// Errors returned when verifying tokens and looking up users
+var (
+	ErrInvalidToken = errors.New("devauth: invalid token")
+	ErrExpiredToken = errors.New("devauth: token has expired")
+	ErrUserNotFound = errors.New("devauth: user not found")
+)
+
+// claims is the signed payload of a dev token
+type claims struct {
+	UID       string   `json:"uid"`
+	Email     string   `json:"email"`
+	Name      string   `json:"name"`
+	Roles     []string `json:"roles,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// Authenticator mints dev tokens and implements firebase.TokenVerifier and
+// firebase.UserDirectory. It is safe for concurrent use.
+type Authenticator struct {
+	secret string
+
+	mu    sync.Mutex
+	users map[string]*auth.UserRecord
+}
+
+// New creates an authenticator that signs tokens with secret
+func New(secret string) *Authenticator {
+	return &Authenticator{
+		secret: secret,
+		users:  make(map[string]*auth.UserRecord),
+	}
+}
+
+// Issue mints a token for uid, creating the user on first use. Email and
+// name default to values derived from uid. If roles are given they replace
+// the user's roles, otherwise the token carries the roles the user already
+// has, as a refreshed Firebase token would.
+func (a *Authenticator) Issue(uid, email, name string, roles []string) (string, time.Time, error) {
+	if uid == "" {
+		return "", time.Time{}, errors.New("devauth: uid is required")
+	}
+	for _, role := range roles {
+		if !models.IsValidRole(role) {
+			return "", time.Time{}, fmt.Errorf("devauth: unknown role %q", role)
+		}
+	}
+
+	a.mu.Lock()
+	user := a.upsert(uid, email, name)
+	if roles != nil {
+		setRoles(user, roles)
+	}
+	c := claims{
+		UID:   uid,
+		Email: user.Email,
+		Name:  user.DisplayName,
+		Roles: models.RolesFromClaims(user.CustomClaims),
+	}
+	a.mu.Unlock()
+
+	now := time.Now()
+	expiresAt := now.Add(TokenTTL)
+	c.IssuedAt = now.Unix()
+	c.ExpiresAt = expiresAt.Unix()
+
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("devauth: encode token: %w", err)
+	}
+
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return tokenPrefix + body + "." + a.signature(body), expiresAt, nil
+}
+
+// VerifyIDToken implements firebase.TokenVerifier. Users of valid tokens
+// are recreated if they are unknown, e.g. after a restart.
+func (a *Authenticator) VerifyIDToken(_ context.Context, idToken string) (*auth.Token, error) {
+	body, sig, ok := strings.Cut(strings.TrimPrefix(idToken, tokenPrefix), ".")
+	if !strings.HasPrefix(idToken, tokenPrefix) || !ok {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(sig), []byte(a.signature(body))) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var c claims
+	if err := json.Unmarshal(payload, &c); err != nil || c.UID == "" {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= c.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	a.mu.Lock()
+	a.upsert(c.UID, c.Email, c.Name)
+	a.mu.Unlock()
+
+	tokenClaims := map[string]interface{}{
+		"email": c.Email,
+		"name":  c.Name,
+	}
+	if len(c.Roles) > 0 {
+		tokenClaims[models.RolesClaim] = c.Roles
+	}
+
+	return &auth.Token{
+		AuthTime: c.IssuedAt,
+		Issuer:   issuer,
+		Expires:  c.ExpiresAt,
+		IssuedAt: c.IssuedAt,
+		Subject:  c.UID,
+		UID:      c.UID,
+		Firebase: auth.FirebaseInfo{SignInProvider: "custom"},
+		Claims:   tokenClaims,
+	}, nil
+}
+
+// GetUser implements firebase.UserDirectory
+func (a *Authenticator) GetUser(_ context.Context, uid string) (*auth.UserRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[uid]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return cloneUser(user), nil
+}
+
+// SetUserRoles implements firebase.UserDirectory. Tokens minted afterwards
+// carry the new roles.
+func (a *Authenticator) SetUserRoles(_ context.Context, uid string, roles []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+	setRoles(user, roles)
+	return nil
+}
+
+// RevokeRefreshTokens implements firebase.UserDirectory. Tokens minted
+// before the next second are revoked.
+func (a *Authenticator) RevokeRefreshTokens(_ context.Context, uid string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.TokensValidAfterMillis = (time.Now().Unix() + 1) * 1000
+	return nil
+}
+
+// upsert returns the user uid, creating it if needed. The caller must hold
+// a.mu.
+func (a *Authenticator) upsert(uid, email, name string) *auth.UserRecord {
+	if user, ok := a.users[uid]; ok {
+		return user
+	}
+
+	if email == "" {
+		email = uid + "@example.com"
+	}
+	if name == "" {
+		name = uid
+	}
+
+	now := time.Now().UnixMilli()
+	user := &auth.UserRecord{
+		UserInfo: &auth.UserInfo{
+			UID:         uid,
+			Email:       email,
+			DisplayName: name,
+			ProviderID:  "firebase",
+		},
+		CustomClaims:  make(map[string]interface{}),
+		EmailVerified: true,
+		UserMetadata:  &auth.UserMetadata{CreationTimestamp: now, LastLogInTimestamp: now},
+	}
+	a.users[uid] = user
+	return user
+}
+
+// signature is the unpadded base64url HMAC of a token body. The "devtoken:"
+// prefix keeps it distinct from other uses of the same secret.
+func (a *Authenticator) signature(body string) string {
+	mac := hmac.New(sha256.New, []byte(a.secret))
+	mac.Write([]byte("devtoken:" + body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// setRoles replaces the roles custom claim of user
+func setRoles(user *auth.UserRecord, roles []string) {
+	if len(roles) == 0 {
+		delete(user.CustomClaims, models.RolesClaim)
+		return
+	}
+	user.CustomClaims[models.RolesClaim] = append([]string(nil), roles...)
+}
+
+// cloneUser returns a copy of user that callers may modify
+func cloneUser(user *auth.UserRecord) *auth.UserRecord {
+	clone := *user
+	info := *user.UserInfo
+	clone.UserInfo = &info
+	clone.CustomClaims = make(map[string]interface{}, len(user.CustomClaims))
+	for k, v := range user.CustomClaims {
+		clone.CustomClaims[k] = v
+	}
+	if user.UserMetadata != nil {
+		metadata := *user.UserMetadata
+		clone.UserMetadata = &metadata
+	}
+	return &clone
+}
@@ -0,0 +1,44 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+)
+
+// CSVWriter writes rows as RFC 4180 CSV
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter creates a CSV writer on w
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// Write appends one row. Cells that a spreadsheet would evaluate as a
+// formula are prefixed with a quote so attendee input cannot run formulas.
+func (cw *CSVWriter) Write(record []string) error {
+	escaped := make([]string, len(record))
+	for i, value := range record {
+		escaped[i] = escapeFormula(value)
+	}
+	return cw.w.Write(escaped)
+}
+
+// Close flushes any buffered rows
+func (cw *CSVWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// escapeFormula neutralises values starting with a formula trigger
+func escapeFormula(value string) string {
+	if value == "" {
+		return value
+	}
+	switch value[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + value
+	}
+	return value
+}
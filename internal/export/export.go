@@ -0,0 +1,52 @@
+// Package export writes tabular data as CSV or XLSX spreadsheets. Rows are
+// written to the underlying writer as they arrive so exports of any size use
+// constant memory.
+package export
+
+import (
+	"errors"
+	"io"
+)
+
+// Supported export formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// ErrUnknownFormat is returned by NewWriter for unsupported formats
+var ErrUnknownFormat = errors.New("export: unknown format")
+
+// Writer writes rows of a single sheet
+type Writer interface {
+	// Write appends one row
+	Write(record []string) error
+	// Close flushes buffered data and finishes the document. It does not
+	// close the underlying io.Writer.
+	Close() error
+}
+
+// NewWriter returns a Writer producing the given format on w.
+// sheetName is used by formats that support named sheets.
+func NewWriter(format string, w io.Writer, sheetName string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatXLSX:
+		return NewXLSXWriter(w, sheetName)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// ContentType returns the MIME type of format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/octet-stream"
+	}
+}
@@ -0,0 +1,116 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Static parts of a single-sheet workbook. Cells are written as inline
+// strings, so no shared strings table or styles part is needed.
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+
+	xlsxWorkbookHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`
+	xlsxWorkbookFooter = `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	xlsxSheetHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetFooter = `</sheetData></worksheet>`
+)
+
+// XLSXWriter writes rows into a single-sheet Office Open XML workbook,
+// streaming the sheet into the zip archive as rows are written.
+type XLSXWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+}
+
+// NewXLSXWriter writes the workbook structure to w and returns a writer for
+// the rows of its only sheet
+func NewXLSXWriter(w io.Writer, sheetName string) (*XLSXWriter, error) {
+	zw := zip.NewWriter(w)
+
+	var name strings.Builder
+	if err := xml.EscapeText(&name, []byte(sheetName)); err != nil {
+		return nil, err
+	}
+
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbookHeader + name.String() + xlsxWorkbookFooter},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	// The sheet must be the last entry because it stays open while rows are written
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	sheet := bufio.NewWriter(f)
+	if _, err := sheet.WriteString(xlsxSheetHeader); err != nil {
+		return nil, err
+	}
+
+	return &XLSXWriter{zw: zw, sheet: sheet}, nil
+}
+
+// Write appends one row of text cells
+func (xw *XLSXWriter) Write(record []string) error {
+	if _, err := xw.sheet.WriteString("<row>"); err != nil {
+		return err
+	}
+	for _, value := range record {
+		if _, err := xw.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`); err != nil {
+			return err
+		}
+		if err := xml.EscapeText(xw.sheet, []byte(value)); err != nil {
+			return err
+		}
+		if _, err := xw.sheet.WriteString("</t></is></c>"); err != nil {
+			return err
+		}
+	}
+	_, err := xw.sheet.WriteString("</row>")
+	return err
+}
+
+// Close finishes the sheet and the zip archive
+func (xw *XLSXWriter) Close() error {
+	if _, err := xw.sheet.WriteString(xlsxSheetFooter); err != nil {
+		return err
+	}
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zw.Close()
+}
@@ -4,14 +4,46 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"time"
+
+	"backend-ITC/internal/models"
 
 	"cloud.google.com/go/firestore"
 	fb "firebase.google.com/go"
 	"firebase.google.com/go/auth"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Services reported to a CallObserver
+const (
+	ServiceAuth      = "auth"
+	ServiceFirestore = "firestore"
 )
 
+// CallObserver is told the duration and outcome of every call the client
+// makes to Firebase, e.g. to record metrics
+type CallObserver interface {
+	ObserveCall(service, operation string, d time.Duration, err error)
+}
+
+// TokenVerifier verifies ID tokens sent by clients
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error)
+}
+
+// UserDirectory looks up users and manages their roles and sessions
+type UserDirectory interface {
+	GetUser(ctx context.Context, uid string) (*auth.UserRecord, error)
+	SetUserRoles(ctx context.Context, uid string, roles []string) error
+	RevokeRefreshTokens(ctx context.Context, uid string) error
+}
+
 // Client is a thin abstraction over the Firebase Admin SDK components
 // required by the application. It exposes the Firebase Auth client and
 // Firestore client while managing their lifecycle.
@@ -21,6 +53,8 @@ type Client struct {
 	Auth      *auth.Client
 	Firestore *firestore.Client
 
+	observer CallObserver
+
 	closeOnce sync.Once
 	closeErr  error
 }
@@ -33,10 +67,18 @@ func Initialize(ctx context.Context, credentialsFile string, opts ...option.Clie
 		return nil, errors.New("firebase: context must not be nil")
 	}
 
+	c := &Client{}
+
 	if credentialsFile != "" {
 		opts = append(opts, option.WithCredentialsFile(credentialsFile))
 	}
 
+	// Time Firestore RPCs; the Auth client uses HTTP and ignores these
+	opts = append(opts,
+		option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(c.unaryInterceptor)),
+		option.WithGRPCDialOption(grpc.WithChainStreamInterceptor(c.streamInterceptor)),
+	)
+
 	app, err := fb.NewApp(ctx, nil, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("firebase: create app: %w", err)
@@ -52,11 +94,73 @@ func Initialize(ctx context.Context, credentialsFile string, opts ...option.Clie
 		return nil, fmt.Errorf("firebase: initialize firestore client: %w", err)
 	}
 
-	return &Client{
-		app:       app,
-		Auth:      authClient,
-		Firestore: firestoreClient,
-	}, nil
+	c.app = app
+	c.Auth = authClient
+	c.Firestore = firestoreClient
+
+	return c, nil
+}
+
+// SetObserver makes the client report every Firebase call to o. It must be
+// called before the client is used concurrently.
+func (c *Client) SetObserver(o CallObserver) {
+	c.observer = o
+}
+
+// observe reports a call that started at start to the observer, if any
+func (c *Client) observe(service, operation string, start time.Time, err error) {
+	if c.observer != nil {
+		c.observer.ObserveCall(service, operation, time.Since(start), err)
+	}
+}
+
+// unaryInterceptor times unary Firestore RPCs such as Commit
+func (c *Client) unaryInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	c.observe(ServiceFirestore, rpcName(method), start, err)
+	return err
+}
+
+// streamInterceptor times streaming Firestore RPCs such as RunQuery from
+// their start until the stream ends
+func (c *Client) streamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	start := time.Now()
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		c.observe(ServiceFirestore, rpcName(method), start, err)
+		return nil, err
+	}
+	return &observedStream{ClientStream: stream, client: c, operation: rpcName(method), start: start}, nil
+}
+
+// observedStream reports a stream once it ends
+type observedStream struct {
+	grpc.ClientStream
+	client    *Client
+	operation string
+	start     time.Time
+	once      sync.Once
+}
+
+// RecvMsg implements grpc.ClientStream
+func (s *observedStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(func() {
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			s.client.observe(ServiceFirestore, s.operation, s.start, err)
+		})
+	}
+	return err
+}
+
+// rpcName returns the method name of a full gRPC method such as
+// "/google.firestore.v1.Firestore/Commit"
+func rpcName(method string) string {
+	return method[strings.LastIndex(method, "/")+1:]
 }
 
 // VerifyIDToken verifies the provided Firebase ID token and returns the decoded token.
@@ -67,7 +171,11 @@ func (c *Client) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token
 	if idToken == "" {
 		return nil, errors.New("firebase: id token is required")
 	}
-	return c.Auth.VerifyIDToken(ctx, idToken)
+
+	start := time.Now()
+	token, err := c.Auth.VerifyIDToken(ctx, idToken)
+	c.observe(ServiceAuth, "VerifyIDToken", start, err)
+	return token, err
 }
 
 // GetUser retrieves the Firebase Auth user record for the given UID.
@@ -78,7 +186,88 @@ func (c *Client) GetUser(ctx context.Context, uid string) (*auth.UserRecord, err
 	if uid == "" {
 		return nil, errors.New("firebase: uid is required")
 	}
-	return c.Auth.GetUser(ctx, uid)
+
+	start := time.Now()
+	user, err := c.Auth.GetUser(ctx, uid)
+	c.observe(ServiceAuth, "GetUser", start, err)
+	return user, err
+}
+
+// SetUserRoles replaces the roles custom claim for the given UID while
+// preserving any other custom claims already set on the user.
+// Clients must refresh their ID token before the change is visible.
+func (c *Client) SetUserRoles(ctx context.Context, uid string, roles []string) error {
+	user, err := c.GetUser(ctx, uid)
+	if err != nil {
+		return err
+	}
+
+	claims := make(map[string]interface{}, len(user.CustomClaims)+1)
+	for k, v := range user.CustomClaims {
+		claims[k] = v
+	}
+
+	if len(roles) == 0 {
+		delete(claims, models.RolesClaim)
+	} else {
+		claims[models.RolesClaim] = roles
+	}
+
+	start := time.Now()
+	err = c.Auth.SetCustomUserClaims(ctx, uid, claims)
+	c.observe(ServiceAuth, "SetCustomUserClaims", start, err)
+	return err
+}
+
+// RevokeRefreshTokens signs the user out everywhere: their refresh tokens
+// stop working and TokensValidAfterMillis marks the ID tokens issued before
+// now as revoked.
+func (c *Client) RevokeRefreshTokens(ctx context.Context, uid string) error {
+	if c == nil || c.Auth == nil {
+		return errors.New("firebase: auth client is not initialized")
+	}
+	if uid == "" {
+		return errors.New("firebase: uid is required")
+	}
+
+	start := time.Now()
+	err := c.Auth.RevokeRefreshTokens(ctx, uid)
+	c.observe(ServiceAuth, "RevokeRefreshTokens", start, err)
+	return err
+}
+
+// pingID names the user and document read by the ping methods. Neither has
+// to exist; a not-found answer proves the service is reachable.
+const pingID = "readiness-probe"
+
+// PingFirestore checks that Firestore accepts the client's credentials by
+// reading a single document
+func (c *Client) PingFirestore(ctx context.Context) error {
+	if c == nil || c.Firestore == nil {
+		return errors.New("firebase: firestore client is not initialized")
+	}
+
+	_, err := c.Firestore.Collection("_health").Doc(pingID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil
+	}
+	return err
+}
+
+// PingAuth checks that Firebase Auth accepts the client's credentials by
+// looking up a user
+func (c *Client) PingAuth(ctx context.Context) error {
+	if c == nil || c.Auth == nil {
+		return errors.New("firebase: auth client is not initialized")
+	}
+
+	start := time.Now()
+	_, err := c.Auth.GetUser(ctx, pingID)
+	if auth.IsUserNotFound(err) {
+		err = nil
+	}
+	c.observe(ServiceAuth, "GetUser", start, err)
+	return err
 }
 
 // Close releases any resources held by the Firebase client.
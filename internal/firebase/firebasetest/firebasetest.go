@@ -0,0 +1,202 @@
+// Package firebasetest provides an in-memory fake of Firebase Auth for
+// tests of code that depends on firebase.TokenVerifier and
+// firebase.UserDirectory.
+package firebasetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"backend-ITC/internal/models"
+
+	"firebase.google.com/go/auth"
+)
+
+// Errors returned by the fake
+var (
+	ErrInvalidToken = errors.New("firebasetest: invalid token")
+	ErrExpiredToken = errors.New("firebasetest: token has expired")
+	ErrUserNotFound = errors.New("firebasetest: user not found")
+)
+
+// Auth is a fake Firebase Auth. Users are added with AddUser, which returns
+// an ID token the fake accepts. It is safe for concurrent use.
+type Auth struct {
+	mu     sync.Mutex
+	tokens map[string]*auth.Token
+	users  map[string]*auth.UserRecord
+	err    error
+	issued int
+}
+
+// New creates a fake without users
+func New() *Auth {
+	return &Auth{
+		tokens: make(map[string]*auth.Token),
+		users:  make(map[string]*auth.UserRecord),
+	}
+}
+
+// AddUser adds a user with the given roles and returns a valid ID token for
+// them. The token carries the roles as a custom claim, like a Firebase token
+// issued after the roles were set.
+func (a *Auth) AddUser(uid, email string, roles ...string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	claims := make(map[string]interface{})
+	tokenClaims := make(map[string]interface{})
+	if len(roles) > 0 {
+		claims[models.RolesClaim] = append([]string(nil), roles...)
+		tokenClaims[models.RolesClaim] = append([]string(nil), roles...)
+	}
+
+	a.users[uid] = &auth.UserRecord{
+		UserInfo: &auth.UserInfo{
+			UID:         uid,
+			Email:       email,
+			DisplayName: uid,
+			ProviderID:  "firebase",
+		},
+		CustomClaims:  claims,
+		EmailVerified: true,
+	}
+
+	return a.addToken(uid, tokenClaims, time.Now().Add(time.Hour))
+}
+
+// ExpiredToken returns a token for uid that expired a minute ago
+func (a *Auth) ExpiredToken(uid string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.addToken(uid, nil, time.Now().Add(-time.Minute))
+}
+
+// DeleteUser removes a user. Their tokens still verify, as Firebase tokens
+// do until they expire, but looking the user up fails.
+func (a *Auth) DeleteUser(uid string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.users, uid)
+}
+
+// SetError makes every call fail with err, simulating an outage. A nil err
+// restores normal operation.
+func (a *Auth) SetError(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.err = err
+}
+
+// Ping fails while an error is set, for use as a readiness check
+func (a *Auth) Ping(context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+// VerifyIDToken implements firebase.TokenVerifier
+func (a *Auth) VerifyIDToken(_ context.Context, idToken string) (*auth.Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.err != nil {
+		return nil, a.err
+	}
+	token, ok := a.tokens[idToken]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= token.Expires {
+		return nil, ErrExpiredToken
+	}
+
+	clone := *token
+	return &clone, nil
+}
+
+// GetUser implements firebase.UserDirectory
+func (a *Auth) GetUser(_ context.Context, uid string) (*auth.UserRecord, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.err != nil {
+		return nil, a.err
+	}
+	user, ok := a.users[uid]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	clone := *user
+	info := *user.UserInfo
+	clone.UserInfo = &info
+	clone.CustomClaims = make(map[string]interface{}, len(user.CustomClaims))
+	for k, v := range user.CustomClaims {
+		clone.CustomClaims[k] = v
+	}
+	return &clone, nil
+}
+
+// SetUserRoles implements firebase.UserDirectory. Existing tokens keep the
+// roles they were issued with.
+func (a *Auth) SetUserRoles(_ context.Context, uid string, roles []string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+	user, ok := a.users[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	if len(roles) == 0 {
+		delete(user.CustomClaims, models.RolesClaim)
+	} else {
+		user.CustomClaims[models.RolesClaim] = append([]string(nil), roles...)
+	}
+	return nil
+}
+
+// RevokeRefreshTokens implements firebase.UserDirectory. The revocation
+// time is rounded up to the next second, so every token issued so far is
+// revoked.
+func (a *Auth) RevokeRefreshTokens(_ context.Context, uid string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.err != nil {
+		return a.err
+	}
+	user, ok := a.users[uid]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	user.TokensValidAfterMillis = (time.Now().Unix() + 1) * 1000
+	return nil
+}
+
+// addToken registers a token for uid and returns it. The caller must hold
+// a.mu.
+func (a *Auth) addToken(uid string, claims map[string]interface{}, expires time.Time) string {
+	a.issued++
+	idToken := fmt.Sprintf("test-token-%d-%s", a.issued, uid)
+
+	now := time.Now().Unix()
+	a.tokens[idToken] = &auth.Token{
+		AuthTime: now,
+		Issuer:   "firebasetest",
+		Expires:  expires.Unix(),
+		IssuedAt: now,
+		Subject:  uid,
+		UID:      uid,
+		Claims:   claims,
+	}
+	return idToken
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"net/http"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/firebase"
+	"backend-ITC/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles administrative user management requests
+type AdminHandler struct {
+	directory firebase.UserDirectory
+	profiles  ProfileCache
+	auditLog  *audit.Logger
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(directory firebase.UserDirectory, profiles ProfileCache, auditLog *audit.Logger) *AdminHandler {
+	return &AdminHandler{
+		directory: directory,
+		profiles:  profiles,
+		auditLog:  auditLog,
+	}
+}
+
+// RolesResponse represents the response for role management operations
+type RolesResponse struct {
+	Success bool     `json:"success"`
+	Message string   `json:"message"`
+	UID     string   `json:"uid,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+}
+
+// GetUserRoles returns the roles currently granted to a user
+func (h *AdminHandler) GetUserRoles(c *gin.Context) {
+	uid := c.Param("uid")
+	ctx := requestContext(c)
+
+	userRecord, err := h.directory.GetUser(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, RolesResponse{
+			Success: false,
+			Message: "User not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, RolesResponse{
+		Success: true,
+		Message: "Roles retrieved successfully",
+		UID:     uid,
+		Roles:   models.RolesFromClaims(userRecord.CustomClaims),
+	})
+}
+
+// GrantRole adds a role to a user's custom claims
+func (h *AdminHandler) GrantRole(c *gin.Context) {
+	uid := c.Param("uid")
+
+	var input models.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, RolesResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	if !models.IsValidRole(input.Role) {
+		c.JSON(http.StatusBadRequest, RolesResponse{
+			Success: false,
+			Message: "Unknown role: " + input.Role,
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	userRecord, err := h.directory.GetUser(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, RolesResponse{
+			Success: false,
+			Message: "User not found",
+		})
+		return
+	}
+
+	previous := models.RolesFromClaims(userRecord.CustomClaims)
+	roles := previous
+	for _, r := range roles {
+		if r == input.Role {
+			c.JSON(http.StatusOK, RolesResponse{
+				Success: true,
+				Message: "User already has this role",
+				UID:     uid,
+				Roles:   roles,
+			})
+			return
+		}
+	}
+	roles = append(roles[:len(roles):len(roles)], input.Role)
+
+	if err := h.directory.SetUserRoles(ctx, uid, roles); err != nil {
+		c.JSON(http.StatusInternalServerError, RolesResponse{
+			Success: false,
+			Message: "Failed to grant role: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "user.role_grant", audit.TargetUser, uid, gin.H{"roles": previous}, gin.H{"roles": roles})
+
+	c.JSON(http.StatusOK, RolesResponse{
+		Success: true,
+		Message: "Role granted successfully. The user must refresh their ID token to use it.",
+		UID:     uid,
+		Roles:   roles,
+	})
+}
+
+// RevokeRole removes a role from a user's custom claims
+func (h *AdminHandler) RevokeRole(c *gin.Context) {
+	uid := c.Param("uid")
+	role := c.Param("role")
+
+	if !models.IsValidRole(role) {
+		c.JSON(http.StatusBadRequest, RolesResponse{
+			Success: false,
+			Message: "Unknown role: " + role,
+		})
+		return
+	}
+
+	// Prevent admins from locking themselves out
+	if uid == c.GetString("uid") && role == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, RolesResponse{
+			Success: false,
+			Message: "You cannot revoke your own admin role",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	userRecord, err := h.directory.GetUser(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, RolesResponse{
+			Success: false,
+			Message: "User not found",
+		})
+		return
+	}
+
+	previous := models.RolesFromClaims(userRecord.CustomClaims)
+	var roles []string
+	for _, r := range previous {
+		if r != role {
+			roles = append(roles, r)
+		}
+	}
+	if len(roles) == len(previous) {
+		c.JSON(http.StatusOK, RolesResponse{
+			Success: true,
+			Message: "User does not have this role",
+			UID:     uid,
+			Roles:   previous,
+		})
+		return
+	}
+
+	if err := h.directory.SetUserRoles(ctx, uid, roles); err != nil {
+		c.JSON(http.StatusInternalServerError, RolesResponse{
+			Success: false,
+			Message: "Failed to revoke role: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "user.role_revoke", audit.TargetUser, uid, gin.H{"roles": previous}, gin.H{"roles": roles})
+
+	// ID tokens keep the revoked role until they expire, so sign the user out
+	if err := h.directory.RevokeRefreshTokens(ctx, uid); err != nil {
+		c.JSON(http.StatusInternalServerError, RolesResponse{
+			Success: false,
+			Message: "Role revoked, but failed to sign the user out; it takes effect when their ID token expires within an hour: " + err.Error(),
+			UID:     uid,
+			Roles:   roles,
+		})
+		return
+	}
+	h.profiles.InvalidateUser(uid)
+
+	c.JSON(http.StatusOK, RolesResponse{
+		Success: true,
+		Message: "Role revoked successfully. The user has been signed out and must sign in again.",
+		UID:     uid,
+		Roles:   roles,
+	})
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAuditPageSize caps the limit query parameter of the audit log
+const maxAuditPageSize = 500
+
+// AuditHandler serves the audit log to admins
+type AuditHandler struct {
+	entries repository.AuditRepository
+}
+
+// NewAuditHandler creates a new audit handler
+func NewAuditHandler(entries repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		entries: entries,
+	}
+}
+
+// AuditResponse represents the response for audit log queries
+type AuditResponse struct {
+	Success    bool                `json:"success"`
+	Message    string              `json:"message"`
+	Entries    []models.AuditEntry `json:"entries"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+}
+
+// ListAuditEntries returns audit entries, newest first (admins only)
+// Optional query parameters:
+//   - actor: UID of the user who made the change
+//   - targetType, target: kind and ID of the changed object
+//   - from, to: time range as RFC 3339 timestamps or YYYY-MM-DD dates (UTC).
+//     A date as to includes that whole day; a timestamp is exclusive
+//   - limit: number of entries (default 50, max 500)
+//   - cursor: nextCursor of the previous page
+func (h *AuditHandler) ListAuditEntries(c *gin.Context) {
+	filter := repository.AuditFilter{
+		ActorUID:   c.Query("actor"),
+		TargetType: c.Query("targetType"),
+		TargetID:   c.Query("target"),
+		Cursor:     c.Query("cursor"),
+	}
+
+	var ok bool
+	if filter.From, ok = parseAuditTime(c, "from", false); !ok {
+		return
+	}
+	if filter.Before, ok = parseAuditTime(c, "to", true); !ok {
+		return
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxAuditPageSize {
+			c.JSON(http.StatusBadRequest, AuditResponse{
+				Success: false,
+				Message: "Invalid limit: expected 1 to " + strconv.Itoa(maxAuditPageSize),
+			})
+			return
+		}
+		filter.Limit = n
+	}
+
+	ctx := requestContext(c)
+
+	page, err := h.entries.List(ctx, filter)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, AuditResponse{
+			Success: false,
+			Message: "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, AuditResponse{
+			Success: false,
+			Message: "Failed to retrieve audit log: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AuditResponse{
+		Success:    true,
+		Message:    "Audit log retrieved successfully",
+		Entries:    page.Entries,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// parseAuditTime reads an RFC 3339 timestamp or a date from the query
+// parameter param. Dates used as an upper bound include the whole day. On
+// invalid input it writes the error response and returns false.
+func parseAuditTime(c *gin.Context, param string, upper bool) (time.Time, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return time.Time{}, true
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, AuditResponse{
+			Success: false,
+			Message: "Invalid " + param + ": expected an RFC 3339 timestamp or YYYY-MM-DD",
+		})
+		return time.Time{}, false
+	}
+
+	if upper {
+		return day.AddDate(0, 0, 1), true
+	}
+	return day, true
+}
@@ -2,25 +2,38 @@ package handlers
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
 	"backend-ITC/internal/firebase"
+	"backend-ITC/internal/logging"
 	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
+// ProfileCache drops cached copies of a user's profile after it is written
+type ProfileCache interface {
+	InvalidateUser(uid string)
+}
+
 // AuthHandler handles authentication related requests
 type AuthHandler struct {
-	firebaseClient *firebase.Client
+	verifier  firebase.TokenVerifier
+	directory firebase.UserDirectory
+	users     repository.UserRepository
+	profiles  ProfileCache
 }
 
 // NewAuthHandler creates a new auth handler
-func NewAuthHandler(fc *firebase.Client) *AuthHandler {
+func NewAuthHandler(verifier firebase.TokenVerifier, directory firebase.UserDirectory, users repository.UserRepository, profiles ProfileCache) *AuthHandler {
 	return &AuthHandler{
-		firebaseClient: fc,
+		verifier:  verifier,
+		directory: directory,
+		users:     users,
+		profiles:  profiles,
 	}
 }
 
@@ -49,10 +62,10 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := requestContext(c)
 
 	// Verify the Firebase ID token
-	token, err := h.firebaseClient.VerifyIDToken(ctx, req.IDToken)
+	token, err := h.verifier.VerifyIDToken(ctx, req.IDToken)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, AuthResponse{
 			Success: false,
@@ -61,9 +74,12 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 		return
 	}
 
+	ctx = logging.WithUID(ctx, token.UID)
+
 	// Get user info from Firebase Auth
-	userRecord, err := h.firebaseClient.GetUser(ctx, token.UID)
+	userRecord, err := h.directory.GetUser(ctx, token.UID)
 	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user from Firebase Auth", "error", err)
 		c.JSON(http.StatusInternalServerError, AuthResponse{
 			Success: false,
 			Message: "Failed to retrieve user information",
@@ -71,7 +87,16 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 		return
 	}
 
-	// Create or update user in Firestore
+	// Tokens issued before a revocation, e.g. of a role, are refused
+	if token.IssuedAt*1000 < userRecord.TokensValidAfterMillis {
+		c.JSON(http.StatusUnauthorized, AuthResponse{
+			Success: false,
+			Message: "Token has been revoked, please sign in again",
+		})
+		return
+	}
+
+	// Create or update user profile
 	user := &models.User{
 		UID:         userRecord.UID,
 		Email:       userRecord.Email,
@@ -81,11 +106,12 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 		LastLoginAt: time.Now(),
 	}
 
-	// Save user to Firestore
-	err = h.saveUserToFirestore(ctx, user)
+	// Save user profile
+	err = h.saveUser(ctx, user)
 	if err != nil {
 		// Log error but don't fail the login
 		// The user is authenticated, we just couldn't save their profile
+		slog.ErrorContext(ctx, "Failed to save user profile on login", "error", err)
 		c.JSON(http.StatusOK, AuthResponse{
 			Success: true,
 			Message: "Logged in successfully (profile save pending)",
@@ -103,64 +129,10 @@ func (h *AuthHandler) GoogleLogin(c *gin.Context) {
 	})
 }
 
-// VerifyToken verifies a Firebase ID token from the Authorization header
+// VerifyToken reports whether the bearer token is valid and returns its
+// user. It runs after RequireAuth, which rejects invalid and revoked tokens.
 func (h *AuthHandler) VerifyToken(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	if authHeader == "" {
-		c.JSON(http.StatusUnauthorized, AuthResponse{
-			Success: false,
-			Message: "Authorization header is required",
-		})
-		return
-	}
-
-	// Extract token from "Bearer <token>"
-	tokenParts := strings.Split(authHeader, " ")
-	if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-		c.JSON(http.StatusUnauthorized, AuthResponse{
-			Success: false,
-			Message: "Invalid authorization header format",
-		})
-		return
-	}
-
-	idToken := tokenParts[1]
-	ctx := context.Background()
-
-	token, err := h.firebaseClient.VerifyIDToken(ctx, idToken)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, AuthResponse{
-			Success: false,
-			Message: "Invalid or expired token",
-		})
-		return
-	}
-
-	// Get user from Firestore
-	user, err := h.getUserFromFirestore(ctx, token.UID)
-	if err != nil {
-		// User not in Firestore, get from Auth
-		userRecord, err := h.firebaseClient.GetUser(ctx, token.UID)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, AuthResponse{
-				Success: false,
-				Message: "Failed to retrieve user information",
-			})
-			return
-		}
-		user = &models.User{
-			UID:         userRecord.UID,
-			Email:       userRecord.Email,
-			DisplayName: userRecord.DisplayName,
-			PhotoURL:    userRecord.PhotoURL,
-		}
-	}
-
-	c.JSON(http.StatusOK, AuthResponse{
-		Success: true,
-		Message: "Token is valid",
-		User:    user,
-	})
+	respondCurrentUser(c, "Token is valid")
 }
 
 // Logout handles user logout
@@ -175,7 +147,11 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 
 // GetCurrentUser returns the current authenticated user's profile
 func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
-	// Get user from context (set by auth middleware)
+	respondCurrentUser(c, "User retrieved successfully")
+}
+
+// respondCurrentUser writes the user set by the auth middleware
+func respondCurrentUser(c *gin.Context, message string) {
 	userVal, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, AuthResponse{
@@ -196,45 +172,28 @@ func (h *AuthHandler) GetCurrentUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, AuthResponse{
 		Success: true,
-		Message: "User retrieved successfully",
+		Message: message,
 		User:    user,
 	})
 }
 
-// saveUserToFirestore saves or updates a user in Firestore
-func (h *AuthHandler) saveUserToFirestore(ctx context.Context, user *models.User) error {
-	// Check if user exists
-	docRef := h.firebaseClient.Firestore.Collection("users").Doc(user.UID)
-	doc, err := docRef.Get(ctx)
-
-	if err != nil || !doc.Exists() {
+// saveUser saves or updates a user profile, preserving its creation time
+func (h *AuthHandler) saveUser(ctx context.Context, user *models.User) error {
+	existingUser, err := h.users.Get(ctx, user.UID)
+	if err != nil {
 		// New user - set created timestamp
 		user.CreatedAt = time.Now()
 	} else {
 		// Existing user - preserve created timestamp
-		var existingUser models.User
-		if err := doc.DataTo(&existingUser); err == nil {
-			user.CreatedAt = existingUser.CreatedAt
-		}
+		user.CreatedAt = existingUser.CreatedAt
 	}
 
 	user.UpdatedAt = time.Now()
 
-	_, err = docRef.Set(ctx, user)
-	return err
-}
-
-// getUserFromFirestore retrieves a user from Firestore by UID
-func (h *AuthHandler) getUserFromFirestore(ctx context.Context, uid string) (*models.User, error) {
-	doc, err := h.firebaseClient.Firestore.Collection("users").Doc(uid).Get(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	var user models.User
-	if err := doc.DataTo(&user); err != nil {
-		return nil, err
+	if err := h.users.Save(ctx, user); err != nil {
+		return err
 	}
 
-	return &user, nil
+	h.profiles.InvalidateUser(user.UID)
+	return nil
 }
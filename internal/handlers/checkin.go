@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
+	"backend-ITC/internal/ticket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckInHandler handles attendee tickets and on-site check-in
+type CheckInHandler struct {
+	registrations repository.RegistrationRepository
+	secret        string
+	auditLog      *audit.Logger
+}
+
+// NewCheckInHandler creates a new check-in handler. Tickets are signed with secret.
+func NewCheckInHandler(registrations repository.RegistrationRepository, secret string, auditLog *audit.Logger) *CheckInHandler {
+	return &CheckInHandler{
+		registrations: registrations,
+		secret:        secret,
+		auditLog:      auditLog,
+	}
+}
+
+// CheckInResponse represents the response for ticket and check-in operations
+type CheckInResponse struct {
+	Success      bool                 `json:"success"`
+	Message      string               `json:"message"`
+	Token        string               `json:"token,omitempty"`
+	Registration *models.Registration `json:"registration,omitempty"`
+}
+
+// GetMyTicket returns the signed ticket for the current user's paid
+// registration, as JSON or as a PNG QR code with ?format=png
+func (h *CheckInHandler) GetMyTicket(c *gin.Context) {
+	uid := c.GetString("uid")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, CheckInResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	registration, err := h.registrations.GetByUserID(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, CheckInResponse{
+			Success: false,
+			Message: "Registration not found",
+		})
+		return
+	}
+
+	if registration.PaymentStatus != models.PaymentStatusCompleted {
+		c.JSON(http.StatusPaymentRequired, CheckInResponse{
+			Success: false,
+			Message: "Tickets are issued once payment is completed",
+		})
+		return
+	}
+
+	token := ticket.Sign(h.secret, registration.ID)
+
+	if c.Query("format") == "png" {
+		png, err := ticket.QRCode(token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, CheckInResponse{
+				Success: false,
+				Message: "Failed to generate QR code: " + err.Error(),
+			})
+			return
+		}
+		c.Data(http.StatusOK, "image/png", png)
+		return
+	}
+
+	c.JSON(http.StatusOK, CheckInResponse{
+		Success:      true,
+		Message:      "Ticket retrieved successfully",
+		Token:        token,
+		Registration: registration,
+	})
+}
+
+// Scan verifies a scanned ticket and checks the attendee in (check-in staff,
+// organizers and admins)
+func (h *CheckInHandler) Scan(c *gin.Context) {
+	var input models.CheckInInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, CheckInResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	registrationID, err := ticket.Verify(h.secret, input.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, CheckInResponse{
+			Success: false,
+			Message: "Invalid ticket",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	registration, err := h.registrations.Get(ctx, registrationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, CheckInResponse{
+			Success: false,
+			Message: "Registration not found",
+		})
+		return
+	}
+
+	// Refunded or cancelled payments invalidate previously issued tickets
+	if registration.PaymentStatus != models.PaymentStatusCompleted {
+		c.JSON(http.StatusPaymentRequired, CheckInResponse{
+			Success:      false,
+			Message:      "Registration is not paid (status: " + registration.PaymentStatus + ")",
+			Registration: registration,
+		})
+		return
+	}
+
+	before := *registration
+	registration, err = h.registrations.CheckIn(ctx, registrationID, c.GetString("uid"), time.Now())
+	if errors.Is(err, repository.ErrAlreadyCheckedIn) {
+		c.JSON(http.StatusConflict, CheckInResponse{
+			Success:      false,
+			Message:      "Already checked in at " + registration.CheckedInAt.Format(time.RFC3339),
+			Registration: registration,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, CheckInResponse{
+			Success: false,
+			Message: "Failed to check in: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "registration.checkin", audit.TargetRegistration, registration.ID, &before, registration)
+
+	c.JSON(http.StatusOK, CheckInResponse{
+		Success:      true,
+		Message:      "Checked in successfully",
+		Registration: registration,
+	})
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestContext returns the context for storage calls of a request. It
+// carries the request ID and UID for logging but is not cancelled when the
+// client disconnects, so a change that has started is always completed.
+func requestContext(c *gin.Context) context.Context {
+	return context.WithoutCancel(c.Request.Context())
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"backend-ITC/internal/devauth"
+	"backend-ITC/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DevHandler serves the helpers of offline development mode
+type DevHandler struct {
+	tokens *devauth.Authenticator
+}
+
+// NewDevHandler creates a new dev handler that mints tokens with tokens
+func NewDevHandler(tokens *devauth.Authenticator) *DevHandler {
+	return &DevHandler{
+		tokens: tokens,
+	}
+}
+
+// DevTokenResponse represents the response for minted dev tokens
+type DevTokenResponse struct {
+	Success   bool       `json:"success"`
+	Message   string     `json:"message"`
+	IDToken   string     `json:"idToken,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// IssueToken mints a dev ID token to use in place of a Firebase one
+// Query parameters:
+//   - uid: user ID (required)
+//   - role: role to grant; may be repeated. Given roles replace the user's
+//     roles, an empty role clears them, and without any the user keeps theirs
+//   - email, name: profile of a new user, derived from uid when omitted
+func (h *DevHandler) IssueToken(c *gin.Context) {
+	uid := c.Query("uid")
+	if uid == "" {
+		c.JSON(http.StatusBadRequest, DevTokenResponse{
+			Success: false,
+			Message: "Query parameter uid is required",
+		})
+		return
+	}
+
+	var roles []string
+	if values, ok := c.GetQueryArray("role"); ok {
+		roles = make([]string, 0, len(values))
+		for _, role := range values {
+			if role == "" {
+				continue
+			}
+			if !models.IsValidRole(role) {
+				c.JSON(http.StatusBadRequest, DevTokenResponse{
+					Success: false,
+					Message: "Unknown role: " + role,
+				})
+				return
+			}
+			roles = append(roles, role)
+		}
+	}
+
+	idToken, expiresAt, err := h.tokens.Issue(uid, c.Query("email"), c.Query("name"), roles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, DevTokenResponse{
+			Success: false,
+			Message: "Failed to issue token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DevTokenResponse{
+		Success:   true,
+		Message:   "Token issued successfully",
+		IDToken:   idToken,
+		ExpiresAt: &expiresAt,
+	})
+}
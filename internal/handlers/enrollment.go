@@ -0,0 +1,320 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/notify"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnrollmentHandler handles session enrollment requests
+type EnrollmentHandler struct {
+	enrollments   repository.EnrollmentRepository
+	registrations repository.RegistrationRepository
+	sessions      repository.SessionRepository
+	notifier      notify.Notifier
+	auditLog      *audit.Logger
+}
+
+// NewEnrollmentHandler creates a new enrollment handler
+func NewEnrollmentHandler(enrollments repository.EnrollmentRepository, registrations repository.RegistrationRepository, sessions repository.SessionRepository, notifier notify.Notifier, auditLog *audit.Logger) *EnrollmentHandler {
+	return &EnrollmentHandler{
+		enrollments:   enrollments,
+		registrations: registrations,
+		sessions:      sessions,
+		notifier:      notifier,
+		auditLog:      auditLog,
+	}
+}
+
+// EnrollmentResponse represents the response for enrollment operations
+type EnrollmentResponse struct {
+	Success     bool                `json:"success"`
+	Message     string              `json:"message"`
+	Enrollment  *models.Enrollment  `json:"enrollment,omitempty"`
+	Enrollments []models.Enrollment `json:"enrollments,omitempty"`
+	Waitlist    []models.Enrollment `json:"waitlist,omitempty"`
+	Session     *models.Session     `json:"session,omitempty"`
+	Position    int                 `json:"position,omitempty"`
+}
+
+// Enroll reserves a seat in a session for the current user
+func (h *EnrollmentHandler) Enroll(c *gin.Context) {
+	userVal, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, EnrollmentResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	user, ok := userVal.(*models.User)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, EnrollmentResponse{
+			Success: false,
+			Message: "Failed to retrieve user information",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	// Only registered attendees can enroll in sessions
+	registration, err := h.registrations.GetByUserID(ctx, user.UID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, EnrollmentResponse{
+			Success: false,
+			Message: "A registration is required before enrolling in sessions",
+		})
+		return
+	}
+
+	enrollment := &models.Enrollment{
+		SessionID:      c.Param("id"),
+		UserID:         user.UID,
+		RegistrationID: registration.ID,
+		FirstName:      registration.FirstName,
+		LastName:       registration.LastName,
+		Email:          registration.Email,
+		CreatedAt:      time.Now(),
+	}
+
+	err = h.enrollments.Enroll(ctx, enrollment)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		c.JSON(http.StatusNotFound, EnrollmentResponse{
+			Success: false,
+			Message: "Session not found",
+		})
+		return
+	case errors.Is(err, repository.ErrAlreadyEnrolled):
+		c.JSON(http.StatusConflict, EnrollmentResponse{
+			Success: false,
+			Message: "Already enrolled or waitlisted in this session",
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, EnrollmentResponse{
+			Success: false,
+			Message: "Failed to enroll: " + err.Error(),
+		})
+		return
+	}
+
+	// Enrollments are audited against their session so its history reads in one place
+	h.auditLog.Record(c, "enrollment.create", audit.TargetSession, enrollment.SessionID, nil, enrollment)
+
+	if enrollment.IsWaitlisted() {
+		position, _ := h.enrollments.WaitlistPosition(ctx, enrollment.SessionID, user.UID)
+		c.JSON(http.StatusAccepted, EnrollmentResponse{
+			Success:    true,
+			Message:    "Session is full. You have been added to the waitlist",
+			Enrollment: enrollment,
+			Position:   position,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, EnrollmentResponse{
+		Success:    true,
+		Message:    "Enrolled successfully",
+		Enrollment: enrollment,
+	})
+}
+
+// Drop releases the current user's seat in a session, or removes them from
+// its waitlist. Freed seats are given to the head of the waitlist.
+func (h *EnrollmentHandler) Drop(c *gin.Context) {
+	uid := c.GetString("uid")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, EnrollmentResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	dropped, promoted, err := h.enrollments.Drop(ctx, c.Param("id"), uid)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, EnrollmentResponse{
+			Success: false,
+			Message: "Enrollment not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, EnrollmentResponse{
+			Success: false,
+			Message: "Failed to drop session: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "enrollment.delete", audit.TargetSession, dropped.SessionID, dropped, nil)
+	recordPromotions(c, h.auditLog, promoted)
+	h.notifyPromoted(ctx, dropped.SessionID, promoted)
+
+	c.JSON(http.StatusOK, EnrollmentResponse{
+		Success: true,
+		Message: "Session dropped successfully",
+	})
+}
+
+// GetWaitlistPosition returns the current user's position on a session's waitlist
+func (h *EnrollmentHandler) GetWaitlistPosition(c *gin.Context) {
+	uid := c.GetString("uid")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, EnrollmentResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	position, err := h.enrollments.WaitlistPosition(ctx, c.Param("id"), uid)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, EnrollmentResponse{
+			Success: false,
+			Message: "You are not on the waitlist for this session",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, EnrollmentResponse{
+			Success: false,
+			Message: "Failed to retrieve waitlist position: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, EnrollmentResponse{
+		Success:  true,
+		Message:  "Waitlist position retrieved successfully",
+		Position: position,
+	})
+}
+
+// GetMyEnrollments returns the current user's enrollments
+func (h *EnrollmentHandler) GetMyEnrollments(c *gin.Context) {
+	uid := c.GetString("uid")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, EnrollmentResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	enrollments, err := h.enrollments.ListByUser(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, EnrollmentResponse{
+			Success: false,
+			Message: "Failed to retrieve enrollments: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, EnrollmentResponse{
+		Success:     true,
+		Message:     "Enrollments retrieved successfully",
+		Enrollments: enrollments,
+	})
+}
+
+// GetSessionRoster returns every attendee enrolled in a session along with
+// its waitlist (admins and organizers only)
+func (h *EnrollmentHandler) GetSessionRoster(c *gin.Context) {
+	ctx := requestContext(c)
+
+	session, err := h.sessions.Get(ctx, c.Param("id"))
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, EnrollmentResponse{
+			Success: false,
+			Message: "Session not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, EnrollmentResponse{
+			Success: false,
+			Message: "Failed to retrieve session: " + err.Error(),
+		})
+		return
+	}
+
+	entries, err := h.enrollments.ListBySession(ctx, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, EnrollmentResponse{
+			Success: false,
+			Message: "Failed to retrieve roster: " + err.Error(),
+		})
+		return
+	}
+
+	var enrollments, waitlist []models.Enrollment
+	for _, e := range entries {
+		if e.IsWaitlisted() {
+			waitlist = append(waitlist, e)
+		} else {
+			enrollments = append(enrollments, e)
+		}
+	}
+
+	c.JSON(http.StatusOK, EnrollmentResponse{
+		Success:     true,
+		Message:     "Roster retrieved successfully",
+		Session:     session,
+		Enrollments: enrollments,
+		Waitlist:    waitlist,
+	})
+}
+
+// notifyPromoted loads the session and notifies attendees promoted from its waitlist
+func (h *EnrollmentHandler) notifyPromoted(ctx context.Context, sessionID string, promoted []models.Enrollment) {
+	if len(promoted) == 0 {
+		return
+	}
+
+	session, err := h.sessions.Get(ctx, sessionID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load session for waitlist notifications", "session_id", sessionID, "error", err)
+		return
+	}
+
+	notifyPromoted(ctx, h.notifier, session, promoted)
+}
+
+// recordPromotions audits the move of each promoted attendee off the waitlist
+func recordPromotions(c *gin.Context, auditLog *audit.Logger, promoted []models.Enrollment) {
+	for i := range promoted {
+		before := promoted[i]
+		before.Status = models.EnrollmentStatusWaitlisted
+		before.PromotedAt = time.Time{}
+		auditLog.Record(c, "enrollment.promote", audit.TargetSession, promoted[i].SessionID, &before, &promoted[i])
+	}
+}
+
+// notifyPromoted tells attendees they were promoted from the waitlist.
+// Notification failures are logged but do not fail the request.
+func notifyPromoted(ctx context.Context, notifier notify.Notifier, session *models.Session, promoted []models.Enrollment) {
+	for i := range promoted {
+		if err := notifier.WaitlistPromoted(ctx, &promoted[i], session); err != nil {
+			slog.ErrorContext(ctx, "Failed to notify waitlist promotion", "user_id", promoted[i].UserID, "error", err)
+		}
+	}
+}
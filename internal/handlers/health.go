@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"backend-ITC/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	checker *health.Checker
+}
+
+// NewHealthHandler creates a new health handler that checks dependencies
+// with checker
+func NewHealthHandler(checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		checker: checker,
+	}
+}
+
+// LivenessResponse is the body of the liveness probe
+type LivenessResponse struct {
+	Status string `json:"status"`
+}
+
+// Livez reports that the process is serving requests. It does not check
+// dependencies, so an outage of Firebase never gets the server restarted.
+func (h *HealthHandler) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, LivenessResponse{Status: health.StatusUp})
+}
+
+// Readyz checks every dependency and responds 503 if one is down, so that
+// traffic is routed elsewhere until it recovers
+func (h *HealthHandler) Readyz(c *gin.Context) {
+	report := h.checker.Run(c.Request.Context())
+
+	if !report.Up() {
+		for name, result := range report.Checks {
+			if result.Status != health.StatusUp {
+				slog.WarnContext(c.Request.Context(), "Readiness check failed",
+					"check", name,
+					"latency_ms", result.LatencyMs,
+					"error", result.Error,
+				)
+			}
+		}
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
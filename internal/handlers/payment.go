@@ -0,0 +1,392 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/notify"
+	"backend-ITC/internal/payment"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentHandler handles checkout and payment webhook requests
+type PaymentHandler struct {
+	registrations repository.RegistrationRepository
+	ticketTypes   repository.TicketTypeRepository
+	provider      payment.Provider
+	notifier      notify.Notifier
+	auditLog      *audit.Logger
+	frontendURL   string
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(registrations repository.RegistrationRepository, ticketTypes repository.TicketTypeRepository, provider payment.Provider, notifier notify.Notifier, auditLog *audit.Logger, frontendURL string) *PaymentHandler {
+	return &PaymentHandler{
+		registrations: registrations,
+		ticketTypes:   ticketTypes,
+		provider:      provider,
+		notifier:      notifier,
+		auditLog:      auditLog,
+		frontendURL:   strings.TrimSuffix(frontendURL, "/"),
+	}
+}
+
+// PaymentResponse represents the response for payment operations
+type PaymentResponse struct {
+	Success      bool                     `json:"success"`
+	Message      string                   `json:"message"`
+	Registration *models.Registration     `json:"registration,omitempty"`
+	Checkout     *payment.CheckoutSession `json:"checkout,omitempty"`
+}
+
+// SimulatePaymentRequest represents the request body for simulated payments
+type SimulatePaymentRequest struct {
+	Type string `json:"type" binding:"required"` // payment.succeeded, payment.failed, payment.refunded
+}
+
+// CreateCheckout starts a payment for the current user's registration
+func (h *PaymentHandler) CreateCheckout(c *gin.Context) {
+	uid := c.GetString("uid")
+	if uid == "" {
+		c.JSON(http.StatusUnauthorized, PaymentResponse{
+			Success: false,
+			Message: "User not authenticated",
+		})
+		return
+	}
+
+	ctx := requestContext(c)
+
+	registration, err := h.registrations.GetByUserID(ctx, uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, PaymentResponse{
+			Success: false,
+			Message: "Registration not found",
+		})
+		return
+	}
+
+	if registration.PaymentStatus == models.PaymentStatusCompleted {
+		c.JSON(http.StatusConflict, PaymentResponse{
+			Success:      false,
+			Message:      "Registration is already paid",
+			Registration: registration,
+		})
+		return
+	}
+
+	ticketType, err := h.ticketTypes.Get(ctx, registration.TicketType)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, PaymentResponse{
+			Success: false,
+			Message: "Failed to retrieve ticket type for registration",
+		})
+		return
+	}
+
+	// Only the latest checkout may be paid, so the previous one is closed
+	// first. If it has just been paid, its webhook is on the way.
+	if registration.PaymentStatus == models.PaymentStatusPending && registration.PaymentRef != "" {
+		err := h.provider.ExpireCheckoutSession(ctx, registration.PaymentRef)
+		if errors.Is(err, payment.ErrCheckoutCompleted) {
+			c.JSON(http.StatusConflict, PaymentResponse{
+				Success:      false,
+				Message:      "Payment is being confirmed, please wait",
+				Registration: registration,
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, PaymentResponse{
+				Success: false,
+				Message: "Failed to expire previous checkout session: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	before := *registration
+	update := repository.PaymentUpdate{
+		FromStatus: registration.PaymentStatus,
+		FromRef:    registration.PaymentRef,
+		Ref:        registration.PaymentRef,
+		AmountDue:  ticketType.Price - registration.Discount,
+		Currency:   ticketType.Currency,
+		At:         time.Now(),
+	}
+	if update.AmountDue < 0 {
+		update.AmountDue = 0
+	}
+
+	// Free tickets need no checkout
+	if update.AmountDue == 0 {
+		update.Status = models.PaymentStatusCompleted
+		registration, ok := h.setPaymentStatus(c, registration.ID, update)
+		if !ok {
+			return
+		}
+		h.auditLog.Record(c, "registration.checkout", audit.TargetRegistration, registration.ID, &before, registration)
+		h.sendReceipt(ctx, registration)
+
+		c.JSON(http.StatusOK, PaymentResponse{
+			Success:      true,
+			Message:      "No payment required",
+			Registration: registration,
+		})
+		return
+	}
+
+	checkout, err := h.provider.CreateCheckoutSession(ctx, payment.CheckoutRequest{
+		RegistrationID: registration.ID,
+		Amount:         update.AmountDue,
+		Currency:       update.Currency,
+		Email:          registration.Email,
+		Description:    ticketType.Name,
+		SuccessURL:     h.frontendURL + "/registration/payment/success",
+		CancelURL:      h.frontendURL + "/registration/payment/cancel",
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, PaymentResponse{
+			Success: false,
+			Message: "Failed to create checkout session: " + err.Error(),
+		})
+		return
+	}
+
+	update.Status = models.PaymentStatusPending
+	update.Ref = checkout.ID
+	registration, ok := h.setPaymentStatus(c, registration.ID, update)
+	if !ok {
+		// Nobody is sent to the new session, so it must not stay payable
+		if err := h.provider.ExpireCheckoutSession(ctx, checkout.ID); err != nil {
+			slog.ErrorContext(ctx, "Failed to expire unused checkout session", "registration_id", before.ID, "checkout_session_id", checkout.ID, "error", err)
+		}
+		return
+	}
+
+	h.auditLog.Record(c, "registration.checkout", audit.TargetRegistration, registration.ID, &before, registration)
+
+	c.JSON(http.StatusOK, PaymentResponse{
+		Success:      true,
+		Message:      "Checkout session created",
+		Registration: registration,
+		Checkout:     checkout,
+	})
+}
+
+// setPaymentStatus applies a checkout's payment update and writes the error
+// response if it fails. A payment changed since it was read means another
+// checkout or a webhook got there first, so the caller has to start over.
+func (h *PaymentHandler) setPaymentStatus(c *gin.Context, id string, update repository.PaymentUpdate) (*models.Registration, bool) {
+	registration, err := h.registrations.SetPaymentStatus(requestContext(c), id, update)
+	if errors.Is(err, repository.ErrPaymentChanged) {
+		c.JSON(http.StatusConflict, PaymentResponse{
+			Success:      false,
+			Message:      "Registration payment changed, please try again",
+			Registration: registration,
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, PaymentResponse{
+			Success: false,
+			Message: "Failed to update registration: " + err.Error(),
+		})
+		return nil, false
+	}
+	return registration, true
+}
+
+// Webhook receives signed payment events from the provider
+func (h *PaymentHandler) Webhook(c *gin.Context) {
+	payload, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, PaymentResponse{
+			Success: false,
+			Message: "Failed to read request body",
+		})
+		return
+	}
+
+	h.handleWebhook(c, payload, c.Request.Header)
+}
+
+// SimulatePayment delivers a signed event from the fake provider for the
+// given checkout session through the regular webhook path
+func (h *PaymentHandler) SimulatePayment(c *gin.Context) {
+	fake, ok := h.provider.(*payment.FakeProvider)
+	if !ok {
+		c.JSON(http.StatusNotFound, PaymentResponse{
+			Success: false,
+			Message: "Payment simulation is only available with the fake provider",
+		})
+		return
+	}
+
+	var req SimulatePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, PaymentResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return
+	}
+
+	payload, header, err := fake.SignEvent(c.Param("sessionId"), req.Type)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, PaymentResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.handleWebhook(c, payload, header)
+}
+
+// handleWebhook verifies a webhook payload and applies its event
+func (h *PaymentHandler) handleWebhook(c *gin.Context, payload []byte, header http.Header) {
+	ctx := requestContext(c)
+
+	event, err := h.provider.ParseWebhook(ctx, payload, header)
+	if errors.Is(err, payment.ErrInvalidSignature) {
+		c.JSON(http.StatusUnauthorized, PaymentResponse{
+			Success: false,
+			Message: "Invalid webhook signature",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, PaymentResponse{
+			Success: false,
+			Message: "Invalid webhook payload: " + err.Error(),
+		})
+		return
+	}
+
+	registration, err := h.registrations.Get(ctx, event.RegistrationID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, PaymentResponse{
+			Success: false,
+			Message: "Registration not found",
+		})
+		return
+	}
+
+	// Redeliveries and concurrent events move the payment at most once: if
+	// it changed since it was read, the event is applied to the new state
+	for {
+		// Ignore events for superseded checkout sessions. Those are expired
+		// when replaced, but one may have been paid just before: the money
+		// has to be refunded by hand.
+		if registration.PaymentRef != event.CheckoutSessionID {
+			if event.Type == payment.EventPaymentSucceeded {
+				slog.ErrorContext(ctx, "Payment received for a superseded checkout session, refund it",
+					"registration_id", registration.ID, "checkout_session_id", event.CheckoutSessionID, "event_id", event.ID)
+				h.auditLog.Record(c, "registration.payment_superseded", audit.TargetRegistration, registration.ID, nil, event)
+			}
+			c.JSON(http.StatusOK, PaymentResponse{
+				Success: true,
+				Message: "Event ignored: checkout session is not current",
+			})
+			return
+		}
+
+		status, ok := nextPaymentStatus(registration.PaymentStatus, event.Type)
+		if !ok {
+			slog.WarnContext(ctx, "Ignoring payment event", "event_type", event.Type, "registration_id", registration.ID, "payment_status", registration.PaymentStatus)
+			c.JSON(http.StatusOK, PaymentResponse{
+				Success: true,
+				Message: "Event ignored",
+			})
+			return
+		}
+		if status == registration.PaymentStatus {
+			break
+		}
+
+		before := *registration
+		updated, err := h.registrations.SetPaymentStatus(ctx, registration.ID, repository.PaymentUpdate{
+			FromStatus: registration.PaymentStatus,
+			FromRef:    registration.PaymentRef,
+			Status:     status,
+			Ref:        registration.PaymentRef,
+			AmountDue:  registration.AmountDue,
+			Currency:   registration.Currency,
+			At:         time.Now(),
+		})
+		if errors.Is(err, repository.ErrPaymentChanged) {
+			registration = updated
+			continue
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, PaymentResponse{
+				Success: false,
+				Message: "Registration not found",
+			})
+			return
+		}
+		if err != nil {
+			// A 5xx makes the provider retry the delivery
+			c.JSON(http.StatusInternalServerError, PaymentResponse{
+				Success: false,
+				Message: "Failed to update registration: " + err.Error(),
+			})
+			return
+		}
+		registration = updated
+
+		h.auditLog.Record(c, "registration.payment", audit.TargetRegistration, registration.ID, &before, registration)
+
+		if status == models.PaymentStatusCompleted {
+			h.sendReceipt(ctx, registration)
+		}
+		break
+	}
+
+	c.JSON(http.StatusOK, PaymentResponse{
+		Success:      true,
+		Message:      "Event processed",
+		Registration: registration,
+	})
+}
+
+// sendReceipt notifies the attendee of a completed payment. Failures are
+// logged because the payment has already been recorded.
+func (h *PaymentHandler) sendReceipt(ctx context.Context, registration *models.Registration) {
+	if err := h.notifier.PaymentReceived(ctx, registration); err != nil {
+		slog.ErrorContext(ctx, "Failed to send payment receipt", "registration_id", registration.ID, "error", err)
+	}
+}
+
+// nextPaymentStatus returns the payment status a registration moves to when
+// it receives eventType, and false if the event does not apply. Repeated
+// deliveries of the same event leave the status unchanged.
+func nextPaymentStatus(current, eventType string) (string, bool) {
+	switch eventType {
+	case payment.EventPaymentSucceeded:
+		switch current {
+		case models.PaymentStatusPending, models.PaymentStatusFailed, models.PaymentStatusCompleted:
+			return models.PaymentStatusCompleted, true
+		}
+	case payment.EventPaymentFailed:
+		switch current {
+		case models.PaymentStatusPending, models.PaymentStatusFailed:
+			return models.PaymentStatusFailed, true
+		}
+	case payment.EventPaymentRefunded:
+		switch current {
+		case models.PaymentStatusCompleted, models.PaymentStatusRefunded:
+			return models.PaymentStatusRefunded, true
+		}
+	}
+	return "", false
+}
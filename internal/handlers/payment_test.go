@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"testing"
+
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/payment"
+)
+
+func TestNextPaymentStatus(t *testing.T) {
+	tests := []struct {
+		current string
+		event   string
+		want    string
+		ok      bool
+	}{
+		{models.PaymentStatusPending, payment.EventPaymentSucceeded, models.PaymentStatusCompleted, true},
+		{models.PaymentStatusFailed, payment.EventPaymentSucceeded, models.PaymentStatusCompleted, true},
+		{models.PaymentStatusCompleted, payment.EventPaymentSucceeded, models.PaymentStatusCompleted, true},
+		{models.PaymentStatusRefunded, payment.EventPaymentSucceeded, "", false},
+
+		{models.PaymentStatusPending, payment.EventPaymentFailed, models.PaymentStatusFailed, true},
+		{models.PaymentStatusFailed, payment.EventPaymentFailed, models.PaymentStatusFailed, true},
+		{models.PaymentStatusCompleted, payment.EventPaymentFailed, "", false},
+		{models.PaymentStatusRefunded, payment.EventPaymentFailed, "", false},
+
+		{models.PaymentStatusCompleted, payment.EventPaymentRefunded, models.PaymentStatusRefunded, true},
+		{models.PaymentStatusRefunded, payment.EventPaymentRefunded, models.PaymentStatusRefunded, true},
+		{models.PaymentStatusPending, payment.EventPaymentRefunded, "", false},
+		{models.PaymentStatusFailed, payment.EventPaymentRefunded, "", false},
+
+		{models.PaymentStatusPending, "checkout.session.completed", "", false},
+		{"", payment.EventPaymentSucceeded, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.current+" "+tt.event, func(t *testing.T) {
+			got, ok := nextPaymentStatus(tt.current, tt.event)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("nextPaymentStatus(%q, %q) = %q, %v, want %q, %v", tt.current, tt.event, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
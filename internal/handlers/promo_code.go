@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promoCodePattern restricts promo codes to URL and document-ID safe values
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// PromoCodeHandler handles promo code management requests
+type PromoCodeHandler struct {
+	promoCodes repository.PromoCodeRepository
+	auditLog   *audit.Logger
+}
+
+// NewPromoCodeHandler creates a new promo code handler
+func NewPromoCodeHandler(promoCodes repository.PromoCodeRepository, auditLog *audit.Logger) *PromoCodeHandler {
+	return &PromoCodeHandler{
+		promoCodes: promoCodes,
+		auditLog:   auditLog,
+	}
+}
+
+// PromoCodeResponse represents the response for promo code operations
+type PromoCodeResponse struct {
+	Success    bool               `json:"success"`
+	Message    string             `json:"message"`
+	PromoCode  *models.PromoCode  `json:"promoCode,omitempty"`
+	PromoCodes []models.PromoCode `json:"promoCodes,omitempty"`
+}
+
+// ListPromoCodes returns every promo code with its usage (admins only)
+func (h *PromoCodeHandler) ListPromoCodes(c *gin.Context) {
+	ctx := requestContext(c)
+
+	promos, err := h.promoCodes.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, PromoCodeResponse{
+			Success: false,
+			Message: "Failed to retrieve promo codes: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PromoCodeResponse{
+		Success:    true,
+		Message:    "Promo codes retrieved successfully",
+		PromoCodes: promos,
+	})
+}
+
+// CreatePromoCode creates a new promo code (admins only)
+func (h *PromoCodeHandler) CreatePromoCode(c *gin.Context) {
+	input, ok := bindPromoCodeInput(c)
+	if !ok {
+		return
+	}
+
+	code := normalizePromoCode(input.ID)
+	if !promoCodePattern.MatchString(code) {
+		c.JSON(http.StatusBadRequest, PromoCodeResponse{
+			Success: false,
+			Message: "Invalid request: id must be 3-32 letters, digits, '-' or '_'",
+		})
+		return
+	}
+
+	now := time.Now()
+	promo := &models.PromoCode{
+		ID:        code,
+		CreatedAt: now,
+	}
+	applyPromoCodeInput(promo, input, now)
+
+	ctx := requestContext(c)
+
+	err := h.promoCodes.Create(ctx, promo)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, PromoCodeResponse{
+			Success: false,
+			Message: "A promo code with this id already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, PromoCodeResponse{
+			Success: false,
+			Message: "Failed to create promo code: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "promo_code.create", audit.TargetPromoCode, promo.ID, nil, promo)
+
+	c.JSON(http.StatusCreated, PromoCodeResponse{
+		Success:   true,
+		Message:   "Promo code created successfully",
+		PromoCode: promo,
+	})
+}
+
+// UpdatePromoCode updates a promo code (admins only)
+func (h *PromoCodeHandler) UpdatePromoCode(c *gin.Context) {
+	input, ok := bindPromoCodeInput(c)
+	if !ok {
+		return
+	}
+
+	ctx := requestContext(c)
+
+	promo, err := h.promoCodes.Get(ctx, normalizePromoCode(c.Param("id")))
+	if err != nil {
+		h.respondLookupError(c, err)
+		return
+	}
+
+	before := *promo
+	applyPromoCodeInput(promo, input, time.Now())
+
+	err = h.promoCodes.Update(ctx, promo)
+	// Update reports the current use count, which is not edited here
+	before.Uses = promo.Uses
+	if errors.Is(err, repository.ErrMaxUsesBelowUses) {
+		c.JSON(http.StatusConflict, PromoCodeResponse{
+			Success:   false,
+			Message:   "Max uses cannot be lower than the number of times the code was redeemed",
+			PromoCode: &before,
+		})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		h.respondLookupError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, PromoCodeResponse{
+			Success: false,
+			Message: "Failed to update promo code: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "promo_code.update", audit.TargetPromoCode, promo.ID, &before, promo)
+
+	c.JSON(http.StatusOK, PromoCodeResponse{
+		Success:   true,
+		Message:   "Promo code updated successfully",
+		PromoCode: promo,
+	})
+}
+
+// DeletePromoCode removes a promo code that has never been redeemed (admins only)
+func (h *PromoCodeHandler) DeletePromoCode(c *gin.Context) {
+	ctx := requestContext(c)
+
+	promo, err := h.promoCodes.Get(ctx, normalizePromoCode(c.Param("id")))
+	if err != nil {
+		h.respondLookupError(c, err)
+		return
+	}
+
+	// Registrations reference redeemed codes; deactivate those instead
+	err = h.promoCodes.Delete(ctx, promo.ID)
+	if errors.Is(err, repository.ErrInUse) {
+		c.JSON(http.StatusConflict, PromoCodeResponse{
+			Success:   false,
+			Message:   "Promo code has been redeemed. Deactivate it instead",
+			PromoCode: promo,
+		})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		h.respondLookupError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, PromoCodeResponse{
+			Success: false,
+			Message: "Failed to delete promo code: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "promo_code.delete", audit.TargetPromoCode, promo.ID, promo, nil)
+
+	c.JSON(http.StatusOK, PromoCodeResponse{
+		Success: true,
+		Message: "Promo code deleted successfully",
+	})
+}
+
+// respondLookupError writes a 404 for missing promo codes and a 500 otherwise
+func (h *PromoCodeHandler) respondLookupError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, PromoCodeResponse{
+			Success: false,
+			Message: "Promo code not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, PromoCodeResponse{
+		Success: false,
+		Message: "Failed to retrieve promo code: " + err.Error(),
+	})
+}
+
+// bindPromoCodeInput binds and validates the promo code request body
+func bindPromoCodeInput(c *gin.Context) (*models.PromoCodeInput, bool) {
+	var input models.PromoCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, PromoCodeResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if input.DiscountType == models.DiscountTypePercent && input.Value > 100 {
+		c.JSON(http.StatusBadRequest, PromoCodeResponse{
+			Success: false,
+			Message: "Invalid request: percent discounts must be between 1 and 100",
+		})
+		return nil, false
+	}
+
+	if input.DiscountType == models.DiscountTypeFixed && len(input.Currency) != 3 {
+		c.JSON(http.StatusBadRequest, PromoCodeResponse{
+			Success: false,
+			Message: "Invalid request: fixed discounts require a 3-letter currency",
+		})
+		return nil, false
+	}
+
+	return &input, true
+}
+
+// applyPromoCodeInput copies input fields onto promo
+func applyPromoCodeInput(promo *models.PromoCode, input *models.PromoCodeInput, now time.Time) {
+	promo.Description = input.Description
+	promo.DiscountType = input.DiscountType
+	promo.Value = input.Value
+	promo.Currency = strings.ToUpper(input.Currency)
+	promo.TicketTypes = input.TicketTypes
+	promo.MaxUses = input.MaxUses
+	promo.ExpiresAt = input.ExpiresAt
+	promo.Active = input.Active
+	promo.UpdatedAt = now
+}
@@ -2,27 +2,42 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"backend-ITC/internal/audit"
 	"backend-ITC/internal/models"
+	"backend-ITC/internal/notify"
+	"backend-ITC/internal/repository"
 
-	fb "backend-ITC/internal/firebase"
-
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"google.golang.org/api/iterator"
 )
 
 // RegistrationHandler handles registration related requests
 type RegistrationHandler struct {
-	firebaseClient *fb.Client
+	registrations repository.RegistrationRepository
+	ticketTypes   repository.TicketTypeRepository
+	promoCodes    repository.PromoCodeRepository
+	enrollments   repository.EnrollmentRepository
+	sessions      repository.SessionRepository
+	notifier      notify.Notifier
+	auditLog      *audit.Logger
 }
 
 // NewRegistrationHandler creates a new registration handler
-func NewRegistrationHandler(fc *fb.Client) *RegistrationHandler {
+func NewRegistrationHandler(registrations repository.RegistrationRepository, ticketTypes repository.TicketTypeRepository, promoCodes repository.PromoCodeRepository, enrollments repository.EnrollmentRepository, sessions repository.SessionRepository, notifier notify.Notifier, auditLog *audit.Logger) *RegistrationHandler {
 	return &RegistrationHandler{
-		firebaseClient: fc,
+		registrations: registrations,
+		ticketTypes:   ticketTypes,
+		promoCodes:    promoCodes,
+		enrollments:   enrollments,
+		sessions:      sessions,
+		notifier:      notifier,
+		auditLog:      auditLog,
 	}
 }
 
@@ -64,7 +79,7 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := requestContext(c)
 
 	// Check if user already has a registration
 	existingReg, _ := h.getUserRegistration(ctx, user.UID)
@@ -77,6 +92,21 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 		return
 	}
 
+	// Validate and reserve the ticket
+	ticketType, ok := h.reserveTicket(ctx, c, input.TicketType, user, input.Country)
+	if !ok {
+		return
+	}
+
+	// Redeem the promo code, if any
+	var promo *models.PromoCode
+	if code := normalizePromoCode(input.PromoCode); code != "" {
+		if promo, ok = h.redeemPromo(ctx, c, code, ticketType); !ok {
+			h.releaseTicket(ctx, ticketType.ID)
+			return
+		}
+	}
+
 	// Create registration
 	now := time.Now()
 	registration := &models.Registration{
@@ -93,15 +123,30 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 		SpecialNeeds:     input.SpecialNeeds,
 		TicketType:       input.TicketType,
 		SessionsOfInt:    input.SessionsOfInt,
-		PaymentStatus:    "pending",
+		PaymentStatus:    models.PaymentStatusPending,
 		RegistrationDate: now,
 		CreatedAt:        now,
 		UpdatedAt:        now,
 	}
+	if promo != nil {
+		registration.PromoCode = promo.ID
+		registration.Discount = promo.DiscountFor(ticketType.Price)
+	}
 
-	// Save to Firestore
-	docRef, _, err := h.firebaseClient.Firestore.Collection("registrations").Add(ctx, registration)
-	if err != nil {
+	// Save registration. The check above is only a fast path: a concurrent
+	// request may have created the user's registration since.
+	if err := h.registrations.Create(ctx, registration); err != nil {
+		h.releaseTicket(ctx, registration.TicketType)
+		h.releasePromo(ctx, registration.PromoCode)
+		if errors.Is(err, repository.ErrAlreadyExists) {
+			existingReg, _ := h.getUserRegistration(ctx, user.UID)
+			c.JSON(http.StatusConflict, RegistrationResponse{
+				Success:      false,
+				Message:      "User already has a registration. Please update instead.",
+				Registration: existingReg,
+			})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, RegistrationResponse{
 			Success: false,
 			Message: "Failed to create registration: " + err.Error(),
@@ -109,7 +154,11 @@ func (h *RegistrationHandler) CreateRegistration(c *gin.Context) {
 		return
 	}
 
-	registration.ID = docRef.ID
+	h.auditLog.Record(c, "registration.create", audit.TargetRegistration, registration.ID, nil, registration)
+
+	if err := h.notifier.RegistrationConfirmed(ctx, registration); err != nil {
+		slog.ErrorContext(ctx, "Failed to send registration confirmation", "registration_id", registration.ID, "error", err)
+	}
 
 	c.JSON(http.StatusCreated, RegistrationResponse{
 		Success:      true,
@@ -138,7 +187,7 @@ func (h *RegistrationHandler) GetMyRegistration(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := requestContext(c)
 	registration, err := h.getUserRegistration(ctx, user.UID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, RegistrationResponse{
@@ -184,7 +233,7 @@ func (h *RegistrationHandler) UpdateRegistration(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := requestContext(c)
 
 	// Find existing registration
 	existingReg, err := h.getUserRegistration(ctx, user.UID)
@@ -196,25 +245,83 @@ func (h *RegistrationHandler) UpdateRegistration(c *gin.Context) {
 		return
 	}
 
+	before := *existingReg
+	previousTicketType := existingReg.TicketType
+	previousPromoCode := existingReg.PromoCode
+	promoCode := normalizePromoCode(input.PromoCode)
+	ticketChanged := input.TicketType != previousTicketType
+	promoChanged := promoCode != previousPromoCode
+
+	if (ticketChanged || promoChanged) && existingReg.PaymentStatus == models.PaymentStatusCompleted {
+		c.JSON(http.StatusConflict, RegistrationResponse{
+			Success:      false,
+			Message:      "The ticket type and promo code of a paid registration cannot be changed",
+			Registration: existingReg,
+		})
+		return
+	}
+
+	// Reserve the new ticket when the ticket type changes
+	var ticketType *models.TicketType
+	if ticketChanged {
+		var ok bool
+		if ticketType, ok = h.reserveTicket(ctx, c, input.TicketType, user, input.Country); !ok {
+			return
+		}
+	} else if promoChanged && promoCode != "" {
+		var err error
+		if ticketType, err = h.ticketTypes.Get(ctx, input.TicketType); err != nil {
+			c.JSON(http.StatusInternalServerError, RegistrationResponse{
+				Success: false,
+				Message: "Failed to retrieve ticket type: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	// Redeem a new promo code, or check the current one still applies to a new ticket
+	if promoCode != "" && (ticketChanged || promoChanged) {
+		var promo *models.PromoCode
+		var ok bool
+		if promoChanged {
+			promo, ok = h.redeemPromo(ctx, c, promoCode, ticketType)
+		} else {
+			promo, ok = h.checkPromoApplies(ctx, c, promoCode, ticketType)
+		}
+		if !ok {
+			if ticketChanged {
+				h.releaseTicket(ctx, ticketType.ID)
+			}
+			return
+		}
+		existingReg.Discount = promo.DiscountFor(ticketType.Price)
+	} else if promoCode == "" {
+		existingReg.Discount = 0
+	}
+
 	// Update registration
-	updates := map[string]interface{}{
-		"firstName":           input.FirstName,
-		"lastName":            input.LastName,
-		"email":               input.Email,
-		"phone":               input.Phone,
-		"organization":        input.Organization,
-		"jobTitle":            input.JobTitle,
-		"country":             input.Country,
-		"city":                input.City,
-		"dietaryRequirements": input.DietaryReqs,
-		"specialNeeds":        input.SpecialNeeds,
-		"ticketType":          input.TicketType,
-		"sessionsOfInterest":  input.SessionsOfInt,
-		"updatedAt":           time.Now(),
-	}
-
-	_, err = h.firebaseClient.Firestore.Collection("registrations").Doc(existingReg.ID).Set(ctx, updates, firestore.MergeAll)
-	if err != nil {
+	existingReg.FirstName = input.FirstName
+	existingReg.LastName = input.LastName
+	existingReg.Email = input.Email
+	existingReg.Phone = input.Phone
+	existingReg.Organization = input.Organization
+	existingReg.JobTitle = input.JobTitle
+	existingReg.Country = input.Country
+	existingReg.City = input.City
+	existingReg.DietaryReqs = input.DietaryReqs
+	existingReg.SpecialNeeds = input.SpecialNeeds
+	existingReg.TicketType = input.TicketType
+	existingReg.SessionsOfInt = input.SessionsOfInt
+	existingReg.PromoCode = promoCode
+	existingReg.UpdatedAt = time.Now()
+
+	if err := h.registrations.Update(ctx, existingReg); err != nil {
+		if ticketChanged {
+			h.releaseTicket(ctx, input.TicketType)
+		}
+		if promoChanged {
+			h.releasePromo(ctx, promoCode)
+		}
 		c.JSON(http.StatusInternalServerError, RegistrationResponse{
 			Success: false,
 			Message: "Failed to update registration: " + err.Error(),
@@ -222,13 +329,23 @@ func (h *RegistrationHandler) UpdateRegistration(c *gin.Context) {
 		return
 	}
 
-	// Fetch updated registration
-	updatedReg, _ := h.getUserRegistration(ctx, user.UID)
+	if ticketChanged {
+		h.releaseTicket(ctx, previousTicketType)
+	}
+	if promoChanged {
+		h.releasePromo(ctx, previousPromoCode)
+	}
+
+	h.auditLog.Record(c, "registration.update", audit.TargetRegistration, existingReg.ID, &before, existingReg)
+
+	if err := h.notifier.RegistrationUpdated(ctx, existingReg); err != nil {
+		slog.ErrorContext(ctx, "Failed to send registration update", "registration_id", existingReg.ID, "error", err)
+	}
 
 	c.JSON(http.StatusOK, RegistrationResponse{
 		Success:      true,
 		Message:      "Registration updated successfully",
-		Registration: updatedReg,
+		Registration: existingReg,
 	})
 }
 
@@ -252,7 +369,7 @@ func (h *RegistrationHandler) DeleteRegistration(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := requestContext(c)
 
 	// Find existing registration
 	existingReg, err := h.getUserRegistration(ctx, user.UID)
@@ -264,9 +381,18 @@ func (h *RegistrationHandler) DeleteRegistration(c *gin.Context) {
 		return
 	}
 
+	// Paid registrations are cancelled through a refund
+	if existingReg.PaymentStatus == models.PaymentStatusCompleted || existingReg.IsCheckedIn() {
+		c.JSON(http.StatusConflict, RegistrationResponse{
+			Success:      false,
+			Message:      "Paid or checked-in registrations cannot be cancelled; please contact the organizers for a refund",
+			Registration: existingReg,
+		})
+		return
+	}
+
 	// Delete registration
-	_, err = h.firebaseClient.Firestore.Collection("registrations").Doc(existingReg.ID).Delete(ctx)
-	if err != nil {
+	if err := h.registrations.Delete(ctx, existingReg.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, RegistrationResponse{
 			Success: false,
 			Message: "Failed to delete registration: " + err.Error(),
@@ -274,61 +400,314 @@ func (h *RegistrationHandler) DeleteRegistration(c *gin.Context) {
 		return
 	}
 
+	h.releaseTicket(ctx, existingReg.TicketType)
+	h.releasePromo(ctx, existingReg.PromoCode)
+	h.dropEnrollments(c, existingReg.UserID)
+
+	h.auditLog.Record(c, "registration.delete", audit.TargetRegistration, existingReg.ID, existingReg, nil)
+
+	if err := h.notifier.RegistrationCancelled(ctx, existingReg); err != nil {
+		slog.ErrorContext(ctx, "Failed to send registration cancellation", "registration_id", existingReg.ID, "error", err)
+	}
+
 	c.JSON(http.StatusOK, RegistrationResponse{
 		Success: true,
 		Message: "Registration deleted successfully",
 	})
 }
 
-// GetAllRegistrations retrieves all registrations (admin only - add admin check as needed)
+// RegistrationListResponse represents a page of the admin registrations list
+type RegistrationListResponse struct {
+	Success       bool                  `json:"success"`
+	Message       string                `json:"message"`
+	Registrations []models.Registration `json:"registrations"`
+	NextCursor    string                `json:"nextCursor,omitempty"`
+	Total         int                   `json:"total"`
+}
+
+// maxRegistrationPageSize caps the limit query parameter of the admin list
+const maxRegistrationPageSize = 200
+
+// GetAllRegistrations retrieves a page of registrations (admins and organizers only)
+// Optional query parameters:
+//   - ticketType, paymentStatus, country: exact matches
+//   - from, to: creation date range (YYYY-MM-DD, UTC, inclusive)
+//   - sort: createdAt or lastName, prefixed with "-" for descending order;
+//     lastName cannot be combined with from or to
+//   - limit: page size (default 50, max 200)
+//   - cursor: nextCursor of the previous page
 func (h *RegistrationHandler) GetAllRegistrations(c *gin.Context) {
-	ctx := context.Background()
+	filter, ok := bindRegistrationFilter(c)
+	if !ok {
+		return
+	}
 
-	iter := h.firebaseClient.Firestore.Collection("registrations").Documents(ctx)
-	var registrations []models.Registration
+	page := repository.PageRequest{
+		SortBy: strings.TrimPrefix(c.DefaultQuery("sort", repository.SortByCreatedAt), "-"),
+		Cursor: c.Query("cursor"),
+	}
+	page.Descending = strings.HasPrefix(c.Query("sort"), "-")
+	if page.SortBy != repository.SortByCreatedAt && page.SortBy != repository.SortByLastName {
+		c.JSON(http.StatusBadRequest, RegistrationListResponse{
+			Success: false,
+			Message: "Invalid sort: use createdAt or lastName",
+		})
+		return
+	}
+	// Firestore can only order a date range by the date itself
+	if page.SortBy == repository.SortByLastName && (!filter.CreatedFrom.IsZero() || !filter.CreatedBefore.IsZero()) {
+		c.JSON(http.StatusBadRequest, RegistrationListResponse{
+			Success: false,
+			Message: "Sorting by lastName cannot be combined with from or to",
+		})
+		return
+	}
 
-	for {
-		doc, err := iter.Next()
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, RegistrationResponse{
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxRegistrationPageSize {
+			c.JSON(http.StatusBadRequest, RegistrationListResponse{
 				Success: false,
-				Message: "Failed to retrieve registrations: " + err.Error(),
+				Message: "Invalid limit: expected 1 to " + strconv.Itoa(maxRegistrationPageSize),
 			})
 			return
 		}
+		page.Limit = n
+	}
 
-		var reg models.Registration
-		if err := doc.DataTo(&reg); err != nil {
-			continue
-		}
-		reg.ID = doc.Ref.ID
-		registrations = append(registrations, reg)
+	ctx := requestContext(c)
+
+	result, err := h.registrations.ListPage(ctx, filter, page)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, RegistrationListResponse{
+			Success: false,
+			Message: "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, RegistrationListResponse{
+			Success: false,
+			Message: "Failed to retrieve registrations: " + err.Error(),
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, RegistrationResponse{
+	c.JSON(http.StatusOK, RegistrationListResponse{
 		Success:       true,
 		Message:       "Registrations retrieved successfully",
-		Registrations: registrations,
+		Registrations: result.Registrations,
+		NextCursor:    result.NextCursor,
+		Total:         result.Total,
 	})
 }
 
-// getUserRegistration retrieves a user's registration from Firestore
+// bindRegistrationFilter reads the admin list filters from the query string.
+// On invalid input it writes the error response and returns false.
+func bindRegistrationFilter(c *gin.Context) (repository.RegistrationFilter, bool) {
+	filter := repository.RegistrationFilter{
+		TicketType:    c.Query("ticketType"),
+		PaymentStatus: c.Query("paymentStatus"),
+		Country:       c.Query("country"),
+	}
+
+	for _, param := range []string{"from", "to"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+
+		day, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, RegistrationResponse{
+				Success: false,
+				Message: "Invalid " + param + ": expected YYYY-MM-DD",
+			})
+			return filter, false
+		}
+
+		if param == "from" {
+			filter.CreatedFrom = day
+		} else {
+			filter.CreatedBefore = day.AddDate(0, 0, 1)
+		}
+	}
+
+	return filter, true
+}
+
+// getUserRegistration retrieves a user's registration from the repository
 func (h *RegistrationHandler) getUserRegistration(ctx context.Context, userID string) (*models.Registration, error) {
-	iter := h.firebaseClient.Firestore.Collection("registrations").Where("userId", "==", userID).Limit(1).Documents(ctx)
+	return h.registrations.GetByUserID(ctx, userID)
+}
+
+// reserveTicket checks that ticketTypeID exists, is on sale and that the user
+// is eligible, then takes one ticket from inventory and returns its type. On
+// failure it writes the error response and returns false.
+func (h *RegistrationHandler) reserveTicket(ctx context.Context, c *gin.Context, ticketTypeID string, user *models.User, country string) (*models.TicketType, bool) {
+	ticketType, err := h.ticketTypes.Get(ctx, ticketTypeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, RegistrationResponse{
+			Success: false,
+			Message: "Unknown ticket type: " + ticketTypeID,
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, RegistrationResponse{
+			Success: false,
+			Message: "Failed to retrieve ticket type: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if !ticketType.IsOnSale(time.Now()) {
+		c.JSON(http.StatusBadRequest, RegistrationResponse{
+			Success: false,
+			Message: "Ticket type is not on sale",
+		})
+		return nil, false
+	}
+
+	// Eligibility uses the verified account email rather than the form email
+	if !ticketType.IsEligible(user.Email, country) {
+		c.JSON(http.StatusForbidden, RegistrationResponse{
+			Success: false,
+			Message: "You are not eligible for this ticket type",
+		})
+		return nil, false
+	}
+
+	err = h.ticketTypes.Reserve(ctx, ticketTypeID)
+	if errors.Is(err, repository.ErrSoldOut) {
+		c.JSON(http.StatusConflict, RegistrationResponse{
+			Success: false,
+			Message: "Ticket type is sold out",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, RegistrationResponse{
+			Success: false,
+			Message: "Failed to reserve ticket: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	return ticketType, true
+}
+
+// releaseTicket returns a ticket to inventory. Failures are logged because the
+// registration change they accompany has already been applied.
+func (h *RegistrationHandler) releaseTicket(ctx context.Context, ticketTypeID string) {
+	if err := h.ticketTypes.Release(ctx, ticketTypeID); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		slog.ErrorContext(ctx, "Failed to release ticket", "ticket_type_id", ticketTypeID, "error", err)
+	}
+}
+
+// checkPromoApplies loads the promo code and checks it can be used with
+// ticketType. On failure it writes the error response and returns false.
+func (h *RegistrationHandler) checkPromoApplies(ctx context.Context, c *gin.Context, code string, ticketType *models.TicketType) (*models.PromoCode, bool) {
+	promo, err := h.promoCodes.Get(ctx, code)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusBadRequest, RegistrationResponse{
+			Success: false,
+			Message: "Invalid promo code",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, RegistrationResponse{
+			Success: false,
+			Message: "Failed to retrieve promo code: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if !promo.Active || promo.IsExpired(time.Now()) {
+		c.JSON(http.StatusBadRequest, RegistrationResponse{
+			Success: false,
+			Message: "Promo code has expired",
+		})
+		return nil, false
+	}
+
+	if !promo.AppliesTo(ticketType) {
+		c.JSON(http.StatusBadRequest, RegistrationResponse{
+			Success: false,
+			Message: "Promo code does not apply to this ticket type",
+		})
+		return nil, false
+	}
+
+	return promo, true
+}
+
+// redeemPromo checks the promo code applies to ticketType and atomically
+// counts one use. On failure it writes the error response and returns false.
+func (h *RegistrationHandler) redeemPromo(ctx context.Context, c *gin.Context, code string, ticketType *models.TicketType) (*models.PromoCode, bool) {
+	promo, ok := h.checkPromoApplies(ctx, c, code, ticketType)
+	if !ok {
+		return nil, false
+	}
 
-	doc, err := iter.Next()
+	err := h.promoCodes.Redeem(ctx, code)
+	if errors.Is(err, repository.ErrUsageLimitReached) {
+		c.JSON(http.StatusConflict, RegistrationResponse{
+			Success: false,
+			Message: "Promo code has reached its usage limit",
+		})
+		return nil, false
+	}
 	if err != nil {
-		return nil, err
+		c.JSON(http.StatusInternalServerError, RegistrationResponse{
+			Success: false,
+			Message: "Failed to redeem promo code: " + err.Error(),
+		})
+		return nil, false
 	}
 
-	var registration models.Registration
-	if err := doc.DataTo(&registration); err != nil {
-		return nil, err
+	return promo, true
+}
+
+// releasePromo gives back one use of a promo code. Failures are logged
+// because the registration change they accompany has already been applied.
+func (h *RegistrationHandler) releasePromo(ctx context.Context, code string) {
+	if code == "" {
+		return
+	}
+	if err := h.promoCodes.Release(ctx, code); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		slog.ErrorContext(ctx, "Failed to release promo code", "promo_code", code, "error", err)
 	}
-	registration.ID = doc.Ref.ID
+}
+
+// dropEnrollments removes userID from every session and waitlist and
+// notifies the attendees promoted into the freed seats. Failures are logged
+// because the registration has already been deleted.
+func (h *RegistrationHandler) dropEnrollments(c *gin.Context, userID string) {
+	ctx := requestContext(c)
+
+	promoted, err := h.enrollments.DropAllForUser(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to drop enrollments", "uid", userID, "error", err)
+	}
+	recordPromotions(c, h.auditLog, promoted)
+
+	bySession := make(map[string][]models.Enrollment)
+	for _, enrollment := range promoted {
+		bySession[enrollment.SessionID] = append(bySession[enrollment.SessionID], enrollment)
+	}
+	for sessionID, enrollments := range bySession {
+		session, err := h.sessions.Get(ctx, sessionID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load session for waitlist notifications", "session_id", sessionID, "error", err)
+			continue
+		}
+		notifyPromoted(ctx, h.notifier, session, enrollments)
+	}
+}
 
-	return &registration, nil
+// normalizePromoCode canonicalises user-entered codes to their stored form
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
 }
@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/notify"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler handles conference session requests
+type SessionHandler struct {
+	sessions    repository.SessionRepository
+	enrollments repository.EnrollmentRepository
+	notifier    notify.Notifier
+	auditLog    *audit.Logger
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessions repository.SessionRepository, enrollments repository.EnrollmentRepository, notifier notify.Notifier, auditLog *audit.Logger) *SessionHandler {
+	return &SessionHandler{
+		sessions:    sessions,
+		enrollments: enrollments,
+		notifier:    notifier,
+		auditLog:    auditLog,
+	}
+}
+
+// SessionResponse represents the response for session operations
+type SessionResponse struct {
+	Success  bool             `json:"success"`
+	Message  string           `json:"message"`
+	Session  *models.Session  `json:"session,omitempty"`
+	Sessions []models.Session `json:"sessions,omitempty"`
+}
+
+// ListSessions returns all sessions, optionally filtered by track, tag and
+// day (YYYY-MM-DD, UTC)
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	filter := repository.SessionFilter{
+		Track: c.Query("track"),
+		Tag:   c.Query("tag"),
+	}
+
+	if day := c.Query("day"); day != "" {
+		start, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, SessionResponse{
+				Success: false,
+				Message: "Invalid day: expected YYYY-MM-DD",
+			})
+			return
+		}
+		filter.StartFrom = start
+		filter.StartBefore = start.AddDate(0, 0, 1)
+	}
+
+	ctx := requestContext(c)
+
+	sessions, err := h.sessions.List(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, SessionResponse{
+			Success: false,
+			Message: "Failed to retrieve sessions: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SessionResponse{
+		Success:  true,
+		Message:  "Sessions retrieved successfully",
+		Sessions: sessions,
+	})
+}
+
+// GetSession returns a single session by ID
+func (h *SessionHandler) GetSession(c *gin.Context) {
+	ctx := requestContext(c)
+
+	session, err := h.sessions.Get(ctx, c.Param("id"))
+	if err != nil {
+		h.respondLookupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SessionResponse{
+		Success: true,
+		Message: "Session retrieved successfully",
+		Session: session,
+	})
+}
+
+// CreateSession creates a new session (admins and organizers only)
+func (h *SessionHandler) CreateSession(c *gin.Context) {
+	input, ok := bindSessionInput(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	session := &models.Session{
+		CreatedAt: now,
+	}
+	applySessionInput(session, input, now)
+
+	ctx := requestContext(c)
+
+	if err := h.sessions.Create(ctx, session); err != nil {
+		c.JSON(http.StatusInternalServerError, SessionResponse{
+			Success: false,
+			Message: "Failed to create session: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "session.create", audit.TargetSession, session.ID, nil, session)
+
+	c.JSON(http.StatusCreated, SessionResponse{
+		Success: true,
+		Message: "Session created successfully",
+		Session: session,
+	})
+}
+
+// UpdateSession updates an existing session (admins and organizers only)
+func (h *SessionHandler) UpdateSession(c *gin.Context) {
+	input, ok := bindSessionInput(c)
+	if !ok {
+		return
+	}
+
+	ctx := requestContext(c)
+
+	session, err := h.sessions.Get(ctx, c.Param("id"))
+	if err != nil {
+		h.respondLookupError(c, err)
+		return
+	}
+
+	before := *session
+	applySessionInput(session, input, time.Now())
+
+	err = h.sessions.Update(ctx, session)
+	// Update reports the current enrollment count, which is not edited here
+	before.Enrolled = session.Enrolled
+	if errors.Is(err, repository.ErrCapacityBelowEnrolled) {
+		c.JSON(http.StatusConflict, SessionResponse{
+			Success: false,
+			Message: "Capacity cannot be lower than the current number of enrollments",
+			Session: &before,
+		})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		h.respondLookupError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, SessionResponse{
+			Success: false,
+			Message: "Failed to update session: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "session.update", audit.TargetSession, session.ID, &before, session)
+	h.notifyAttendees(ctx, session, h.notifier.SessionChanged)
+
+	// A larger capacity may free seats for waitlisted attendees
+	if !session.IsFull() {
+		promoted, err := h.enrollments.Promote(ctx, session.ID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to promote waitlist", "session_id", session.ID, "error", err)
+		} else {
+			session.Enrolled += len(promoted)
+			recordPromotions(c, h.auditLog, promoted)
+			notifyPromoted(ctx, h.notifier, session, promoted)
+		}
+	}
+
+	c.JSON(http.StatusOK, SessionResponse{
+		Success: true,
+		Message: "Session updated successfully",
+		Session: session,
+	})
+}
+
+// DeleteSession deletes a session (admins and organizers only)
+func (h *SessionHandler) DeleteSession(c *gin.Context) {
+	ctx := requestContext(c)
+
+	session, err := h.sessions.Get(ctx, c.Param("id"))
+	if err != nil {
+		h.respondLookupError(c, err)
+		return
+	}
+
+	// Load attendees before the session goes away so they can be told
+	attendees, err := h.enrollments.ListBySession(ctx, session.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list session attendees", "session_id", session.ID, "error", err)
+	}
+
+	if err := h.sessions.Delete(ctx, session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, SessionResponse{
+			Success: false,
+			Message: "Failed to delete session: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "session.delete", audit.TargetSession, session.ID, session, nil)
+
+	if err := h.enrollments.DeleteBySession(ctx, session.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to delete session enrollments", "session_id", session.ID, "error", err)
+	}
+
+	for i := range attendees {
+		if err := h.notifier.SessionCancelled(ctx, &attendees[i], session); err != nil {
+			slog.ErrorContext(ctx, "Failed to notify session cancellation", "user_id", attendees[i].UserID, "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, SessionResponse{
+		Success: true,
+		Message: "Session deleted successfully",
+	})
+}
+
+// notifyAttendees calls send for every enrolled or waitlisted attendee of
+// session. Failures are logged because the session change has been applied.
+func (h *SessionHandler) notifyAttendees(ctx context.Context, session *models.Session, send func(context.Context, *models.Enrollment, *models.Session) error) {
+	attendees, err := h.enrollments.ListBySession(ctx, session.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to list session attendees", "session_id", session.ID, "error", err)
+		return
+	}
+
+	for i := range attendees {
+		if err := send(ctx, &attendees[i], session); err != nil {
+			slog.ErrorContext(ctx, "Failed to notify session change", "user_id", attendees[i].UserID, "error", err)
+		}
+	}
+}
+
+// respondLookupError writes a 404 for missing sessions and a 500 otherwise
+func (h *SessionHandler) respondLookupError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, SessionResponse{
+			Success: false,
+			Message: "Session not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, SessionResponse{
+		Success: false,
+		Message: "Failed to retrieve session: " + err.Error(),
+	})
+}
+
+// bindSessionInput binds and validates the session request body
+func bindSessionInput(c *gin.Context) (*models.SessionInput, bool) {
+	var input models.SessionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, SessionResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if !input.EndTime.After(input.StartTime) {
+		c.JSON(http.StatusBadRequest, SessionResponse{
+			Success: false,
+			Message: "Invalid request: endTime must be after startTime",
+		})
+		return nil, false
+	}
+
+	return &input, true
+}
+
+// applySessionInput copies input fields onto session
+func applySessionInput(session *models.Session, input *models.SessionInput, now time.Time) {
+	session.Title = input.Title
+	session.Description = input.Description
+	session.Speaker = input.Speaker
+	session.SpeakerBio = input.SpeakerBio
+	session.StartTime = input.StartTime
+	session.EndTime = input.EndTime
+	session.Location = input.Location
+	session.Capacity = input.Capacity
+	session.Track = input.Track
+	session.Tags = input.Tags
+	session.UpdatedAt = now
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend-ITC/internal/audit"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ticketTypeIDPattern restricts ticket type codes to URL and document-ID safe values
+var ticketTypeIDPattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// TicketTypeHandler handles ticket type catalog requests
+type TicketTypeHandler struct {
+	ticketTypes repository.TicketTypeRepository
+	auditLog    *audit.Logger
+}
+
+// NewTicketTypeHandler creates a new ticket type handler
+func NewTicketTypeHandler(ticketTypes repository.TicketTypeRepository, auditLog *audit.Logger) *TicketTypeHandler {
+	return &TicketTypeHandler{
+		ticketTypes: ticketTypes,
+		auditLog:    auditLog,
+	}
+}
+
+// TicketTypeResponse represents the response for ticket type operations
+type TicketTypeResponse struct {
+	Success     bool                `json:"success"`
+	Message     string              `json:"message"`
+	TicketType  *models.TicketType  `json:"ticketType,omitempty"`
+	TicketTypes []models.TicketType `json:"ticketTypes,omitempty"`
+}
+
+// ListOnSale returns the ticket types that can currently be bought
+func (h *TicketTypeHandler) ListOnSale(c *gin.Context) {
+	ctx := requestContext(c)
+
+	ticketTypes, err := h.ticketTypes.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, TicketTypeResponse{
+			Success: false,
+			Message: "Failed to retrieve ticket types: " + err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	onSale := []models.TicketType{}
+	for _, t := range ticketTypes {
+		if t.IsOnSale(now) && !t.IsSoldOut() {
+			onSale = append(onSale, t)
+		}
+	}
+
+	c.JSON(http.StatusOK, TicketTypeResponse{
+		Success:     true,
+		Message:     "Ticket types retrieved successfully",
+		TicketTypes: onSale,
+	})
+}
+
+// ListTicketTypes returns every ticket type including inactive ones (admins only)
+func (h *TicketTypeHandler) ListTicketTypes(c *gin.Context) {
+	ctx := requestContext(c)
+
+	ticketTypes, err := h.ticketTypes.List(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, TicketTypeResponse{
+			Success: false,
+			Message: "Failed to retrieve ticket types: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, TicketTypeResponse{
+		Success:     true,
+		Message:     "Ticket types retrieved successfully",
+		TicketTypes: ticketTypes,
+	})
+}
+
+// CreateTicketType adds a ticket type to the catalog (admins only)
+func (h *TicketTypeHandler) CreateTicketType(c *gin.Context) {
+	input, ok := bindTicketTypeInput(c)
+	if !ok {
+		return
+	}
+
+	if !ticketTypeIDPattern.MatchString(input.ID) {
+		c.JSON(http.StatusBadRequest, TicketTypeResponse{
+			Success: false,
+			Message: "Invalid request: id must be 1-64 lowercase letters, digits, '-' or '_'",
+		})
+		return
+	}
+
+	now := time.Now()
+	ticketType := &models.TicketType{
+		ID:        input.ID,
+		CreatedAt: now,
+	}
+	applyTicketTypeInput(ticketType, input, now)
+
+	ctx := requestContext(c)
+
+	err := h.ticketTypes.Create(ctx, ticketType)
+	if errors.Is(err, repository.ErrAlreadyExists) {
+		c.JSON(http.StatusConflict, TicketTypeResponse{
+			Success: false,
+			Message: "A ticket type with this id already exists",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, TicketTypeResponse{
+			Success: false,
+			Message: "Failed to create ticket type: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "ticket_type.create", audit.TargetTicketType, ticketType.ID, nil, ticketType)
+
+	c.JSON(http.StatusCreated, TicketTypeResponse{
+		Success:    true,
+		Message:    "Ticket type created successfully",
+		TicketType: ticketType,
+	})
+}
+
+// UpdateTicketType updates a ticket type (admins only)
+func (h *TicketTypeHandler) UpdateTicketType(c *gin.Context) {
+	input, ok := bindTicketTypeInput(c)
+	if !ok {
+		return
+	}
+
+	ctx := requestContext(c)
+
+	ticketType, err := h.ticketTypes.Get(ctx, c.Param("id"))
+	if err != nil {
+		h.respondLookupError(c, err)
+		return
+	}
+
+	before := *ticketType
+	applyTicketTypeInput(ticketType, input, time.Now())
+
+	err = h.ticketTypes.Update(ctx, ticketType)
+	// Update reports the current sold count, which is not edited here
+	before.Sold = ticketType.Sold
+	if errors.Is(err, repository.ErrQuantityBelowSold) {
+		c.JSON(http.StatusConflict, TicketTypeResponse{
+			Success:    false,
+			Message:    "Quantity cannot be lower than the number of tickets already sold",
+			TicketType: &before,
+		})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		h.respondLookupError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, TicketTypeResponse{
+			Success: false,
+			Message: "Failed to update ticket type: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "ticket_type.update", audit.TargetTicketType, ticketType.ID, &before, ticketType)
+
+	c.JSON(http.StatusOK, TicketTypeResponse{
+		Success:    true,
+		Message:    "Ticket type updated successfully",
+		TicketType: ticketType,
+	})
+}
+
+// DeleteTicketType removes a ticket type that has not been sold (admins only)
+func (h *TicketTypeHandler) DeleteTicketType(c *gin.Context) {
+	ctx := requestContext(c)
+
+	ticketType, err := h.ticketTypes.Get(ctx, c.Param("id"))
+	if err != nil {
+		h.respondLookupError(c, err)
+		return
+	}
+
+	// Registrations reference ticket types by ID; deactivate sold types instead
+	err = h.ticketTypes.Delete(ctx, ticketType.ID)
+	if errors.Is(err, repository.ErrInUse) {
+		c.JSON(http.StatusConflict, TicketTypeResponse{
+			Success:    false,
+			Message:    "Ticket type has sold tickets. Deactivate it instead",
+			TicketType: ticketType,
+		})
+		return
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		h.respondLookupError(c, err)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, TicketTypeResponse{
+			Success: false,
+			Message: "Failed to delete ticket type: " + err.Error(),
+		})
+		return
+	}
+
+	h.auditLog.Record(c, "ticket_type.delete", audit.TargetTicketType, ticketType.ID, ticketType, nil)
+
+	c.JSON(http.StatusOK, TicketTypeResponse{
+		Success: true,
+		Message: "Ticket type deleted successfully",
+	})
+}
+
+// respondLookupError writes a 404 for missing ticket types and a 500 otherwise
+func (h *TicketTypeHandler) respondLookupError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, TicketTypeResponse{
+			Success: false,
+			Message: "Ticket type not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusInternalServerError, TicketTypeResponse{
+		Success: false,
+		Message: "Failed to retrieve ticket type: " + err.Error(),
+	})
+}
+
+// bindTicketTypeInput binds and validates the ticket type request body
+func bindTicketTypeInput(c *gin.Context) (*models.TicketTypeInput, bool) {
+	var input models.TicketTypeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, TicketTypeResponse{
+			Success: false,
+			Message: "Invalid request: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if !input.SaleStart.IsZero() && !input.SaleEnd.IsZero() && !input.SaleEnd.After(input.SaleStart) {
+		c.JSON(http.StatusBadRequest, TicketTypeResponse{
+			Success: false,
+			Message: "Invalid request: saleEnd must be after saleStart",
+		})
+		return nil, false
+	}
+
+	return &input, true
+}
+
+// applyTicketTypeInput copies input fields onto ticketType
+func applyTicketTypeInput(ticketType *models.TicketType, input *models.TicketTypeInput, now time.Time) {
+	ticketType.Name = input.Name
+	ticketType.Description = input.Description
+	ticketType.Price = input.Price
+	ticketType.Currency = strings.ToUpper(input.Currency)
+	ticketType.Quantity = input.Quantity
+	ticketType.SaleStart = input.SaleStart
+	ticketType.SaleEnd = input.SaleEnd
+	ticketType.EligibleEmailDomains = input.EligibleEmailDomains
+	ticketType.EligibleCountries = input.EligibleCountries
+	ticketType.Active = input.Active
+	ticketType.UpdatedAt = now
+}
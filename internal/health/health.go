@@ -0,0 +1,119 @@
+// Package health runs the dependency checks behind the readiness probe.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Statuses of a check and of a report
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc reports whether a dependency is usable. It should return once
+// ctx is done.
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult is the outcome of one check. Error is kept out of responses
+// because it may reveal details of the deployment.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"-"`
+}
+
+// Report is the outcome of all checks. Status is down if any check is.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Up reports whether every check passed
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+// Checker runs named checks concurrently, each bounded by a timeout
+type Checker struct {
+	timeout time.Duration
+	names   []string
+	checks  map[string]CheckFunc
+}
+
+// NewChecker creates a checker whose checks may each take up to timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		checks:  make(map[string]CheckFunc),
+	}
+}
+
+// Add registers a check under name. It must be called before Run is.
+func (c *Checker) Add(name string, check CheckFunc) {
+	if _, ok := c.checks[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.checks[name] = check
+}
+
+// Run runs every check and waits for all of them
+func (c *Checker) Run(ctx context.Context) Report {
+	results := make([]CheckResult, len(c.names))
+
+	var wg sync.WaitGroup
+	for i, name := range c.names {
+		wg.Add(1)
+		go func(i int, check CheckFunc) {
+			defer wg.Done()
+			results[i] = c.run(ctx, check)
+		}(i, c.checks[name])
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(c.names))}
+	for i, name := range c.names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// run runs one check with the timeout. A check that ignores its context is
+// abandoned, not waited for, once the timeout expires.
+func (c *Checker) run(ctx context.Context, check CheckFunc) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		if ctx.Err() == context.DeadlineExceeded {
+			result.Error = fmt.Sprintf("timed out after %s", c.timeout)
+		} else {
+			result.Error = err.Error()
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+// Package logging configures structured JSON logging with log/slog. Records
+// carry the request ID and authenticated UID stored in their context, and
+// personal data and credentials are redacted before they are written.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// contextKey is the type of the context keys of this package
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	uidKey
+)
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// WithUID returns a copy of ctx carrying the authenticated user's UID
+func WithUID(ctx context.Context, uid string) context.Context {
+	return context.WithValue(ctx, uidKey, uid)
+}
+
+// UID returns the authenticated user's UID carried by ctx, or ""
+func UID(ctx context.Context) string {
+	uid, _ := ctx.Value(uidKey).(string)
+	return uid
+}
+
+// New returns a logger writing JSON records at or above level to w
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel maps debug, info, warn and error to a level, defaulting to info
+func ParseLevel(s string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// contextHandler adds the request ID and UID found in the record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+// Handle implements slog.Handler
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := RequestID(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if uid := UID(ctx); uid != "" {
+			r.AddAttrs(slog.String("uid", uid))
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces sensitive values in log records
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are always redacted,
+// compared case-insensitively with "-" and "_" removed
+var sensitiveKeys = map[string]bool{
+	"email":         true,
+	"phone":         true,
+	"token":         true,
+	"idtoken":       true,
+	"authorization": true,
+	"password":      true,
+	"secret":        true,
+	"cookie":        true,
+}
+
+// sensitivePatterns match personal data and credentials inside free text
+var sensitivePatterns = []*regexp.Regexp{
+	// Bearer credentials and JWTs such as Firebase ID tokens
+	regexp.MustCompile(`(?i)bearer\s+\S+`),
+	regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	// Email addresses
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	// Phone numbers in international or North American notation
+	regexp.MustCompile(`\+\d[\d\s().-]{6,}\d`),
+	regexp.MustCompile(`\(\d{3}\)\s?\d{3}[-.\s]\d{4}`),
+	regexp.MustCompile(`\b\d{3}[-.\s]\d{3}[-.\s]\d{4}\b`),
+}
+
+// Redact replaces personal data and credentials in s
+func Redact(s string) string {
+	for _, pattern := range sensitivePatterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// redactAttr is the slog ReplaceAttr hook. It hides the values of sensitive
+// keys and scrubs strings, including the message and error texts.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Redact(v.String()))
+		}
+	}
+	return a
+}
+
+// isSensitiveKey reports whether values logged under key must be hidden
+func isSensitiveKey(key string) bool {
+	normalized := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	return sensitiveKeys[normalized]
+}
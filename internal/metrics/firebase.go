@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Firebase records the latency and failures of calls to Firebase Auth and
+// Firestore. It implements firebase.CallObserver.
+type Firebase struct {
+	duration *prometheus.HistogramVec
+	errors   *prometheus.CounterVec
+}
+
+// NewFirebase registers the Firebase call metrics with reg
+func NewFirebase(reg prometheus.Registerer) *Firebase {
+	m := &Firebase{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "firebase_call_duration_seconds",
+			Help:    "Latency of Firebase calls, by service and operation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"service", "operation"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "firebase_call_errors_total",
+			Help: "Failed Firebase calls, by service and operation.",
+		}, []string{"service", "operation"}),
+	}
+	reg.MustRegister(m.duration, m.errors)
+	return m
+}
+
+// ObserveCall records one call to operation of service
+func (m *Firebase) ObserveCall(service, operation string, d time.Duration, err error) {
+	m.duration.WithLabelValues(service, operation).Observe(d.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(service, operation).Inc()
+	}
+}
@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// countTimeout bounds a recount of the registrations during a scrape
+const countTimeout = 30 * time.Second
+
+// registrationCount is the number of registrations of one ticket type in one
+// payment status
+type registrationCount struct {
+	ticketType    string
+	paymentStatus string
+}
+
+// registrationsCollector exposes the registrations gauge. Counting reads
+// every registration, so the result is reused for refresh; if a recount
+// fails, the previous counts are served.
+type registrationsCollector struct {
+	registrations repository.RegistrationRepository
+	refresh       time.Duration
+	desc          *prometheus.Desc
+
+	mu        sync.Mutex
+	counts    map[registrationCount]int
+	countedAt time.Time
+}
+
+// RegisterRegistrations registers a gauge of the registrations by ticket type
+// and payment status, recounted at most every refresh
+func RegisterRegistrations(reg prometheus.Registerer, registrations repository.RegistrationRepository, refresh time.Duration) {
+	reg.MustRegister(&registrationsCollector{
+		registrations: registrations,
+		refresh:       refresh,
+		desc: prometheus.NewDesc("registrations",
+			"Registrations, by ticket type and payment status.",
+			[]string{"ticket_type", "payment_status"}, nil),
+	})
+}
+
+// Describe implements prometheus.Collector
+func (c *registrationsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c *registrationsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.counts == nil || time.Since(c.countedAt) >= c.refresh {
+		ctx, cancel := context.WithTimeout(context.Background(), countTimeout)
+		defer cancel()
+
+		fresh := make(map[registrationCount]int)
+		err := c.registrations.Each(ctx, repository.RegistrationFilter{}, func(r *models.Registration) error {
+			fresh[registrationCount{ticketType: r.TicketType, paymentStatus: r.PaymentStatus}]++
+			return nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to count registrations for metrics", "error", err)
+			if c.counts == nil {
+				ch <- prometheus.NewInvalidMetric(c.desc, err)
+				return
+			}
+		} else {
+			c.counts = fresh
+			c.countedAt = time.Now()
+		}
+	}
+
+	for k, n := range c.counts {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n), k.ticketType, k.paymentStatus)
+	}
+}
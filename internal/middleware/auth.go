@@ -2,24 +2,84 @@ package middleware
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
+	"backend-ITC/internal/cache"
 	"backend-ITC/internal/firebase"
+	"backend-ITC/internal/logging"
 	"backend-ITC/internal/models"
+	"backend-ITC/internal/repository"
 
+	"firebase.google.com/go/auth"
 	"github.com/gin-gonic/gin"
 )
 
 // AuthMiddleware handles authentication middleware
 type AuthMiddleware struct {
-	firebaseClient *firebase.Client
+	verifier  firebase.TokenVerifier
+	directory firebase.UserDirectory
+	users     repository.UserRepository
+
+	cacheTTL time.Duration
+	tokens   *cache.Cache[string, *auth.Token]
+	profiles *cache.Cache[string, *cachedProfile]
+}
+
+// cachedProfile is a user's profile together with the time before which
+// their ID tokens were revoked, in Unix milliseconds
+type cachedProfile struct {
+	user             *models.User
+	tokensValidAfter int64
 }
 
-// NewAuthMiddleware creates a new auth middleware instance
-func NewAuthMiddleware(fc *firebase.Client) *AuthMiddleware {
+// errTokenRevoked is returned for ID tokens issued before the user's
+// tokens were revoked
+var errTokenRevoked = errors.New("middleware: token has been revoked")
+
+// AuthCacheConfig bounds the caches of verified tokens and user profiles.
+// Entries live for at most TTL, and tokens never outlive their expiry.
+// A zero TTL or MaxEntries disables caching.
+type AuthCacheConfig struct {
+	TTL        time.Duration
+	MaxEntries int
+}
+
+// AuthCacheStats are the counters of the auth caches
+type AuthCacheStats struct {
+	Tokens   cache.Stats `json:"tokens"`
+	Profiles cache.Stats `json:"profiles"`
+}
+
+// NewAuthMiddleware creates a new auth middleware instance that verifies
+// tokens with verifier and looks users up in directory
+func NewAuthMiddleware(verifier firebase.TokenVerifier, directory firebase.UserDirectory, users repository.UserRepository, cacheCfg AuthCacheConfig) *AuthMiddleware {
 	return &AuthMiddleware{
-		firebaseClient: fc,
+		verifier:  verifier,
+		directory: directory,
+		users:     users,
+		cacheTTL:  cacheCfg.TTL,
+		tokens:    cache.New[string, *auth.Token](cacheCfg.MaxEntries),
+		profiles:  cache.New[string, *cachedProfile](cacheCfg.MaxEntries),
+	}
+}
+
+// InvalidateUser drops the cached profile of uid. It must be called after
+// the stored profile changes or the user's tokens are revoked.
+func (m *AuthMiddleware) InvalidateUser(uid string) {
+	m.profiles.Delete(uid)
+}
+
+// CacheStats returns the hit and miss counters of the auth caches
+func (m *AuthMiddleware) CacheStats() AuthCacheStats {
+	return AuthCacheStats{
+		Tokens:   m.tokens.Stats(),
+		Profiles: m.profiles.Stats(),
 	}
 }
 
@@ -48,10 +108,10 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 		}
 
 		idToken := tokenParts[1]
-		ctx := context.Background()
+		ctx := c.Request.Context()
 
 		// Verify the Firebase ID token
-		token, err := m.firebaseClient.VerifyIDToken(ctx, idToken)
+		token, err := m.verifyToken(ctx, idToken)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
@@ -61,9 +121,18 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
-		// Get user info from Firebase Auth
-		userRecord, err := m.firebaseClient.GetUser(ctx, token.UID)
+		// Load the user from Firebase Auth merged with the stored profile
+		user, err := m.loadUser(ctx, token)
+		if errors.Is(err, errTokenRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Token has been revoked, please sign in again",
+			})
+			c.Abort()
+			return
+		}
 		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load authenticated user", "uid", token.UID, "error", err)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"message": "Failed to retrieve user information",
@@ -72,32 +141,11 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return
 		}
 
-		// Create user object to pass to handlers
-		user := &models.User{
-			UID:           userRecord.UID,
-			Email:         userRecord.Email,
-			DisplayName:   userRecord.DisplayName,
-			PhotoURL:      userRecord.PhotoURL,
-			EmailVerified: userRecord.EmailVerified,
-		}
-
-		// Try to get additional user data from Firestore
-		doc, err := m.firebaseClient.Firestore.Collection("users").Doc(token.UID).Get(ctx)
-		if err == nil && doc.Exists() {
-			var firestoreUser models.User
-			if err := doc.DataTo(&firestoreUser); err == nil {
-				// Merge Firestore data with Auth data
-				user.CreatedAt = firestoreUser.CreatedAt
-				user.UpdatedAt = firestoreUser.UpdatedAt
-				user.LastLoginAt = firestoreUser.LastLoginAt
-				user.Provider = firestoreUser.Provider
-			}
-		}
-
-		// Set user in context for handlers to use
+		// Set user in context for handlers to use and for logging
 		c.Set("user", user)
 		c.Set("uid", token.UID)
 		c.Set("token", token)
+		c.Request = c.Request.WithContext(logging.WithUID(ctx, token.UID))
 
 		c.Next()
 	}
@@ -123,36 +171,129 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 		}
 
 		idToken := tokenParts[1]
-		ctx := context.Background()
+		ctx := c.Request.Context()
 
 		// Verify the Firebase ID token
-		token, err := m.firebaseClient.VerifyIDToken(ctx, idToken)
+		token, err := m.verifyToken(ctx, idToken)
 		if err != nil {
 			// Invalid token, continue without user context
 			c.Next()
 			return
 		}
 
-		// Get user info from Firebase Auth
-		userRecord, err := m.firebaseClient.GetUser(ctx, token.UID)
+		user, err := m.loadUser(ctx, token)
 		if err != nil {
 			c.Next()
 			return
 		}
 
-		// Create user object
-		user := &models.User{
-			UID:           userRecord.UID,
-			Email:         userRecord.Email,
-			DisplayName:   userRecord.DisplayName,
-			PhotoURL:      userRecord.PhotoURL,
-			EmailVerified: userRecord.EmailVerified,
-		}
-
 		// Set user in context
 		c.Set("user", user)
 		c.Set("uid", token.UID)
 		c.Set("token", token)
+		c.Request = c.Request.WithContext(logging.WithUID(ctx, token.UID))
+
+		c.Next()
+	}
+}
+
+// verifyToken verifies idToken, reusing the result of an earlier verification
+// until the token expires or the cache TTL passes
+func (m *AuthMiddleware) verifyToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	// Key by hash so raw bearer tokens are not kept in memory
+	sum := sha256.Sum256([]byte(idToken))
+	key := hex.EncodeToString(sum[:])
+
+	if token, ok := m.tokens.Get(key); ok {
+		if time.Now().Before(time.Unix(token.Expires, 0)) {
+			return token, nil
+		}
+		m.tokens.Delete(key)
+	}
+
+	token, err := m.verifier.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := time.Until(time.Unix(token.Expires, 0))
+	if ttl > m.cacheTTL {
+		ttl = m.cacheTTL
+	}
+	m.tokens.Set(key, token, ttl)
+
+	return token, nil
+}
+
+// loadUser returns the Firebase Auth user of token merged with the stored
+// profile, or errTokenRevoked if the user's tokens were revoked after token
+// was issued. Roles always come from the token's claims. The result is a
+// copy that handlers may modify.
+func (m *AuthMiddleware) loadUser(ctx context.Context, token *auth.Token) (*models.User, error) {
+	cached, ok := m.profiles.Get(token.UID)
+	if !ok {
+		// Get user info from Firebase Auth
+		userRecord, err := m.directory.GetUser(ctx, token.UID)
+		if err != nil {
+			return nil, err
+		}
+
+		cached = &cachedProfile{
+			user: &models.User{
+				UID:           userRecord.UID,
+				Email:         userRecord.Email,
+				DisplayName:   userRecord.DisplayName,
+				PhotoURL:      userRecord.PhotoURL,
+				EmailVerified: userRecord.EmailVerified,
+			},
+			tokensValidAfter: userRecord.TokensValidAfterMillis,
+		}
+
+		// Try to get additional user data from the stored profile
+		if storedUser, err := m.users.Get(ctx, token.UID); err == nil {
+			// Merge stored profile with Auth data
+			cached.user.CreatedAt = storedUser.CreatedAt
+			cached.user.UpdatedAt = storedUser.UpdatedAt
+			cached.user.LastLoginAt = storedUser.LastLoginAt
+			cached.user.Provider = storedUser.Provider
+		}
+
+		m.profiles.Set(token.UID, cached, m.cacheTTL)
+	}
+
+	// Checked on every request, so cached tokens are covered as well
+	if token.IssuedAt*1000 < cached.tokensValidAfter {
+		return nil, errTokenRevoked
+	}
+
+	user := *cached.user
+	user.Roles = models.RolesFromClaims(token.Claims)
+	return &user, nil
+}
+
+// RequireRole creates a middleware that only allows users holding at least one
+// of the given roles. It must run after RequireAuth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userVal, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "User not authenticated",
+			})
+			c.Abort()
+			return
+		}
+
+		user, ok := userVal.(*models.User)
+		if !ok || !user.HasRole(roles...) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Insufficient permissions",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
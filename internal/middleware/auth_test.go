@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/auth"
+)
+
+// countingVerifier accepts every token, expiring expiresIn after each call
+type countingVerifier struct {
+	expiresIn time.Duration
+	calls     int
+}
+
+func (v *countingVerifier) VerifyIDToken(_ context.Context, idToken string) (*auth.Token, error) {
+	v.calls++
+	return &auth.Token{UID: idToken, Expires: time.Now().Add(v.expiresIn).Unix()}, nil
+}
+
+func TestVerifyTokenCaching(t *testing.T) {
+	tests := []struct {
+		name      string
+		cacheTTL  time.Duration
+		expiresIn time.Duration
+		wait      time.Duration
+		calls     int
+		hits      uint64
+	}{
+		{"cached within TTL and expiry", time.Hour, time.Hour, 0, 1, 1},
+		{"TTL passed", 10 * time.Millisecond, time.Hour, 20 * time.Millisecond, 2, 0},
+		{"capped at expiry", time.Hour, 2 * time.Second, 2 * time.Second, 2, 0},
+		{"expired tokens are not cached", time.Hour, -time.Second, 0, 2, 0},
+		{"caching disabled", 0, time.Hour, 0, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := &countingVerifier{expiresIn: tt.expiresIn}
+			m := NewAuthMiddleware(verifier, nil, nil, AuthCacheConfig{TTL: tt.cacheTTL, MaxEntries: 10})
+			ctx := context.Background()
+
+			if _, err := m.verifyToken(ctx, "token"); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(tt.wait)
+			if _, err := m.verifyToken(ctx, "token"); err != nil {
+				t.Fatal(err)
+			}
+
+			if verifier.calls != tt.calls {
+				t.Errorf("verifier called %d times, want %d", verifier.calls, tt.calls)
+			}
+			// A hit on an entry past the token's expiry would mean the cache
+			// TTL was not capped
+			if got := m.CacheStats().Tokens.Hits; got != tt.hits {
+				t.Errorf("cache hits = %d, want %d", got, tt.hits)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"backend-ITC/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID that correlates a request with its logs and
+// audit entries
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID creates a middleware that tags each request with an ID. A valid
+// X-Request-ID from the client is kept, otherwise a random one is generated.
+// The ID is echoed in the response header and stored in the request context
+// for logging.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+	}
+}
+
+// AccessLog creates a middleware that writes one structured record per
+// request to logger. It must run after RequestID.
+func AccessLog(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", c.Errors.String()))
+		}
+
+		// The request context now also carries the UID set by RequireAuth
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+// Recovery creates a middleware that turns panics into 500 responses and
+// logs them with their stack trace to logger
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logger.ErrorContext(c.Request.Context(), "Recovered from panic",
+			"panic", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Internal server error",
+		})
+	})
+}
+
+// validRequestID reports whether a client-supplied ID is safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		ch := id[i]
+		if ch < 0x21 || ch > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return hex.EncodeToString([]byte(time.Now().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
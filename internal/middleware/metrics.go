@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"backend-ITC/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics creates a middleware that records the count and latency of
+// requests by route pattern and status
+func Metrics(m *metrics.HTTP) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.ObserveRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// RequireBearerToken creates a middleware that only lets through requests
+// presenting token as a bearer credential. An empty token allows everyone.
+func RequireBearerToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		presented := []byte(c.GetHeader("Authorization"))
+		expected := []byte("Bearer " + token)
+		if subtle.ConstantTimeCompare(presented, expected) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Invalid or missing token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
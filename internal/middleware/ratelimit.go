@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit configures a token bucket: it holds up to Burst tokens and is
+// refilled at Rate tokens per second. Each request takes one token.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// Enabled reports whether the limit allows any requests to be throttled
+func (l RateLimit) Enabled() bool {
+	return l.Rate > 0 && l.Burst > 0
+}
+
+// RateLimitStore keeps the token buckets of a rate limiter. Implementations
+// must be safe for concurrent use; a shared backend such as Redis lets several
+// server instances enforce one limit.
+type RateLimitStore interface {
+	// Take removes a token from the bucket for key, creating a full bucket
+	// if none exists. When the bucket is empty it returns false and the time
+	// until the next token is available.
+	Take(ctx context.Context, key string, limit RateLimit) (bool, time.Duration, error)
+}
+
+// KeyFunc returns the rate limiting key of a request, or "" to skip limiting
+type KeyFunc func(c *gin.Context) string
+
+// KeyByIP limits requests per client IP
+func KeyByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// KeyByUID limits requests per authenticated user, falling back to the
+// client IP for anonymous requests. It must run after RequireAuth.
+func KeyByUID(c *gin.Context) string {
+	if uid := c.GetString("uid"); uid != "" {
+		return "uid:" + uid
+	}
+	return KeyByIP(c)
+}
+
+// RateLimiter creates a middleware that rejects requests with 429 Too Many
+// Requests once the bucket for their key is empty. scope namespaces the keys
+// so that several limiters can share a store. If the store fails, requests
+// are let through rather than taking the API down.
+func RateLimiter(store RateLimitStore, scope string, limit RateLimit, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limit.Enabled() {
+			c.Next()
+			return
+		}
+
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		allowed, retryAfter, err := store.Take(c.Request.Context(), scope+":"+k, limit)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Rate limiter store failed, allowing request", "error", err)
+			c.Next()
+			return
+		}
+
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"message": "Too many requests. Please retry later",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// memoryBucket is the state of one token bucket
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	// fullAt is when the bucket will have refilled completely
+	fullAt time.Time
+}
+
+// memorySweepInterval is how often idle buckets are dropped from memory
+const memorySweepInterval = time.Minute
+
+// MemoryRateLimitStore is a RateLimitStore local to the process. Each server
+// instance enforces its own limits.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*memoryBucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, limit RateLimit) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	// Refill for the time elapsed since the last request
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, refillTime(1-b.tokens, limit.Rate), nil
+	}
+
+	b.tokens--
+	b.fullAt = now.Add(refillTime(float64(limit.Burst)-b.tokens, limit.Rate))
+	return true, 0, nil
+}
+
+// sweep periodically drops buckets that have refilled completely, since a
+// missing bucket behaves the same as a full one. Callers must hold s.mu.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// refillTime returns how long a bucket takes to gain tokens at rate per second
+func refillTime(tokens, rate float64) time.Duration {
+	return time.Duration(tokens / rate * float64(time.Second))
+}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRefillTime(t *testing.T) {
+	tests := []struct {
+		tokens float64
+		rate   float64
+		want   time.Duration
+	}{
+		{1, 1, time.Second},
+		{1, 2, 500 * time.Millisecond},
+		{1, 0.5, 2 * time.Second},
+		{0.25, 1, 250 * time.Millisecond},
+		{10, 5, 2 * time.Second},
+		{0, 1, 0},
+	}
+	for _, tt := range tests {
+		if got := refillTime(tt.tokens, tt.rate); got != tt.want {
+			t.Errorf("refillTime(%v, %v) = %v, want %v", tt.tokens, tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreRefill(t *testing.T) {
+	limit := RateLimit{Rate: 2, Burst: 3}
+
+	tests := []struct {
+		name      string
+		tokens    float64
+		elapsed   time.Duration
+		allowed   bool
+		remaining float64
+		wait      time.Duration
+	}{
+		{"full bucket", 3, 0, true, 2, 0},
+		{"capped at burst", 3, time.Hour, true, 2, 0},
+		{"refilled one token", 0, 500 * time.Millisecond, true, 0, 0},
+		{"refilled half a token", 0, 250 * time.Millisecond, false, 0.5, 250 * time.Millisecond},
+		{"empty", 0, 0, false, 0, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMemoryRateLimitStore()
+			// Back-date the bucket instead of waiting for it to refill
+			s.buckets["k"] = &memoryBucket{tokens: tt.tokens, last: time.Now().Add(-tt.elapsed)}
+
+			allowed, wait, err := s.Take(context.Background(), "k", limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v", allowed, tt.allowed)
+			}
+			// Allow for the time that passed during the call
+			const slack = 10 * time.Millisecond
+			if wait > tt.wait || wait < tt.wait-slack {
+				t.Errorf("wait = %v, want %v", wait, tt.wait)
+			}
+			if got := s.buckets["k"].tokens; got < tt.remaining || got > tt.remaining+slack.Seconds()*limit.Rate {
+				t.Errorf("tokens left = %v, want %v", got, tt.remaining)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStoreBurst(t *testing.T) {
+	s := NewMemoryRateLimitStore()
+	limit := RateLimit{Rate: 0.001, Burst: 3}
+	ctx := context.Background()
+
+	for i := 0; i < limit.Burst; i++ {
+		if allowed, _, _ := s.Take(ctx, "a", limit); !allowed {
+			t.Fatalf("request %d rejected within the burst", i+1)
+		}
+	}
+	if allowed, _, _ := s.Take(ctx, "a", limit); allowed {
+		t.Error("request beyond the burst allowed")
+	}
+	if allowed, _, _ := s.Take(ctx, "b", limit); !allowed {
+		t.Error("another key shares the bucket")
+	}
+}
+
+// stubRateLimitStore answers every Take with fixed values
+type stubRateLimitStore struct {
+	allowed    bool
+	retryAfter time.Duration
+	err        error
+	keys       []string
+}
+
+func (s *stubRateLimitStore) Take(_ context.Context, key string, _ RateLimit) (bool, time.Duration, error) {
+	s.keys = append(s.keys, key)
+	return s.allowed, s.retryAfter, s.err
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limit := RateLimit{Rate: 1, Burst: 1}
+
+	tests := []struct {
+		name       string
+		store      *stubRateLimitStore
+		limit      RateLimit
+		key        string
+		status     int
+		retryAfter string
+	}{
+		{"allowed", &stubRateLimitStore{allowed: true}, limit, "k", http.StatusOK, ""},
+		{"sub-second wait rounds up", &stubRateLimitStore{retryAfter: 200 * time.Millisecond}, limit, "k", http.StatusTooManyRequests, "1"},
+		{"zero wait is one second", &stubRateLimitStore{}, limit, "k", http.StatusTooManyRequests, "1"},
+		{"fractional wait rounds up", &stubRateLimitStore{retryAfter: 1500 * time.Millisecond}, limit, "k", http.StatusTooManyRequests, "2"},
+		{"whole seconds", &stubRateLimitStore{retryAfter: 3 * time.Second}, limit, "k", http.StatusTooManyRequests, "3"},
+		{"store failure allows", &stubRateLimitStore{err: errors.New("down")}, limit, "k", http.StatusOK, ""},
+		{"disabled limit", &stubRateLimitStore{}, RateLimit{}, "k", http.StatusOK, ""},
+		{"empty key", &stubRateLimitStore{}, limit, "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			key := func(*gin.Context) string { return tt.key }
+			r.GET("/", RateLimiter(tt.store, "test", tt.limit, key), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+			if len(tt.store.keys) > 0 && tt.store.keys[0] != "test:k" {
+				t.Errorf("store key = %q, want test:k", tt.store.keys[0])
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // User represents a user in the system (linked to Firebase Auth)
 type User struct {
@@ -13,6 +16,63 @@ type User struct {
 	CreatedAt     time.Time `json:"createdAt" firestore:"createdAt"`
 	UpdatedAt     time.Time `json:"updatedAt" firestore:"updatedAt"`
 	LastLoginAt   time.Time `json:"lastLoginAt" firestore:"lastLoginAt"`
+	Roles         []string  `json:"roles,omitempty" firestore:"-"` // from Firebase custom claims
+}
+
+// Roles granted through Firebase custom claims
+const (
+	RoleAdmin        = "admin"
+	RoleOrganizer    = "organizer"
+	RoleCheckInStaff = "checkin_staff"
+)
+
+// RolesClaim is the custom claim key that holds a user's roles
+const RolesClaim = "roles"
+
+// ValidRoles lists every role that can be granted
+var ValidRoles = []string{RoleAdmin, RoleOrganizer, RoleCheckInStaff}
+
+// IsValidRole reports whether role is a known role
+func IsValidRole(role string) bool {
+	for _, r := range ValidRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RolesFromClaims extracts the roles custom claim from decoded token claims
+func RolesFromClaims(claims map[string]interface{}) []string {
+	var roles []string
+	switch v := claims[RolesClaim].(type) {
+	case []interface{}:
+		for _, r := range v {
+			if role, ok := r.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	case []string:
+		roles = append(roles, v...)
+	}
+	return roles
+}
+
+// HasRole reports whether the user has any of the given roles
+func (u *User) HasRole(roles ...string) bool {
+	for _, have := range u.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RoleInput is used for granting a role to a user
+type RoleInput struct {
+	Role string `json:"role" binding:"required"`
 }
 
 // Registration represents a conference registration
@@ -29,14 +89,35 @@ type Registration struct {
 	City             string    `json:"city" firestore:"city"`
 	DietaryReqs      string    `json:"dietaryRequirements" firestore:"dietaryRequirements"`
 	SpecialNeeds     string    `json:"specialNeeds" firestore:"specialNeeds"`
-	TicketType       string    `json:"ticketType" firestore:"ticketType"` // standard, vip, student, etc.
+	TicketType       string    `json:"ticketType" firestore:"ticketType"` // TicketType ID: standard, vip, student, etc.
 	SessionsOfInt    []string  `json:"sessionsOfInterest" firestore:"sessionsOfInterest"`
-	PaymentStatus    string    `json:"paymentStatus" firestore:"paymentStatus"` // pending, completed, refunded
+	PaymentStatus    string    `json:"paymentStatus" firestore:"paymentStatus"` // pending, completed, failed, refunded
+	AmountDue        int64     `json:"amountDue" firestore:"amountDue"`         // in minor units, e.g. cents
+	Currency         string    `json:"currency" firestore:"currency"`
+	PaymentRef       string    `json:"paymentRef" firestore:"paymentRef"` // provider checkout session ID
+	PromoCode        string    `json:"promoCode" firestore:"promoCode"`
+	Discount         int64     `json:"discount" firestore:"discount"` // in minor units, fixed when the code is redeemed
+	CheckedInAt      time.Time `json:"checkedInAt" firestore:"checkedInAt"`
+	CheckedInBy      string    `json:"checkedInBy" firestore:"checkedInBy"` // UID of the staff member who scanned the ticket
+	PaidAt           time.Time `json:"paidAt" firestore:"paidAt"`
 	RegistrationDate time.Time `json:"registrationDate" firestore:"registrationDate"`
 	CreatedAt        time.Time `json:"createdAt" firestore:"createdAt"`
 	UpdatedAt        time.Time `json:"updatedAt" firestore:"updatedAt"`
 }
 
+// IsCheckedIn reports whether the attendee has been checked in on site
+func (r *Registration) IsCheckedIn() bool {
+	return !r.CheckedInAt.IsZero()
+}
+
+// Payment statuses of a registration
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusCompleted = "completed"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusRefunded  = "refunded"
+)
+
 // RegistrationInput is used for creating/updating registrations
 type RegistrationInput struct {
 	FirstName     string   `json:"firstName" binding:"required"`
@@ -51,6 +132,12 @@ type RegistrationInput struct {
 	SpecialNeeds  string   `json:"specialNeeds"`
 	TicketType    string   `json:"ticketType" binding:"required"`
 	SessionsOfInt []string `json:"sessionsOfInterest"`
+	PromoCode     string   `json:"promoCode"`
+}
+
+// CheckInInput is used for scanning a ticket at check-in
+type CheckInInput struct {
+	Token string `json:"token" binding:"required"`
 }
 
 // Session represents a conference session
@@ -63,9 +150,248 @@ type Session struct {
 	StartTime   time.Time `json:"startTime" firestore:"startTime"`
 	EndTime     time.Time `json:"endTime" firestore:"endTime"`
 	Location    string    `json:"location" firestore:"location"`
-	Capacity    int       `json:"capacity" firestore:"capacity"`
+	Capacity    int       `json:"capacity" firestore:"capacity"` // 0 means unlimited
+	Enrolled    int       `json:"enrolled" firestore:"enrolled"`
 	Track       string    `json:"track" firestore:"track"` // technical, business, workshop, etc.
 	Tags        []string  `json:"tags" firestore:"tags"`
 	CreatedAt   time.Time `json:"createdAt" firestore:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt" firestore:"updatedAt"`
 }
+
+// IsFull reports whether the session has no remaining seats
+func (s *Session) IsFull() bool {
+	return s.Capacity > 0 && s.Enrolled >= s.Capacity
+}
+
+// Enrollment statuses
+const (
+	EnrollmentStatusEnrolled   = "enrolled"
+	EnrollmentStatusWaitlisted = "waitlisted"
+)
+
+// Enrollment represents an attendee's seat in a session, or their place on
+// its waitlist when the session is full
+type Enrollment struct {
+	ID             string    `json:"id" firestore:"-"`
+	SessionID      string    `json:"sessionId" firestore:"sessionId"`
+	UserID         string    `json:"userId" firestore:"userId"`
+	RegistrationID string    `json:"registrationId" firestore:"registrationId"`
+	FirstName      string    `json:"firstName" firestore:"firstName"`
+	LastName       string    `json:"lastName" firestore:"lastName"`
+	Email          string    `json:"email" firestore:"email"`
+	Status         string    `json:"status" firestore:"status"` // enrolled, waitlisted
+	CreatedAt      time.Time `json:"createdAt" firestore:"createdAt"`
+	PromotedAt     time.Time `json:"promotedAt" firestore:"promotedAt,omitempty"`
+}
+
+// IsWaitlisted reports whether the enrollment is waiting for a seat
+func (e *Enrollment) IsWaitlisted() bool {
+	return e.Status == EnrollmentStatusWaitlisted
+}
+
+// SessionInput is used for creating/updating sessions
+type SessionInput struct {
+	Title       string    `json:"title" binding:"required"`
+	Description string    `json:"description"`
+	Speaker     string    `json:"speaker" binding:"required"`
+	SpeakerBio  string    `json:"speakerBio"`
+	StartTime   time.Time `json:"startTime" binding:"required"`
+	EndTime     time.Time `json:"endTime" binding:"required"`
+	Location    string    `json:"location"`
+	Capacity    int       `json:"capacity" binding:"min=0"`
+	Track       string    `json:"track"`
+	Tags        []string  `json:"tags"`
+}
+
+// TicketType is a purchasable ticket in the catalog. Its ID is the code
+// stored in Registration.TicketType.
+type TicketType struct {
+	ID          string `json:"id" firestore:"-"`
+	Name        string `json:"name" firestore:"name"`
+	Description string `json:"description" firestore:"description"`
+	Price       int64  `json:"price" firestore:"price"` // in minor units, e.g. cents
+	Currency    string `json:"currency" firestore:"currency"`
+	Quantity    int    `json:"quantity" firestore:"quantity"` // 0 means unlimited
+	Sold        int    `json:"sold" firestore:"sold"`
+	// Sale window; zero values leave that side open
+	SaleStart time.Time `json:"saleStart" firestore:"saleStart"`
+	SaleEnd   time.Time `json:"saleEnd" firestore:"saleEnd"`
+	// Eligibility rules; empty lists allow everyone
+	EligibleEmailDomains []string  `json:"eligibleEmailDomains" firestore:"eligibleEmailDomains"`
+	EligibleCountries    []string  `json:"eligibleCountries" firestore:"eligibleCountries"`
+	Active               bool      `json:"active" firestore:"active"`
+	CreatedAt            time.Time `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt            time.Time `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// IsSoldOut reports whether every ticket of this type has been sold
+func (t *TicketType) IsSoldOut() bool {
+	return t.Quantity > 0 && t.Sold >= t.Quantity
+}
+
+// IsOnSale reports whether the ticket type can be bought at the given time
+func (t *TicketType) IsOnSale(now time.Time) bool {
+	if !t.Active {
+		return false
+	}
+	if !t.SaleStart.IsZero() && now.Before(t.SaleStart) {
+		return false
+	}
+	if !t.SaleEnd.IsZero() && !now.Before(t.SaleEnd) {
+		return false
+	}
+	return true
+}
+
+// IsEligible reports whether an attendee with the given email and country
+// may buy this ticket type
+func (t *TicketType) IsEligible(email, country string) bool {
+	if len(t.EligibleEmailDomains) > 0 {
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			return false
+		}
+		domain := strings.ToLower(email[at+1:])
+
+		matched := false
+		for _, d := range t.EligibleEmailDomains {
+			d = strings.ToLower(strings.TrimPrefix(d, "@"))
+			if domain == d || strings.HasSuffix(domain, "."+d) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(t.EligibleCountries) > 0 {
+		matched := false
+		for _, c := range t.EligibleCountries {
+			if strings.EqualFold(c, country) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TicketTypeInput is used for creating/updating ticket types
+type TicketTypeInput struct {
+	ID                   string    `json:"id"` // required on create; lowercase letters, digits, "-" and "_"
+	Name                 string    `json:"name" binding:"required"`
+	Description          string    `json:"description"`
+	Price                int64     `json:"price" binding:"min=0"`
+	Currency             string    `json:"currency" binding:"required,len=3"`
+	Quantity             int       `json:"quantity" binding:"min=0"`
+	SaleStart            time.Time `json:"saleStart"`
+	SaleEnd              time.Time `json:"saleEnd"`
+	EligibleEmailDomains []string  `json:"eligibleEmailDomains"`
+	EligibleCountries    []string  `json:"eligibleCountries"`
+	Active               bool      `json:"active"`
+}
+
+// Promo code discount types
+const (
+	DiscountTypePercent = "percent"
+	DiscountTypeFixed   = "fixed"
+)
+
+// PromoCode is a discount code redeemable during registration. Its ID is the
+// upper-case code attendees enter.
+type PromoCode struct {
+	ID           string    `json:"id" firestore:"-"`
+	Description  string    `json:"description" firestore:"description"`
+	DiscountType string    `json:"discountType" firestore:"discountType"` // percent, fixed
+	Value        int64     `json:"value" firestore:"value"`               // percent (1-100) or minor units
+	Currency     string    `json:"currency" firestore:"currency"`         // required for fixed discounts
+	TicketTypes  []string  `json:"ticketTypes" firestore:"ticketTypes"`   // empty means every ticket type
+	MaxUses      int       `json:"maxUses" firestore:"maxUses"`           // 0 means unlimited
+	Uses         int       `json:"uses" firestore:"uses"`
+	ExpiresAt    time.Time `json:"expiresAt" firestore:"expiresAt"` // zero means never
+	Active       bool      `json:"active" firestore:"active"`
+	CreatedAt    time.Time `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt" firestore:"updatedAt"`
+}
+
+// IsExhausted reports whether the code has reached its usage cap
+func (p *PromoCode) IsExhausted() bool {
+	return p.MaxUses > 0 && p.Uses >= p.MaxUses
+}
+
+// IsExpired reports whether the code has expired at the given time
+func (p *PromoCode) IsExpired(now time.Time) bool {
+	return !p.ExpiresAt.IsZero() && !now.Before(p.ExpiresAt)
+}
+
+// AppliesTo reports whether the code can be used with the ticket type
+func (p *PromoCode) AppliesTo(ticketType *TicketType) bool {
+	if p.DiscountType == DiscountTypeFixed && !strings.EqualFold(p.Currency, ticketType.Currency) {
+		return false
+	}
+	if len(p.TicketTypes) == 0 {
+		return true
+	}
+	for _, t := range p.TicketTypes {
+		if t == ticketType.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// DiscountFor returns the discount the code gives on price, never more than price
+func (p *PromoCode) DiscountFor(price int64) int64 {
+	var discount int64
+	switch p.DiscountType {
+	case DiscountTypePercent:
+		discount = price * p.Value / 100
+	case DiscountTypeFixed:
+		discount = p.Value
+	}
+	if discount > price {
+		return price
+	}
+	return discount
+}
+
+// PromoCodeInput is used for creating/updating promo codes
+type PromoCodeInput struct {
+	ID           string    `json:"id"` // required on create
+	Description  string    `json:"description"`
+	DiscountType string    `json:"discountType" binding:"required,oneof=percent fixed"`
+	Value        int64     `json:"value" binding:"required,min=1"`
+	Currency     string    `json:"currency"`
+	TicketTypes  []string  `json:"ticketTypes"`
+	MaxUses      int       `json:"maxUses" binding:"min=0"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Active       bool      `json:"active"`
+}
+
+// AuditEntry records one change made through the API. Entries are append-only
+// and are never modified or deleted.
+type AuditEntry struct {
+	ID         string        `json:"id" firestore:"-"`
+	ActorUID   string        `json:"actorUid" firestore:"actorUid"` // empty for unauthenticated callers such as payment webhooks
+	Action     string        `json:"action" firestore:"action"`     // e.g. registration.update
+	TargetType string        `json:"targetType" firestore:"targetType"`
+	TargetID   string        `json:"targetId" firestore:"targetId"`
+	Changes    []AuditChange `json:"changes" firestore:"changes"`
+	IP         string        `json:"ip" firestore:"ip"`
+	RequestID  string        `json:"requestId" firestore:"requestId"`
+	CreatedAt  time.Time     `json:"createdAt" firestore:"createdAt"`
+}
+
+// AuditChange is the before and after value of one changed field, using the
+// field's JSON name. Before is nil for created fields and After for removed ones.
+type AuditChange struct {
+	Field  string      `json:"field" firestore:"field"`
+	Before interface{} `json:"before" firestore:"before"`
+	After  interface{} `json:"after" firestore:"after"`
+}
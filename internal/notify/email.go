@@ -0,0 +1,307 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"backend-ITC/internal/models"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// Template names; each has a <name>.txt.tmpl and <name>.html.tmpl file. The
+// text template also defines the "<name>.subject" block.
+const (
+	templateRegistrationConfirmed = "registration_confirmed"
+	templateRegistrationUpdated   = "registration_updated"
+	templateRegistrationCancelled = "registration_cancelled"
+	templatePaymentReceipt        = "payment_receipt"
+	templateSessionChanged        = "session_changed"
+	templateSessionCancelled      = "session_cancelled"
+	templateWaitlistPromoted      = "waitlist_promoted"
+)
+
+// ErrQueueFull is returned when too many emails are waiting to be sent.
+var ErrQueueFull = errors.New("notify: email queue is full")
+
+// ErrClosed is returned when an email is sent after the notifier is closed.
+var ErrClosed = errors.New("notify: notifier is closed")
+
+// EmailConfig configures an EmailNotifier.
+type EmailConfig struct {
+	// ConferenceName is used in subjects and greetings
+	ConferenceName string
+	// QueueSize bounds the number of emails waiting to be sent
+	QueueSize int
+	// MaxAttempts is how many times a failed send is tried in total
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles each attempt
+	RetryBackoff time.Duration
+}
+
+// EmailNotifier renders templated emails and sends them through a Mailer on
+// a background worker, retrying failed sends with exponential backoff.
+type EmailNotifier struct {
+	mailer Mailer
+	cfg    EmailConfig
+
+	text *texttemplate.Template
+	html *htmltemplate.Template
+
+	// mu guards sends on queue against Close closing it
+	mu     sync.Mutex
+	closed bool
+	queue  chan *Message
+	done   chan struct{}
+
+	// abandon is closed when Close gives up waiting, telling the worker to
+	// stop retrying and drop the rest of the queue
+	abandon     chan struct{}
+	abandonOnce sync.Once
+}
+
+// NewEmailNotifier parses the email templates and starts the send worker.
+func NewEmailNotifier(mailer Mailer, cfg EmailConfig) (*EmailNotifier, error) {
+	if cfg.ConferenceName == "" {
+		cfg.ConferenceName = "the conference"
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 256
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = time.Second
+	}
+
+	funcs := map[string]interface{}{
+		"money": formatMoney,
+		"time":  formatTime,
+	}
+
+	text, err := texttemplate.New("").Funcs(funcs).ParseFS(templateFS, "templates/*.txt.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("notify: parse text templates: %w", err)
+	}
+	html, err := htmltemplate.New("").Funcs(funcs).ParseFS(templateFS, "templates/*.html.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("notify: parse html templates: %w", err)
+	}
+
+	n := &EmailNotifier{
+		mailer:  mailer,
+		cfg:     cfg,
+		text:    text,
+		html:    html,
+		queue:   make(chan *Message, cfg.QueueSize),
+		done:    make(chan struct{}),
+		abandon: make(chan struct{}),
+	}
+	go n.run()
+
+	return n, nil
+}
+
+// templateData is passed to every email template
+type templateData struct {
+	ConferenceName string
+	Name           string
+	Registration   *models.Registration
+	Enrollment     *models.Enrollment
+	Session        *models.Session
+}
+
+// RegistrationConfirmed emails a registration confirmation
+func (n *EmailNotifier) RegistrationConfirmed(_ context.Context, reg *models.Registration) error {
+	return n.enqueue(reg.Email, templateRegistrationConfirmed, n.registrationData(reg))
+}
+
+// RegistrationUpdated emails a summary of the updated registration
+func (n *EmailNotifier) RegistrationUpdated(_ context.Context, reg *models.Registration) error {
+	return n.enqueue(reg.Email, templateRegistrationUpdated, n.registrationData(reg))
+}
+
+// RegistrationCancelled emails a cancellation notice
+func (n *EmailNotifier) RegistrationCancelled(_ context.Context, reg *models.Registration) error {
+	return n.enqueue(reg.Email, templateRegistrationCancelled, n.registrationData(reg))
+}
+
+// PaymentReceived emails a payment receipt
+func (n *EmailNotifier) PaymentReceived(_ context.Context, reg *models.Registration) error {
+	return n.enqueue(reg.Email, templatePaymentReceipt, n.registrationData(reg))
+}
+
+// SessionChanged emails the new details of a session
+func (n *EmailNotifier) SessionChanged(_ context.Context, enrollment *models.Enrollment, session *models.Session) error {
+	return n.enqueue(enrollment.Email, templateSessionChanged, n.sessionData(enrollment, session))
+}
+
+// SessionCancelled emails a session cancellation notice
+func (n *EmailNotifier) SessionCancelled(_ context.Context, enrollment *models.Enrollment, session *models.Session) error {
+	return n.enqueue(enrollment.Email, templateSessionCancelled, n.sessionData(enrollment, session))
+}
+
+// WaitlistPromoted emails a waitlist promotion notice
+func (n *EmailNotifier) WaitlistPromoted(_ context.Context, enrollment *models.Enrollment, session *models.Session) error {
+	return n.enqueue(enrollment.Email, templateWaitlistPromoted, n.sessionData(enrollment, session))
+}
+
+// Close stops accepting emails and waits for queued ones to be sent. If ctx
+// ends first, the emails still queued are dropped, the one being sent is
+// not retried, and Close returns without waiting further.
+func (n *EmailNotifier) Close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mu.Unlock()
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		dropped := len(n.queue)
+		n.abandonOnce.Do(func() {
+			close(n.abandon)
+		})
+		return fmt.Errorf("notify: dropped %d queued emails: %w", dropped, ctx.Err())
+	}
+}
+
+func (n *EmailNotifier) registrationData(reg *models.Registration) *templateData {
+	return &templateData{
+		ConferenceName: n.cfg.ConferenceName,
+		Name:           reg.FirstName,
+		Registration:   reg,
+	}
+}
+
+func (n *EmailNotifier) sessionData(enrollment *models.Enrollment, session *models.Session) *templateData {
+	return &templateData{
+		ConferenceName: n.cfg.ConferenceName,
+		Name:           enrollment.FirstName,
+		Enrollment:     enrollment,
+		Session:        session,
+	}
+}
+
+// enqueue renders a template and queues the message for delivery
+func (n *EmailNotifier) enqueue(to, name string, data *templateData) error {
+	if to == "" {
+		return errors.New("notify: recipient has no email address")
+	}
+
+	msg, err := n.render(to, name, data)
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.closed {
+		return ErrClosed
+	}
+	select {
+	case n.queue <- msg:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// render executes the text, HTML and subject templates for name
+func (n *EmailNotifier) render(to, name string, data *templateData) (*Message, error) {
+	text := n.text.Lookup(name + ".txt.tmpl")
+	html := n.html.Lookup(name + ".html.tmpl")
+	if text == nil || html == nil {
+		return nil, fmt.Errorf("notify: unknown template %q", name)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return nil, fmt.Errorf("notify: render %s subject: %w", name, err)
+	}
+	if err := text.Execute(&textBody, data); err != nil {
+		return nil, fmt.Errorf("notify: render %s text: %w", name, err)
+	}
+	if err := html.Execute(&htmlBody, data); err != nil {
+		return nil, fmt.Errorf("notify: render %s html: %w", name, err)
+	}
+
+	return &Message{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: textBody.String(),
+		HTMLBody: htmlBody.String(),
+	}, nil
+}
+
+// run sends queued messages until the queue is closed
+func (n *EmailNotifier) run() {
+	defer close(n.done)
+
+	for msg := range n.queue {
+		select {
+		case <-n.abandon:
+			// Drain the queue without sending
+		default:
+			n.sendWithRetry(msg)
+		}
+	}
+}
+
+// sendWithRetry tries to send msg up to MaxAttempts times
+func (n *EmailNotifier) sendWithRetry(msg *Message) {
+	backoff := n.cfg.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := n.mailer.Send(msg)
+		if err == nil {
+			return
+		}
+
+		if attempt >= n.cfg.MaxAttempts {
+			slog.Error("Giving up on email", "subject", msg.Subject, "attempts", attempt, "error", err)
+			return
+		}
+
+		slog.Warn("Failed to send email, retrying", "subject", msg.Subject, "attempt", attempt, "max_attempts", n.cfg.MaxAttempts, "backoff", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-n.abandon:
+			slog.Error("Abandoning email at shutdown", "subject", msg.Subject, "attempts", attempt, "error", err)
+			return
+		}
+		backoff *= 2
+	}
+}
+
+// formatMoney renders an amount in minor units, e.g. 1250 USD as "12.50 USD"
+func formatMoney(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d %s", sign, amount/100, amount%100, currency)
+}
+
+// formatTime renders a timestamp for humans
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("Mon 2 Jan 2006, 15:04 MST")
+}
@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// Message is a rendered email.
+type Message struct {
+	To       string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Mailer delivers rendered emails.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// SMTPConfig configures an SMTPMailer.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPMailer sends email over SMTP. Authentication is only used when a
+// username is configured, so local mail sinks such as Mailpit work as is.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPMailer creates a new SMTP mailer
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// smtpTimeout bounds a whole SMTP delivery, from dialling to QUIT, so a
+// stalled server cannot block the send worker
+const smtpTimeout = 30 * time.Second
+
+// Send delivers msg as a multipart/alternative email with text and HTML parts
+func (m *SMTPMailer) Send(msg *Message) error {
+	body, err := buildMIME(m.cfg.From, msg)
+	if err != nil {
+		return err
+	}
+
+	if err := m.send(msg.To, body); err != nil {
+		return fmt.Errorf("notify: smtp send: %w", err)
+	}
+	return nil
+}
+
+// send delivers body to one recipient like smtp.SendMail, upgrading to TLS
+// when the server offers STARTTLS, but within smtpTimeout
+func (m *SMTPMailer) send(to string, body []byte) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+
+	dialer := net.Dialer{Timeout: smtpTimeout}
+	conn, err := dialer.Dial("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(smtpTimeout)); err != nil {
+		return err
+	}
+
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if m.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	// The envelope takes the bare address of a "Name <address>" sender
+	from := m.cfg.From
+	if addr, err := mail.ParseAddress(from); err == nil {
+		from = addr.Address
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMIME encodes msg as an RFC 5322 multipart/alternative message
+func buildMIME(from string, msg *Message) ([]byte, error) {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary)
+
+	for _, part := range []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain", msg.TextBody},
+		{"text/html", msg.HTMLBody},
+	} {
+		fmt.Fprintf(&buf, "--%s\r\n", boundary)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n", part.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", boundary)
+
+	return buf.Bytes(), nil
+}
+
+// randomBoundary returns a MIME multipart boundary
+func randomBoundary() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
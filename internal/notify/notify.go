@@ -0,0 +1,94 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+
+	"backend-ITC/internal/models"
+)
+
+// Notifier delivers attendee notifications.
+type Notifier interface {
+	// RegistrationConfirmed tells an attendee their registration was created.
+	RegistrationConfirmed(ctx context.Context, reg *models.Registration) error
+	// RegistrationUpdated tells an attendee their registration was changed.
+	RegistrationUpdated(ctx context.Context, reg *models.Registration) error
+	// RegistrationCancelled tells an attendee their registration was deleted.
+	RegistrationCancelled(ctx context.Context, reg *models.Registration) error
+	// PaymentReceived sends a receipt for a completed payment.
+	PaymentReceived(ctx context.Context, reg *models.Registration) error
+	// SessionChanged tells an enrolled or waitlisted attendee a session changed.
+	SessionChanged(ctx context.Context, enrollment *models.Enrollment, session *models.Session) error
+	// SessionCancelled tells an enrolled or waitlisted attendee a session was removed.
+	SessionCancelled(ctx context.Context, enrollment *models.Enrollment, session *models.Session) error
+	// WaitlistPromoted tells an attendee they moved from the waitlist into a session.
+	WaitlistPromoted(ctx context.Context, enrollment *models.Enrollment, session *models.Session) error
+	// Close flushes pending notifications and releases resources, giving up
+	// on notifications not yet delivered when ctx ends.
+	Close(ctx context.Context) error
+}
+
+// New returns an EmailNotifier sending through smtp, or a LogNotifier when
+// no SMTP host is configured.
+func New(conferenceName string, smtp SMTPConfig) (Notifier, error) {
+	if smtp.Host == "" {
+		return NewLogNotifier(), nil
+	}
+	return NewEmailNotifier(NewSMTPMailer(smtp), EmailConfig{ConferenceName: conferenceName})
+}
+
+// LogNotifier writes notifications to the default slog logger instead of
+// delivering them. It is useful in development.
+type LogNotifier struct{}
+
+// NewLogNotifier creates a notifier that only logs
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// RegistrationConfirmed logs the confirmation
+func (n *LogNotifier) RegistrationConfirmed(ctx context.Context, reg *models.Registration) error {
+	slog.InfoContext(ctx, "Registration confirmed", "registration_id", reg.ID, "uid", reg.UserID)
+	return nil
+}
+
+// RegistrationUpdated logs the update
+func (n *LogNotifier) RegistrationUpdated(ctx context.Context, reg *models.Registration) error {
+	slog.InfoContext(ctx, "Registration updated", "registration_id", reg.ID, "uid", reg.UserID)
+	return nil
+}
+
+// RegistrationCancelled logs the cancellation
+func (n *LogNotifier) RegistrationCancelled(ctx context.Context, reg *models.Registration) error {
+	slog.InfoContext(ctx, "Registration cancelled", "registration_id", reg.ID, "uid", reg.UserID)
+	return nil
+}
+
+// PaymentReceived logs the receipt
+func (n *LogNotifier) PaymentReceived(ctx context.Context, reg *models.Registration) error {
+	slog.InfoContext(ctx, "Payment received", "registration_id", reg.ID, "uid", reg.UserID)
+	return nil
+}
+
+// SessionChanged logs the session change
+func (n *LogNotifier) SessionChanged(ctx context.Context, enrollment *models.Enrollment, session *models.Session) error {
+	slog.InfoContext(ctx, "Session changed", "session_id", session.ID, "uid", enrollment.UserID)
+	return nil
+}
+
+// SessionCancelled logs the session cancellation
+func (n *LogNotifier) SessionCancelled(ctx context.Context, enrollment *models.Enrollment, session *models.Session) error {
+	slog.InfoContext(ctx, "Session cancelled", "session_id", session.ID, "uid", enrollment.UserID)
+	return nil
+}
+
+// WaitlistPromoted logs the promotion
+func (n *LogNotifier) WaitlistPromoted(ctx context.Context, enrollment *models.Enrollment, session *models.Session) error {
+	slog.InfoContext(ctx, "Promoted from waitlist", "session_id", session.ID, "uid", enrollment.UserID)
+	return nil
+}
+
+// Close is a no-op; LogNotifier holds no resources
+func (n *LogNotifier) Close(context.Context) error {
+	return nil
+}
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeProvider is an offline payment provider for development and tests.
+// Checkout sessions are kept in memory and payments are completed by
+// calling SignEvent, which produces a webhook signed like a real provider.
+type FakeProvider struct {
+	secret          string
+	checkoutBaseURL string
+
+	mu       sync.Mutex
+	sessions map[string]*fakeSession // by checkout session ID
+}
+
+// fakeSession is a checkout session of the fake provider
+type fakeSession struct {
+	registrationID string
+	paid           bool
+	expired        bool
+}
+
+// NewFakeProvider creates a fake provider that signs webhooks with secret.
+// Checkout URLs are built from checkoutBaseURL.
+func NewFakeProvider(secret, checkoutBaseURL string) *FakeProvider {
+	return &FakeProvider{
+		secret:          secret,
+		checkoutBaseURL: strings.TrimSuffix(checkoutBaseURL, "/"),
+		sessions:        make(map[string]*fakeSession),
+	}
+}
+
+// Name returns "fake"
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateCheckoutSession records a checkout session for the registration
+func (p *FakeProvider) CreateCheckoutSession(_ context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	if req.RegistrationID == "" {
+		return nil, errors.New("payment: registration id is required")
+	}
+
+	id := "fake_cs_" + randomHex(12)
+
+	p.mu.Lock()
+	p.sessions[id] = &fakeSession{registrationID: req.RegistrationID}
+	p.mu.Unlock()
+
+	return &CheckoutSession{
+		ID:  id,
+		URL: p.checkoutBaseURL + "/checkout/fake/" + id,
+	}, nil
+}
+
+// ExpireCheckoutSession expires an unpaid checkout session. Unknown sessions,
+// e.g. from before a restart, are treated as expired.
+func (p *FakeProvider) ExpireCheckoutSession(_ context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	session, ok := p.sessions[id]
+	if !ok {
+		return nil
+	}
+	if session.paid {
+		return ErrCheckoutCompleted
+	}
+	session.expired = true
+	return nil
+}
+
+// ParseWebhook verifies the signature header and decodes the event
+func (p *FakeProvider) ParseWebhook(_ context.Context, payload []byte, header http.Header) (*Event, error) {
+	if err := VerifySignature(p.secret, payload, header.Get(SignatureHeader), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// SignEvent builds a signed webhook for a previously created checkout
+// session, as the real provider would deliver it. An expired session can no
+// longer be paid.
+func (p *FakeProvider) SignEvent(checkoutSessionID, eventType string) ([]byte, http.Header, error) {
+	switch eventType {
+	case EventPaymentSucceeded, EventPaymentFailed, EventPaymentRefunded:
+	default:
+		return nil, nil, errors.New("payment: unknown event type")
+	}
+
+	p.mu.Lock()
+	var registrationID string
+	var err error
+	switch session, ok := p.sessions[checkoutSessionID]; {
+	case !ok:
+		err = errors.New("payment: unknown checkout session")
+	case eventType == EventPaymentSucceeded && session.expired:
+		err = errors.New("payment: checkout session has expired")
+	default:
+		registrationID = session.registrationID
+		if eventType == EventPaymentSucceeded {
+			session.paid = true
+		}
+	}
+	p.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := json.Marshal(Event{
+		ID:                "fake_evt_" + randomHex(12),
+		Type:              eventType,
+		CheckoutSessionID: checkoutSessionID,
+		RegistrationID:    registrationID,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := http.Header{}
+	header.Set(SignatureHeader, Sign(p.secret, payload, time.Now()))
+	return payload, header, nil
+}
+
+// randomHex returns n random bytes hex encoded
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("payment: failed to generate id: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
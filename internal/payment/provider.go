@@ -0,0 +1,78 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Event types reported by payment providers through webhooks
+const (
+	EventPaymentSucceeded = "payment.succeeded"
+	EventPaymentFailed    = "payment.failed"
+	EventPaymentRefunded  = "payment.refunded"
+)
+
+// ErrInvalidSignature is returned when a webhook signature does not verify.
+var ErrInvalidSignature = errors.New("payment: invalid webhook signature")
+
+// ErrCheckoutCompleted is returned when expiring a checkout session that has
+// already been paid.
+var ErrCheckoutCompleted = errors.New("payment: checkout session is already completed")
+
+// CheckoutRequest describes the payment an attendee is asked to make.
+type CheckoutRequest struct {
+	RegistrationID string
+	Amount         int64 // in minor units, e.g. cents
+	Currency       string
+	Email          string
+	Description    string
+	SuccessURL     string
+	CancelURL      string
+}
+
+// CheckoutSession is a provider-hosted payment page.
+type CheckoutSession struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// Event is a verified payment notification.
+type Event struct {
+	ID                string `json:"id"`
+	Type              string `json:"type"`
+	CheckoutSessionID string `json:"checkoutSessionId"`
+	RegistrationID    string `json:"registrationId"`
+}
+
+// Provider is implemented by payment backends.
+type Provider interface {
+	// Name identifies the provider, e.g. "fake".
+	Name() string
+	// CreateCheckoutSession starts a payment and returns where to send the attendee.
+	CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error)
+	// ExpireCheckoutSession closes an open checkout session so it can no
+	// longer be paid. Expired sessions are left as they are; it returns
+	// ErrCheckoutCompleted if the session has been paid.
+	ExpireCheckoutSession(ctx context.Context, id string) error
+	// ParseWebhook verifies the signature of a webhook request and decodes
+	// its event. It returns ErrInvalidSignature when verification fails.
+	ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*Event, error)
+}
+
+// NewProvider returns the provider registered under name. apiKey is the
+// secret API key of real providers.
+func NewProvider(name, apiKey, webhookSecret, checkoutBaseURL string) (Provider, error) {
+	switch name {
+	case "", "fake":
+		return NewFakeProvider(webhookSecret, checkoutBaseURL), nil
+	case "stripe":
+		if apiKey == "" {
+			return nil, errors.New("payment: stripe requires an API key")
+		}
+		return NewStripeProvider(apiKey, webhookSecret), nil
+	default:
+		return nil, fmt.Errorf("payment: unknown provider %q", name)
+	}
+}
@@ -0,0 +1,71 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the webhook signature in the form "t=<unix>,v1=<hex>"
+const SignatureHeader = "X-Payment-Signature"
+
+// signatureTolerance bounds how old a signed webhook may be, limiting replays
+const signatureTolerance = 5 * time.Minute
+
+// Sign returns a signature header value for payload signed at timestamp.
+func Sign(secret string, payload []byte, timestamp time.Time) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, computeSignature(secret, ts, payload))
+}
+
+// VerifySignature checks a signature header produced by Sign. The header
+// may carry several v1 signatures, e.g. while a secret is rotated; one of
+// them must match.
+func VerifySignature(secret string, payload []byte, header string, now time.Time) error {
+	var ts string
+	var sigs []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			sigs = append(sigs, value)
+		}
+	}
+	if ts == "" || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(unix, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := computeSignature(secret, ts, payload)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(expected), []byte(sig)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// computeSignature is the hex HMAC-SHA256 of "<timestamp>.<payload>"
+func computeSignature(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
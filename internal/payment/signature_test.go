@@ -0,0 +1,57 @@
+package payment
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	valid := computeSignature("secret", ts, payload)
+
+	tests := []struct {
+		name   string
+		header string
+		now    time.Time
+		ok     bool
+	}{
+		{"valid", Sign("secret", payload, now), now, true},
+		{"spaces around parts", "t=" + ts + ", v1=" + valid, now, true},
+		{"within tolerance", Sign("secret", payload, now), now.Add(signatureTolerance), true},
+		{"too old", Sign("secret", payload, now), now.Add(signatureTolerance + time.Second), false},
+		{"from the future", Sign("secret", payload, now), now.Add(-signatureTolerance - time.Second), false},
+		{"wrong secret", Sign("other", payload, now), now, false},
+		{"rotated secret first", "t=" + ts + ",v1=" + computeSignature("old", ts, payload) + ",v1=" + valid, now, true},
+		{"rotated secret last", "t=" + ts + ",v1=" + valid + ",v1=" + computeSignature("old", ts, payload), now, true},
+		{"no matching v1", "t=" + ts + ",v1=" + computeSignature("old", ts, payload) + ",v1=00", now, false},
+		{"unknown scheme only", "t=" + ts + ",v0=" + valid, now, false},
+		{"missing timestamp", "v1=" + valid, now, false},
+		{"missing signature", "t=" + ts, now, false},
+		{"non-numeric timestamp", "t=abc,v1=" + valid, now, false},
+		{"parts without values", "t,v1", now, false},
+		{"empty", "", now, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature("secret", payload, tt.header, tt.now)
+			if tt.ok && err != nil {
+				t.Errorf("VerifySignature() = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifySignature() = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureRejectsTamperedPayload(t *testing.T) {
+	now := time.Now()
+	header := Sign("secret", []byte(`{"amount":100}`), now)
+	if err := VerifySignature("secret", []byte(`{"amount":1}`), header, now); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("VerifySignature() = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -0,0 +1,227 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StripeSignatureHeader carries the signature of Stripe webhooks. Stripe
+// signs like Sign does, with one v1 value per active signing secret.
+const StripeSignatureHeader = "Stripe-Signature"
+
+// stripeAPIURL is the base URL of the Stripe REST API
+const stripeAPIURL = "https://api.stripe.com"
+
+// stripeTimeout bounds every call to the Stripe API
+const stripeTimeout = 10 * time.Second
+
+// StripeProvider takes payments with Stripe Checkout through the Stripe REST
+// API. Webhooks are verified with the signing secret of the endpoint.
+type StripeProvider struct {
+	apiKey        string
+	webhookSecret string
+	baseURL       string
+	client        *http.Client
+}
+
+// NewStripeProvider creates a provider that calls Stripe with the secret
+// apiKey and verifies webhooks with webhookSecret.
+func NewStripeProvider(apiKey, webhookSecret string) *StripeProvider {
+	return &StripeProvider{
+		apiKey:        apiKey,
+		webhookSecret: webhookSecret,
+		baseURL:       stripeAPIURL,
+		client:        &http.Client{Timeout: stripeTimeout},
+	}
+}
+
+// Name returns "stripe"
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// stripeCheckoutSession is the part of a Stripe Checkout Session we use
+type stripeCheckoutSession struct {
+	ID                string `json:"id"`
+	URL               string `json:"url"`
+	Status            string `json:"status"`         // open, complete or expired
+	PaymentStatus     string `json:"payment_status"` // paid, unpaid or no_payment_required
+	ClientReferenceID string `json:"client_reference_id"`
+}
+
+// CreateCheckoutSession creates a Checkout Session for a single line item
+// and refers it to the registration
+func (p *StripeProvider) CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (*CheckoutSession, error) {
+	description := req.Description
+	if description == "" {
+		description = "Conference ticket"
+	}
+
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	form.Set("client_reference_id", req.RegistrationID)
+	form.Set("metadata[registration_id]", req.RegistrationID)
+	if req.Email != "" {
+		form.Set("customer_email", req.Email)
+	}
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(req.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(req.Amount, 10))
+	form.Set("line_items[0][price_data][product_data][name]", description)
+
+	var session stripeCheckoutSession
+	if err := p.call(ctx, http.MethodPost, "/v1/checkout/sessions", form, &session); err != nil {
+		return nil, err
+	}
+	return &CheckoutSession{ID: session.ID, URL: session.URL}, nil
+}
+
+// ExpireCheckoutSession expires an open Checkout Session so it can no
+// longer be paid
+func (p *StripeProvider) ExpireCheckoutSession(ctx context.Context, id string) error {
+	path := "/v1/checkout/sessions/" + url.PathEscape(id)
+
+	var session stripeCheckoutSession
+	if err := p.call(ctx, http.MethodGet, path, nil, &session); err != nil {
+		return err
+	}
+	switch session.Status {
+	case "complete":
+		return ErrCheckoutCompleted
+	case "expired":
+		return nil
+	}
+
+	if err := p.call(ctx, http.MethodPost, path+"/expire", url.Values{}, &session); err != nil {
+		// The attendee may have paid between both calls
+		if getErr := p.call(ctx, http.MethodGet, path, nil, &session); getErr == nil && session.Status == "complete" {
+			return ErrCheckoutCompleted
+		}
+		return err
+	}
+	return nil
+}
+
+// stripeEvent is a Stripe webhook event
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// ParseWebhook verifies a Stripe webhook and maps its event. Checkout
+// Session events and full refunds of their charges are mapped to payment
+// events; other events keep their Stripe type, which the webhook ignores.
+func (p *StripeProvider) ParseWebhook(ctx context.Context, payload []byte, header http.Header) (*Event, error) {
+	if err := VerifySignature(p.webhookSecret, payload, header.Get(StripeSignatureHeader), time.Now()); err != nil {
+		return nil, err
+	}
+
+	var raw stripeEvent
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+	event := &Event{ID: raw.ID, Type: raw.Type}
+
+	switch raw.Type {
+	case "checkout.session.completed", "checkout.session.async_payment_succeeded",
+		"checkout.session.async_payment_failed", "checkout.session.expired":
+		var session stripeCheckoutSession
+		if err := json.Unmarshal(raw.Data.Object, &session); err != nil {
+			return nil, err
+		}
+		event.CheckoutSessionID = session.ID
+		event.RegistrationID = session.ClientReferenceID
+
+		switch {
+		case raw.Type == "checkout.session.async_payment_failed", raw.Type == "checkout.session.expired":
+			event.Type = EventPaymentFailed
+		case session.PaymentStatus == "paid", session.PaymentStatus == "no_payment_required":
+			event.Type = EventPaymentSucceeded
+		}
+
+	case "charge.refunded":
+		var charge struct {
+			PaymentIntent string `json:"payment_intent"`
+			Refunded      bool   `json:"refunded"`
+		}
+		if err := json.Unmarshal(raw.Data.Object, &charge); err != nil {
+			return nil, err
+		}
+		// Partial refunds leave the registration paid
+		if !charge.Refunded || charge.PaymentIntent == "" {
+			return event, nil
+		}
+
+		var sessions struct {
+			Data []stripeCheckoutSession `json:"data"`
+		}
+		query := url.Values{"payment_intent": {charge.PaymentIntent}, "limit": {"1"}}
+		if err := p.call(ctx, http.MethodGet, "/v1/checkout/sessions", query, &sessions); err != nil {
+			return nil, err
+		}
+		if len(sessions.Data) == 0 {
+			return event, nil
+		}
+		event.Type = EventPaymentRefunded
+		event.CheckoutSessionID = sessions.Data[0].ID
+		event.RegistrationID = sessions.Data[0].ClientReferenceID
+	}
+
+	return event, nil
+}
+
+// call sends a request to the Stripe API and decodes the response into out.
+// Parameters are form encoded in the body of POST requests and in the query
+// of others.
+func (p *StripeProvider) call(ctx context.Context, method, path string, params url.Values, out interface{}) error {
+	target := p.baseURL + path
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(params.Encode())
+	} else if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	if method == http.MethodPost {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("payment: stripe %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("payment: stripe %s %s: %w", method, path, err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return fmt.Errorf("payment: stripe %s %s: %s: %s", method, path, resp.Status, apiErr.Error.Message)
+	}
+
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,105 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStripeCheckoutAndWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk_test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"invalid API key"}}`))
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/checkout/sessions":
+			if err := r.ParseForm(); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.PostForm.Get("client_reference_id"); got != "reg-1" {
+				t.Errorf("client_reference_id = %q, want reg-1", got)
+			}
+			if got := r.PostForm.Get("line_items[0][price_data][unit_amount]"); got != "2500" {
+				t.Errorf("unit_amount = %q, want 2500", got)
+			}
+			w.Write([]byte(`{"id":"cs_1","url":"https://checkout.stripe.test/cs_1"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/checkout/sessions":
+			if got := r.URL.Query().Get("payment_intent"); got != "pi_1" {
+				t.Errorf("payment_intent = %q, want pi_1", got)
+			}
+			w.Write([]byte(`{"data":[{"id":"cs_1","client_reference_id":"reg-1"}]}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/checkout/sessions/cs_paid":
+			w.Write([]byte(`{"id":"cs_paid","status":"complete"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/checkout/sessions/cs_1":
+			w.Write([]byte(`{"id":"cs_1","status":"open"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/checkout/sessions/cs_1/expire":
+			w.Write([]byte(`{"id":"cs_1","status":"expired"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p := NewStripeProvider("sk_test", "whsec")
+	p.baseURL = server.URL
+	ctx := context.Background()
+
+	session, err := p.CreateCheckoutSession(ctx, CheckoutRequest{RegistrationID: "reg-1", Amount: 2500, Currency: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.ID != "cs_1" || session.URL == "" {
+		t.Errorf("session = %+v", session)
+	}
+
+	if err := p.ExpireCheckoutSession(ctx, "cs_1"); err != nil {
+		t.Errorf("ExpireCheckoutSession(open) = %v", err)
+	}
+	if err := p.ExpireCheckoutSession(ctx, "cs_paid"); !errors.Is(err, ErrCheckoutCompleted) {
+		t.Errorf("ExpireCheckoutSession(paid) = %v, want ErrCheckoutCompleted", err)
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{"paid", `{"id":"evt_1","type":"checkout.session.completed","data":{"object":{"id":"cs_1","client_reference_id":"reg-1","payment_status":"paid"}}}`, EventPaymentSucceeded},
+		{"unpaid", `{"id":"evt_2","type":"checkout.session.completed","data":{"object":{"id":"cs_1","client_reference_id":"reg-1","payment_status":"unpaid"}}}`, "checkout.session.completed"},
+		{"expired", `{"id":"evt_3","type":"checkout.session.expired","data":{"object":{"id":"cs_1","client_reference_id":"reg-1"}}}`, EventPaymentFailed},
+		{"refunded", `{"id":"evt_4","type":"charge.refunded","data":{"object":{"payment_intent":"pi_1","refunded":true}}}`, EventPaymentRefunded},
+		{"partially refunded", `{"id":"evt_5","type":"charge.refunded","data":{"object":{"payment_intent":"pi_1","refunded":false}}}`, "charge.refunded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(StripeSignatureHeader, Sign("whsec", []byte(tt.payload), time.Now()))
+			event, err := p.ParseWebhook(ctx, []byte(tt.payload), header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.Type != tt.want {
+				t.Errorf("Type = %q, want %q", event.Type, tt.want)
+			}
+			if tt.want != "charge.refunded" && event.RegistrationID != "reg-1" {
+				t.Errorf("RegistrationID = %q, want reg-1", event.RegistrationID)
+			}
+		})
+	}
+
+	header := http.Header{}
+	header.Set(StripeSignatureHeader, Sign("other", []byte(tests[0].payload), time.Now()))
+	if _, err := p.ParseWebhook(ctx, []byte(tests[0].payload), header); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseWebhook with a wrong secret = %v, want ErrInvalidSignature", err)
+	}
+
+	p.apiKey = "sk_wrong"
+	if _, err := p.CreateCheckoutSession(ctx, CheckoutRequest{RegistrationID: "reg-1"}); err == nil {
+		t.Error("CreateCheckoutSession succeeded with a rejected API key")
+	}
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"backend-ITC/internal/models"
+)
+
+// pageCursor is the decoded form of PageRequest.Cursor. It records the sort
+// key and ID of the last registration on the previous page.
+type pageCursor struct {
+	SortBy string `json:"s"`
+	Key    string `json:"k"`
+	ID     string `json:"id"`
+}
+
+// normalize fills in defaults for unset fields
+func (p PageRequest) normalize() PageRequest {
+	if p.Limit <= 0 {
+		p.Limit = DefaultPageSize
+	}
+	if p.SortBy == "" {
+		p.SortBy = SortByCreatedAt
+	}
+	return p
+}
+
+// encodeCursor returns the opaque cursor pointing just after reg
+func encodeCursor(reg *models.Registration, sortBy string) string {
+	c := pageCursor{SortBy: sortBy, ID: reg.ID}
+	switch sortBy {
+	case SortByLastName:
+		c.Key = reg.LastName
+	default:
+		c.Key = reg.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses cursor and checks it was issued for sortBy. It returns
+// the cursor position as a registration holding only the sort key and ID.
+func decodeCursor(cursor, sortBy string) (*models.Registration, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.SortBy != sortBy || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	reg := &models.Registration{ID: c.ID}
+	switch sortBy {
+	case SortByLastName:
+		reg.LastName = c.Key
+	default:
+		if reg.CreatedAt, err = time.Parse(time.RFC3339Nano, c.Key); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return reg, nil
+}
+
+// auditCursorSort marks cursors issued for audit log pages
+const auditCursorSort = "auditLog"
+
+// encodeAuditCursor returns the opaque cursor pointing just after entry in
+// the audit log, which is ordered by creation time and ID
+func encodeAuditCursor(entry *models.AuditEntry) string {
+	c := pageCursor{
+		SortBy: auditCursorSort,
+		Key:    entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ID:     entry.ID,
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeAuditCursor parses an audit log cursor into an entry holding only
+// the creation time and ID
+func decodeAuditCursor(cursor string) (*models.AuditEntry, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.SortBy != auditCursorSort || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	entry := &models.AuditEntry{ID: c.ID}
+	if entry.CreatedAt, err = time.Parse(time.RFC3339Nano, c.Key); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return entry, nil
+}
+
+// compareRegistrations orders a and b by sortBy, breaking ties by ID
+func compareRegistrations(a, b *models.Registration, sortBy string) int {
+	switch sortBy {
+	case SortByLastName:
+		if a.LastName != b.LastName {
+			if a.LastName < b.LastName {
+				return -1
+			}
+			return 1
+		}
+	default:
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			if a.CreatedAt.Before(b.CreatedAt) {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
+
+// compareAuditEntries orders a and b by creation time, breaking ties by ID
+func compareAuditEntries(a, b *models.AuditEntry) int {
+	switch {
+	case a.CreatedAt.Before(b.CreatedAt):
+		return -1
+	case a.CreatedAt.After(b.CreatedAt):
+		return 1
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,1212 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"backend-ITC/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Firestore collection names
+const (
+	usersCollection         = "users"
+	registrationsCollection = "registrations"
+	sessionsCollection      = "sessions"
+	enrollmentsCollection   = "enrollments"
+	ticketTypesCollection   = "ticketTypes"
+	promoCodesCollection    = "promoCodes"
+	auditLogCollection      = "auditLog"
+)
+
+// NewFirestore returns repositories backed by the given Firestore client.
+func NewFirestore(client *firestore.Client) *Repositories {
+	return &Repositories{
+		Users:         &firestoreUserRepository{client: client},
+		Registrations: &firestoreRegistrationRepository{client: client},
+		Sessions:      &firestoreSessionRepository{client: client},
+		Enrollments:   &firestoreEnrollmentRepository{client: client},
+		TicketTypes:   &firestoreTicketTypeRepository{client: client},
+		PromoCodes:    &firestorePromoCodeRepository{client: client},
+		Audit:         &firestoreAuditRepository{client: client},
+	}
+}
+
+// firestoreUserRepository stores users in the "users" collection keyed by UID
+type firestoreUserRepository struct {
+	client *firestore.Client
+}
+
+func (r *firestoreUserRepository) Get(ctx context.Context, uid string) (*models.User, error) {
+	doc, err := r.client.Collection(usersCollection).Doc(uid).Get(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+
+	var user models.User
+	if err := doc.DataTo(&user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *firestoreUserRepository) Save(ctx context.Context, user *models.User) error {
+	_, err := r.client.Collection(usersCollection).Doc(user.UID).Set(ctx, user)
+	return err
+}
+
+// firestoreRegistrationRepository stores registrations in the "registrations"
+// collection keyed by the UID of their user. Registrations created before
+// that have auto IDs and are found through their userId field.
+type firestoreRegistrationRepository struct {
+	client *firestore.Client
+}
+
+func (r *firestoreRegistrationRepository) Create(ctx context.Context, reg *models.Registration) error {
+	if reg.UserID == "" {
+		return errors.New("repository: registration has no user ID")
+	}
+	ref := r.client.Collection(registrationsCollection).Doc(reg.UserID)
+	legacy := r.client.Collection(registrationsCollection).Where("userId", "==", reg.UserID).Limit(1)
+
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing, err := tx.Documents(legacy).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return ErrAlreadyExists
+		}
+		// Fails if a concurrent transaction created the document first
+		return tx.Create(ref, reg)
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+
+	reg.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreRegistrationRepository) Get(ctx context.Context, id string) (*models.Registration, error) {
+	doc, err := r.client.Collection(registrationsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return registrationFromDoc(doc)
+}
+
+func (r *firestoreRegistrationRepository) GetByUserID(ctx context.Context, userID string) (*models.Registration, error) {
+	if userID == "" {
+		return nil, ErrNotFound
+	}
+
+	doc, err := r.client.Collection(registrationsCollection).Doc(userID).Get(ctx)
+	if err == nil {
+		return registrationFromDoc(doc)
+	}
+	if status.Code(err) != codes.NotFound {
+		return nil, err
+	}
+
+	// Fall back to registrations created with auto IDs
+	iter := r.client.Collection(registrationsCollection).Where("userId", "==", userID).Limit(1).Documents(ctx)
+	defer iter.Stop()
+
+	doc, err = iter.Next()
+	if err == iterator.Done {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return registrationFromDoc(doc)
+}
+
+func (r *firestoreRegistrationRepository) Update(ctx context.Context, reg *models.Registration) error {
+	// Write individual fields so concurrent payments and check-ins are not overwritten
+	_, err := r.client.Collection(registrationsCollection).Doc(reg.ID).Update(ctx, []firestore.Update{
+		{Path: "firstName", Value: reg.FirstName},
+		{Path: "lastName", Value: reg.LastName},
+		{Path: "email", Value: reg.Email},
+		{Path: "phone", Value: reg.Phone},
+		{Path: "organization", Value: reg.Organization},
+		{Path: "jobTitle", Value: reg.JobTitle},
+		{Path: "country", Value: reg.Country},
+		{Path: "city", Value: reg.City},
+		{Path: "dietaryRequirements", Value: reg.DietaryReqs},
+		{Path: "specialNeeds", Value: reg.SpecialNeeds},
+		{Path: "ticketType", Value: reg.TicketType},
+		{Path: "sessionsOfInterest", Value: reg.SessionsOfInt},
+		{Path: "promoCode", Value: reg.PromoCode},
+		{Path: "discount", Value: reg.Discount},
+		{Path: "updatedAt", Value: reg.UpdatedAt},
+	})
+	return translateError(err)
+}
+
+func (r *firestoreRegistrationRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.client.Collection(registrationsCollection).Doc(id).Delete(ctx)
+	return err
+}
+
+func (r *firestoreRegistrationRepository) List(ctx context.Context, filter RegistrationFilter) ([]models.Registration, error) {
+	var registrations []models.Registration
+	err := r.Each(ctx, filter, func(reg *models.Registration) error {
+		registrations = append(registrations, *reg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return registrations, nil
+}
+
+func (r *firestoreRegistrationRepository) ListPage(ctx context.Context, filter RegistrationFilter, page PageRequest) (*RegistrationPage, error) {
+	page = page.normalize()
+	query := r.filteredQuery(filter)
+
+	total, err := countQuery(ctx, query)
+	if err != nil {
+		return nil, translateQueryError(err)
+	}
+
+	dir := firestore.Asc
+	if page.Descending {
+		dir = firestore.Desc
+	}
+	query = query.OrderBy(page.SortBy, dir).OrderBy(firestore.DocumentID, dir)
+
+	if page.Cursor != "" {
+		after, err := decodeCursor(page.Cursor, page.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		var key interface{} = after.CreatedAt
+		if page.SortBy == SortByLastName {
+			key = after.LastName
+		}
+		query = query.StartAfter(key, after.ID)
+	}
+
+	// Fetch one extra registration to learn whether another page follows.
+	// Documents that fail to decode are skipped, so read on until enough
+	// registrations were decoded or the query is exhausted.
+	result := &RegistrationPage{Registrations: []models.Registration{}, Total: total}
+	var last *firestore.DocumentSnapshot
+	for len(result.Registrations) <= page.Limit {
+		want := page.Limit + 1 - len(result.Registrations)
+		batch := query.Limit(want)
+		if last != nil {
+			batch = batch.StartAfter(last)
+		}
+
+		docs, err := batch.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, translateQueryError(err)
+		}
+		for _, doc := range docs {
+			reg, err := registrationFromDoc(doc)
+			if err != nil {
+				slog.WarnContext(ctx, "Skipping undecodable registration", "registration_id", doc.Ref.ID, "error", err)
+				continue
+			}
+			result.Registrations = append(result.Registrations, *reg)
+		}
+
+		if len(docs) < want {
+			break
+		}
+		last = docs[len(docs)-1]
+	}
+
+	if len(result.Registrations) > page.Limit {
+		result.Registrations = result.Registrations[:page.Limit]
+		result.NextCursor = encodeCursor(&result.Registrations[page.Limit-1], page.SortBy)
+	}
+
+	return result, nil
+}
+
+func (r *firestoreRegistrationRepository) Each(ctx context.Context, filter RegistrationFilter, fn func(*models.Registration) error) error {
+	iter := r.filteredQuery(filter).Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return translateQueryError(err)
+		}
+
+		reg, err := registrationFromDoc(doc)
+		if err != nil {
+			slog.WarnContext(ctx, "Skipping undecodable registration", "registration_id", doc.Ref.ID, "error", err)
+			continue
+		}
+		if err := fn(reg); err != nil {
+			return err
+		}
+	}
+}
+
+// filteredQuery returns the registrations query narrowed by filter
+func (r *firestoreRegistrationRepository) filteredQuery(filter RegistrationFilter) firestore.Query {
+	query := r.client.Collection(registrationsCollection).Query
+	if filter.TicketType != "" {
+		query = query.Where("ticketType", "==", filter.TicketType)
+	}
+	if filter.PaymentStatus != "" {
+		query = query.Where("paymentStatus", "==", filter.PaymentStatus)
+	}
+	if filter.Country != "" {
+		query = query.Where("country", "==", filter.Country)
+	}
+	if !filter.CreatedFrom.IsZero() {
+		query = query.Where("createdAt", ">=", filter.CreatedFrom)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		query = query.Where("createdAt", "<", filter.CreatedBefore)
+	}
+	return query
+}
+
+// countQuery returns the number of documents matching query using a
+// server-side aggregation, without reading the documents
+func countQuery(ctx context.Context, query firestore.Query) (int, error) {
+	result, err := query.NewAggregationQuery().WithCount("total").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count, ok := result["total"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("repository: unexpected count result %T", result["total"])
+	}
+	return int(count.GetIntegerValue()), nil
+}
+
+func (r *firestoreRegistrationRepository) CheckIn(ctx context.Context, id, staffUID string, at time.Time) (*models.Registration, error) {
+	ref := r.client.Collection(registrationsCollection).Doc(id)
+
+	var reg *models.Registration
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		if reg, err = registrationFromDoc(doc); err != nil {
+			return err
+		}
+
+		if reg.IsCheckedIn() {
+			return ErrAlreadyCheckedIn
+		}
+
+		reg.CheckedInAt = at
+		reg.CheckedInBy = staffUID
+		return tx.Update(ref, []firestore.Update{
+			{Path: "checkedInAt", Value: at},
+			{Path: "checkedInBy", Value: staffUID},
+		})
+	})
+	if err != nil && !errors.Is(err, ErrAlreadyCheckedIn) {
+		return nil, err
+	}
+
+	return reg, err
+}
+
+func (r *firestoreRegistrationRepository) SetPaymentStatus(ctx context.Context, id string, update PaymentUpdate) (*models.Registration, error) {
+	ref := r.client.Collection(registrationsCollection).Doc(id)
+
+	var reg *models.Registration
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		if reg, err = registrationFromDoc(doc); err != nil {
+			return err
+		}
+
+		if reg.PaymentStatus != update.FromStatus || reg.PaymentRef != update.FromRef {
+			return ErrPaymentChanged
+		}
+
+		update.apply(reg)
+		updates := []firestore.Update{
+			{Path: "paymentStatus", Value: reg.PaymentStatus},
+			{Path: "paymentRef", Value: reg.PaymentRef},
+			{Path: "amountDue", Value: reg.AmountDue},
+			{Path: "currency", Value: reg.Currency},
+			{Path: "updatedAt", Value: reg.UpdatedAt},
+		}
+		if update.Status == models.PaymentStatusCompleted {
+			updates = append(updates, firestore.Update{Path: "paidAt", Value: reg.PaidAt})
+		}
+		return tx.Update(ref, updates)
+	})
+	if err != nil && !errors.Is(err, ErrPaymentChanged) {
+		return nil, err
+	}
+
+	return reg, err
+}
+
+// firestoreSessionRepository stores sessions in the "sessions" collection
+type firestoreSessionRepository struct {
+	client *firestore.Client
+}
+
+func (r *firestoreSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	docRef, _, err := r.client.Collection(sessionsCollection).Add(ctx, session)
+	if err != nil {
+		return err
+	}
+	session.ID = docRef.ID
+	return nil
+}
+
+func (r *firestoreSessionRepository) Get(ctx context.Context, id string) (*models.Session, error) {
+	doc, err := r.client.Collection(sessionsCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return sessionFromDoc(doc)
+}
+
+func (r *firestoreSessionRepository) Update(ctx context.Context, session *models.Session) error {
+	ref := r.client.Collection(sessionsCollection).Doc(session.ID)
+
+	// Enrollments run in transactions too, so the capacity is checked against
+	// the count they leave
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		stored, err := sessionFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		session.Enrolled = stored.Enrolled
+		if session.Capacity > 0 && session.Capacity < stored.Enrolled {
+			return ErrCapacityBelowEnrolled
+		}
+
+		// Write individual fields so the enrollment count is left alone
+		return tx.Update(ref, []firestore.Update{
+			{Path: "title", Value: session.Title},
+			{Path: "description", Value: session.Description},
+			{Path: "speaker", Value: session.Speaker},
+			{Path: "speakerBio", Value: session.SpeakerBio},
+			{Path: "startTime", Value: session.StartTime},
+			{Path: "endTime", Value: session.EndTime},
+			{Path: "location", Value: session.Location},
+			{Path: "capacity", Value: session.Capacity},
+			{Path: "track", Value: session.Track},
+			{Path: "tags", Value: session.Tags},
+			{Path: "updatedAt", Value: session.UpdatedAt},
+		})
+	})
+}
+
+func (r *firestoreSessionRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.client.Collection(sessionsCollection).Doc(id).Delete(ctx)
+	return err
+}
+
+func (r *firestoreSessionRepository) List(ctx context.Context, filter SessionFilter) ([]models.Session, error) {
+	query := r.client.Collection(sessionsCollection).Query
+	if filter.Track != "" {
+		query = query.Where("track", "==", filter.Track)
+	}
+	if filter.Tag != "" {
+		query = query.Where("tags", "array-contains", filter.Tag)
+	}
+	if !filter.StartFrom.IsZero() {
+		query = query.Where("startTime", ">=", filter.StartFrom)
+	}
+	if !filter.StartBefore.IsZero() {
+		query = query.Where("startTime", "<", filter.StartBefore)
+	}
+
+	iter := query.OrderBy("startTime", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+
+	sessions := []models.Session{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, translateQueryError(err)
+		}
+
+		session, err := sessionFromDoc(doc)
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, nil
+}
+
+// firestoreEnrollmentRepository stores enrollments in the "enrollments"
+// collection using a deterministic "<sessionID>_<userID>" document ID
+type firestoreEnrollmentRepository struct {
+	client *firestore.Client
+}
+
+func (r *firestoreEnrollmentRepository) Enroll(ctx context.Context, enrollment *models.Enrollment) error {
+	sessionRef := r.client.Collection(sessionsCollection).Doc(enrollment.SessionID)
+	enrollmentRef := r.client.Collection(enrollmentsCollection).Doc(enrollmentID(enrollment.SessionID, enrollment.UserID))
+
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		sessionDoc, err := tx.Get(sessionRef)
+		if err != nil {
+			return translateError(err)
+		}
+		session, err := sessionFromDoc(sessionDoc)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Get(enrollmentRef); err == nil {
+			return ErrAlreadyEnrolled
+		} else if status.Code(err) != codes.NotFound {
+			return err
+		}
+
+		// Full sessions put the attendee on the waitlist instead
+		if session.IsFull() {
+			enrollment.Status = models.EnrollmentStatusWaitlisted
+			return tx.Create(enrollmentRef, enrollment)
+		}
+
+		enrollment.Status = models.EnrollmentStatusEnrolled
+		if err := tx.Create(enrollmentRef, enrollment); err != nil {
+			return err
+		}
+		return tx.Update(sessionRef, []firestore.Update{
+			{Path: "enrolled", Value: firestore.Increment(1)},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	enrollment.ID = enrollmentRef.ID
+	return nil
+}
+
+func (r *firestoreEnrollmentRepository) Drop(ctx context.Context, sessionID, userID string) (*models.Enrollment, []models.Enrollment, error) {
+	sessionRef := r.client.Collection(sessionsCollection).Doc(sessionID)
+	enrollmentRef := r.client.Collection(enrollmentsCollection).Doc(enrollmentID(sessionID, userID))
+
+	var dropped models.Enrollment
+	var promoted []models.Enrollment
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		dropped, promoted = models.Enrollment{}, nil
+
+		enrollmentDoc, err := tx.Get(enrollmentRef)
+		if err != nil {
+			return translateError(err)
+		}
+		if err := enrollmentDoc.DataTo(&dropped); err != nil {
+			return err
+		}
+		dropped.ID = enrollmentRef.ID
+
+		// Leaving the waitlist frees no seat
+		if dropped.IsWaitlisted() {
+			return tx.Delete(enrollmentRef)
+		}
+
+		// The session may have been deleted; only adjust the count if it exists
+		sessionDoc, err := tx.Get(sessionRef)
+		if status.Code(err) == codes.NotFound {
+			return tx.Delete(enrollmentRef)
+		}
+		if err != nil {
+			return err
+		}
+		session, err := sessionFromDoc(sessionDoc)
+		if err != nil {
+			return err
+		}
+		session.Enrolled--
+
+		// All reads must happen before any write in a transaction
+		waitlist, err := r.waitlistHead(tx, session)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Delete(enrollmentRef); err != nil {
+			return err
+		}
+		promoted, err = r.promote(tx, sessionRef, waitlist, -1)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &dropped, promoted, nil
+}
+
+func (r *firestoreEnrollmentRepository) DropAllForUser(ctx context.Context, userID string) ([]models.Enrollment, error) {
+	enrollments, err := r.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each drop runs in its own transaction so it can promote from its waitlist
+	var promoted []models.Enrollment
+	for _, enrollment := range enrollments {
+		_, p, err := r.Drop(ctx, enrollment.SessionID, userID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return promoted, err
+		}
+		promoted = append(promoted, p...)
+	}
+
+	return promoted, nil
+}
+
+func (r *firestoreEnrollmentRepository) DeleteBySession(ctx context.Context, sessionID string) error {
+	docs, err := r.client.Collection(enrollmentsCollection).Where("sessionId", "==", sessionID).Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	bw := r.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(docs))
+	for _, doc := range docs {
+		job, err := bw.Delete(doc.Ref)
+		if err != nil {
+			bw.End()
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *firestoreEnrollmentRepository) Promote(ctx context.Context, sessionID string) ([]models.Enrollment, error) {
+	sessionRef := r.client.Collection(sessionsCollection).Doc(sessionID)
+
+	var promoted []models.Enrollment
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		promoted = nil
+
+		sessionDoc, err := tx.Get(sessionRef)
+		if err != nil {
+			return translateError(err)
+		}
+		session, err := sessionFromDoc(sessionDoc)
+		if err != nil {
+			return err
+		}
+
+		waitlist, err := r.waitlistHead(tx, session)
+		if err != nil {
+			return err
+		}
+
+		promoted, err = r.promote(tx, sessionRef, waitlist, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return promoted, nil
+}
+
+func (r *firestoreEnrollmentRepository) WaitlistPosition(ctx context.Context, sessionID, userID string) (int, error) {
+	iter := r.waitlistQuery(sessionID).Documents(ctx)
+	defer iter.Stop()
+
+	for position := 1; ; position++ {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return 0, ErrNotFound
+		}
+		if err != nil {
+			return 0, translateQueryError(err)
+		}
+		if doc.Ref.ID == enrollmentID(sessionID, userID) {
+			return position, nil
+		}
+	}
+}
+
+// waitlistQuery returns the waitlist of sessionID in arrival order
+func (r *firestoreEnrollmentRepository) waitlistQuery(sessionID string) firestore.Query {
+	return r.client.Collection(enrollmentsCollection).
+		Where("sessionId", "==", sessionID).
+		Where("status", "==", models.EnrollmentStatusWaitlisted).
+		OrderBy("createdAt", firestore.Asc)
+}
+
+// waitlistHead reads as many waitlist entries as session has free seats
+func (r *firestoreEnrollmentRepository) waitlistHead(tx *firestore.Transaction, session *models.Session) ([]*firestore.DocumentSnapshot, error) {
+	query := r.waitlistQuery(session.ID)
+	if session.Capacity > 0 {
+		free := session.Capacity - session.Enrolled
+		if free <= 0 {
+			return nil, nil
+		}
+		query = query.Limit(free)
+	}
+	docs, err := tx.Documents(query).GetAll()
+	if err != nil {
+		return nil, translateQueryError(err)
+	}
+	return docs, nil
+}
+
+// promote moves waitlist entries into the session and adjusts the session's
+// enrollment count by delta plus the number of promoted entries
+func (r *firestoreEnrollmentRepository) promote(tx *firestore.Transaction, sessionRef *firestore.DocumentRef, waitlist []*firestore.DocumentSnapshot, delta int) ([]models.Enrollment, error) {
+	now := time.Now()
+
+	var promoted []models.Enrollment
+	for _, doc := range waitlist {
+		var enrollment models.Enrollment
+		if err := doc.DataTo(&enrollment); err != nil {
+			return nil, err
+		}
+		enrollment.ID = doc.Ref.ID
+		enrollment.Status = models.EnrollmentStatusEnrolled
+		enrollment.PromotedAt = now
+
+		if err := tx.Update(doc.Ref, []firestore.Update{
+			{Path: "status", Value: enrollment.Status},
+			{Path: "promotedAt", Value: enrollment.PromotedAt},
+		}); err != nil {
+			return nil, err
+		}
+		promoted = append(promoted, enrollment)
+	}
+
+	if delta += len(promoted); delta != 0 {
+		if err := tx.Update(sessionRef, []firestore.Update{
+			{Path: "enrolled", Value: firestore.Increment(delta)},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return promoted, nil
+}
+
+func (r *firestoreEnrollmentRepository) ListByUser(ctx context.Context, userID string) ([]models.Enrollment, error) {
+	query := r.client.Collection(enrollmentsCollection).Where("userId", "==", userID)
+	return r.list(ctx, query)
+}
+
+func (r *firestoreEnrollmentRepository) ListBySession(ctx context.Context, sessionID string) ([]models.Enrollment, error) {
+	query := r.client.Collection(enrollmentsCollection).Where("sessionId", "==", sessionID).OrderBy("createdAt", firestore.Asc)
+	return r.list(ctx, query)
+}
+
+func (r *firestoreEnrollmentRepository) list(ctx context.Context, query firestore.Query) ([]models.Enrollment, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	enrollments := []models.Enrollment{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, translateQueryError(err)
+		}
+
+		var enrollment models.Enrollment
+		if err := doc.DataTo(&enrollment); err != nil {
+			continue
+		}
+		enrollment.ID = doc.Ref.ID
+		enrollments = append(enrollments, enrollment)
+	}
+
+	return enrollments, nil
+}
+
+// enrollmentID derives the document ID that makes enrollments unique per user and session
+func enrollmentID(sessionID, userID string) string {
+	return sessionID + "_" + userID
+}
+
+// firestoreTicketTypeRepository stores ticket types in the "ticketTypes"
+// collection keyed by their code
+type firestoreTicketTypeRepository struct {
+	client *firestore.Client
+}
+
+func (r *firestoreTicketTypeRepository) Create(ctx context.Context, ticketType *models.TicketType) error {
+	_, err := r.client.Collection(ticketTypesCollection).Doc(ticketType.ID).Create(ctx, ticketType)
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *firestoreTicketTypeRepository) Get(ctx context.Context, id string) (*models.TicketType, error) {
+	doc, err := r.client.Collection(ticketTypesCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return ticketTypeFromDoc(doc)
+}
+
+func (r *firestoreTicketTypeRepository) Update(ctx context.Context, ticketType *models.TicketType) error {
+	ref := r.client.Collection(ticketTypesCollection).Doc(ticketType.ID)
+
+	// Reservations run in transactions too, so the quantity is checked
+	// against the count they leave
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		stored, err := ticketTypeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		ticketType.Sold = stored.Sold
+		if ticketType.Quantity > 0 && ticketType.Quantity < stored.Sold {
+			return ErrQuantityBelowSold
+		}
+
+		// Write individual fields so the sold count is left alone
+		return tx.Update(ref, []firestore.Update{
+			{Path: "name", Value: ticketType.Name},
+			{Path: "description", Value: ticketType.Description},
+			{Path: "price", Value: ticketType.Price},
+			{Path: "currency", Value: ticketType.Currency},
+			{Path: "quantity", Value: ticketType.Quantity},
+			{Path: "saleStart", Value: ticketType.SaleStart},
+			{Path: "saleEnd", Value: ticketType.SaleEnd},
+			{Path: "eligibleEmailDomains", Value: ticketType.EligibleEmailDomains},
+			{Path: "eligibleCountries", Value: ticketType.EligibleCountries},
+			{Path: "active", Value: ticketType.Active},
+			{Path: "updatedAt", Value: ticketType.UpdatedAt},
+		})
+	})
+}
+
+func (r *firestoreTicketTypeRepository) Delete(ctx context.Context, id string) error {
+	ref := r.client.Collection(ticketTypesCollection).Doc(id)
+
+	// Registrations reference ticket types by ID, so a type is only deleted
+	// while no reservation holds a ticket
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		stored, err := ticketTypeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if stored.Sold > 0 {
+			return ErrInUse
+		}
+		return tx.Delete(ref)
+	})
+}
+
+func (r *firestoreTicketTypeRepository) List(ctx context.Context) ([]models.TicketType, error) {
+	iter := r.client.Collection(ticketTypesCollection).OrderBy("price", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+
+	ticketTypes := []models.TicketType{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		ticketType, err := ticketTypeFromDoc(doc)
+		if err != nil {
+			continue
+		}
+		ticketTypes = append(ticketTypes, *ticketType)
+	}
+
+	return ticketTypes, nil
+}
+
+func (r *firestoreTicketTypeRepository) Reserve(ctx context.Context, id string) error {
+	ref := r.client.Collection(ticketTypesCollection).Doc(id)
+
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		ticketType, err := ticketTypeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		if ticketType.IsSoldOut() {
+			return ErrSoldOut
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "sold", Value: firestore.Increment(1)},
+		})
+	})
+}
+
+func (r *firestoreTicketTypeRepository) Release(ctx context.Context, id string) error {
+	ref := r.client.Collection(ticketTypesCollection).Doc(id)
+
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		ticketType, err := ticketTypeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		if ticketType.Sold <= 0 {
+			return nil
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "sold", Value: firestore.Increment(-1)},
+		})
+	})
+}
+
+// firestorePromoCodeRepository stores promo codes in the "promoCodes"
+// collection keyed by code
+type firestorePromoCodeRepository struct {
+	client *firestore.Client
+}
+
+func (r *firestorePromoCodeRepository) Create(ctx context.Context, promo *models.PromoCode) error {
+	_, err := r.client.Collection(promoCodesCollection).Doc(promo.ID).Create(ctx, promo)
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrAlreadyExists
+	}
+	return err
+}
+
+func (r *firestorePromoCodeRepository) Get(ctx context.Context, id string) (*models.PromoCode, error) {
+	doc, err := r.client.Collection(promoCodesCollection).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return promoCodeFromDoc(doc)
+}
+
+func (r *firestorePromoCodeRepository) Update(ctx context.Context, promo *models.PromoCode) error {
+	ref := r.client.Collection(promoCodesCollection).Doc(promo.ID)
+
+	// Redemptions run in transactions too, so the cap is checked against
+	// the count they leave
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		stored, err := promoCodeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		promo.Uses = stored.Uses
+		if promo.MaxUses > 0 && promo.MaxUses < stored.Uses {
+			return ErrMaxUsesBelowUses
+		}
+
+		// Write individual fields so the use count is left alone
+		return tx.Update(ref, []firestore.Update{
+			{Path: "description", Value: promo.Description},
+			{Path: "discountType", Value: promo.DiscountType},
+			{Path: "value", Value: promo.Value},
+			{Path: "currency", Value: promo.Currency},
+			{Path: "ticketTypes", Value: promo.TicketTypes},
+			{Path: "maxUses", Value: promo.MaxUses},
+			{Path: "expiresAt", Value: promo.ExpiresAt},
+			{Path: "active", Value: promo.Active},
+			{Path: "updatedAt", Value: promo.UpdatedAt},
+		})
+	})
+}
+
+func (r *firestorePromoCodeRepository) Delete(ctx context.Context, id string) error {
+	ref := r.client.Collection(promoCodesCollection).Doc(id)
+
+	// Registrations reference redeemed codes, so a code is only deleted
+	// while it has no uses
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		stored, err := promoCodeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+		if stored.Uses > 0 {
+			return ErrInUse
+		}
+		return tx.Delete(ref)
+	})
+}
+
+func (r *firestorePromoCodeRepository) List(ctx context.Context) ([]models.PromoCode, error) {
+	iter := r.client.Collection(promoCodesCollection).Documents(ctx)
+	defer iter.Stop()
+
+	promos := []models.PromoCode{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		promo, err := promoCodeFromDoc(doc)
+		if err != nil {
+			continue
+		}
+		promos = append(promos, *promo)
+	}
+
+	return promos, nil
+}
+
+func (r *firestorePromoCodeRepository) Redeem(ctx context.Context, id string) error {
+	ref := r.client.Collection(promoCodesCollection).Doc(id)
+
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		promo, err := promoCodeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		if promo.IsExhausted() {
+			return ErrUsageLimitReached
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "uses", Value: firestore.Increment(1)},
+		})
+	})
+}
+
+func (r *firestorePromoCodeRepository) Release(ctx context.Context, id string) error {
+	ref := r.client.Collection(promoCodesCollection).Doc(id)
+
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return translateError(err)
+		}
+		promo, err := promoCodeFromDoc(doc)
+		if err != nil {
+			return err
+		}
+
+		if promo.Uses <= 0 {
+			return nil
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "uses", Value: firestore.Increment(-1)},
+		})
+	})
+}
+
+// promoCodeFromDoc decodes a promo code snapshot and sets its ID
+func promoCodeFromDoc(doc *firestore.DocumentSnapshot) (*models.PromoCode, error) {
+	var promo models.PromoCode
+	if err := doc.DataTo(&promo); err != nil {
+		return nil, err
+	}
+	promo.ID = doc.Ref.ID
+	return &promo, nil
+}
+
+// ticketTypeFromDoc decodes a ticket type snapshot and sets its ID
+func ticketTypeFromDoc(doc *firestore.DocumentSnapshot) (*models.TicketType, error) {
+	var ticketType models.TicketType
+	if err := doc.DataTo(&ticketType); err != nil {
+		return nil, err
+	}
+	ticketType.ID = doc.Ref.ID
+	return &ticketType, nil
+}
+
+// sessionFromDoc decodes a session snapshot and sets its ID
+func sessionFromDoc(doc *firestore.DocumentSnapshot) (*models.Session, error) {
+	var session models.Session
+	if err := doc.DataTo(&session); err != nil {
+		return nil, err
+	}
+	session.ID = doc.Ref.ID
+	return &session, nil
+}
+
+// firestoreAuditRepository stores audit entries in the "auditLog" collection
+type firestoreAuditRepository struct {
+	client *firestore.Client
+}
+
+func (r *firestoreAuditRepository) Append(ctx context.Context, entry *models.AuditEntry) error {
+	// Create rather than Set so an existing entry can never be overwritten
+	ref := r.client.Collection(auditLogCollection).NewDoc()
+	if _, err := ref.Create(ctx, entry); err != nil {
+		return err
+	}
+	entry.ID = ref.ID
+	return nil
+}
+
+func (r *firestoreAuditRepository) List(ctx context.Context, filter AuditFilter) (*AuditPage, error) {
+	query := r.client.Collection(auditLogCollection).Query
+	if filter.ActorUID != "" {
+		query = query.Where("actorUid", "==", filter.ActorUID)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("targetType", "==", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("targetId", "==", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("createdAt", ">=", filter.From)
+	}
+	if !filter.Before.IsZero() {
+		query = query.Where("createdAt", "<", filter.Before)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	// Entries written at the same time are told apart by their ID
+	query = query.OrderBy("createdAt", firestore.Desc).OrderBy(firestore.DocumentID, firestore.Desc)
+	if filter.Cursor != "" {
+		after, err := decodeAuditCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.StartAfter(after.CreatedAt, after.ID)
+	}
+
+	// Fetch one extra entry to learn whether another page follows, reading
+	// on past entries that fail to decode
+	result := &AuditPage{Entries: []models.AuditEntry{}}
+	var last *firestore.DocumentSnapshot
+	for len(result.Entries) <= limit {
+		want := limit + 1 - len(result.Entries)
+		batch := query.Limit(want)
+		if last != nil {
+			batch = batch.StartAfter(last)
+		}
+
+		docs, err := batch.Documents(ctx).GetAll()
+		if err != nil {
+			return nil, translateQueryError(err)
+		}
+		for _, doc := range docs {
+			var entry models.AuditEntry
+			if err := doc.DataTo(&entry); err != nil {
+				slog.WarnContext(ctx, "Skipping undecodable audit entry", "audit_entry_id", doc.Ref.ID, "error", err)
+				continue
+			}
+			entry.ID = doc.Ref.ID
+			result.Entries = append(result.Entries, entry)
+		}
+
+		if len(docs) < want {
+			break
+		}
+		last = docs[len(docs)-1]
+	}
+
+	if len(result.Entries) > limit {
+		result.Entries = result.Entries[:limit]
+		result.NextCursor = encodeAuditCursor(&result.Entries[limit-1])
+	}
+
+	return result, nil
+}
+
+// registrationFromDoc decodes a registration snapshot and sets its ID
+func registrationFromDoc(doc *firestore.DocumentSnapshot) (*models.Registration, error) {
+	var reg models.Registration
+	if err := doc.DataTo(&reg); err != nil {
+		return nil, err
+	}
+	reg.ID = doc.Ref.ID
+	return &reg, nil
+}
+
+// translateQueryError maps the FailedPrecondition error Firestore returns for
+// a query without its composite index to ErrMissingIndex. The server message,
+// which links to the index to create, is kept.
+func translateQueryError(err error) error {
+	if status.Code(err) == codes.FailedPrecondition {
+		return fmt.Errorf("%w: %s", ErrMissingIndex, status.Convert(err).Message())
+	}
+	return err
+}
+
+// translateError maps Firestore "not found" errors to ErrNotFound
+func translateError(err error) error {
+	if status.Code(err) == codes.NotFound || errors.Is(err, iterator.Done) {
+		return ErrNotFound
+	}
+	return err
+}
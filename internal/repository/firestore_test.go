@@ -0,0 +1,816 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"backend-ITC/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// The tests in this file run against the Firestore emulator and are skipped
+// unless FIRESTORE_EMULATOR_HOST is set, e.g.
+//
+//	gcloud emulators firestore start --host-port=localhost:8081
+//	FIRESTORE_EMULATOR_HOST=localhost:8081 go test ./internal/repository
+
+// emulatorProjectID is the project the tests write to. The demo- prefix
+// keeps the emulator from ever reaching a real project.
+const emulatorProjectID = "demo-backend-itc"
+
+// newEmulatorRepositories connects to the emulator and returns Firestore
+// repositories over an empty database that is cleared again after the test
+func newEmulatorRepositories(t *testing.T) (*Repositories, *firestore.Client) {
+	t.Helper()
+
+	host := os.Getenv("FIRESTORE_EMULATOR_HOST")
+	if host == "" {
+		t.Skip("FIRESTORE_EMULATOR_HOST is not set")
+	}
+
+	client, err := firestore.NewClient(context.Background(), emulatorProjectID)
+	if err != nil {
+		t.Fatalf("connect to emulator: %v", err)
+	}
+
+	clearEmulator(t, host)
+	t.Cleanup(func() {
+		clearEmulator(t, host)
+		client.Close()
+	})
+
+	return NewFirestore(client), client
+}
+
+// clearEmulator deletes every document of the test project
+func clearEmulator(t *testing.T, host string) {
+	t.Helper()
+
+	url := fmt.Sprintf("http://%s/emulator/v1/projects/%s/databases/(default)/documents", host, emulatorProjectID)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("clear emulator: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("clear emulator: %s", resp.Status)
+	}
+}
+
+// fixtures are the documents seeded by seedFixtures
+type fixtures struct {
+	ticketType *models.TicketType
+	promo      *models.PromoCode
+	session    *models.Session
+	alice      *models.Registration
+	bob        *models.Registration
+}
+
+// seedFixtures stores a ticket type with two tickets, a promo code with a
+// single use, a session with a single seat and registrations for two users
+func seedFixtures(t *testing.T, repos *Repositories) *fixtures {
+	t.Helper()
+	ctx := context.Background()
+	// The emulator stores microseconds; whole seconds compare cleanly
+	now := time.Now().UTC().Truncate(time.Second)
+
+	f := &fixtures{
+		ticketType: &models.TicketType{
+			ID:        "standard",
+			Name:      "Standard",
+			Price:     5000,
+			Currency:  "EUR",
+			Quantity:  2,
+			Active:    true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		promo: &models.PromoCode{
+			ID:           "SAVE10",
+			DiscountType: models.DiscountTypePercent,
+			Value:        10,
+			MaxUses:      1,
+			Active:       true,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		},
+		session: &models.Session{
+			Title:     "Keynote",
+			Speaker:   "Ada",
+			StartTime: now.Add(24 * time.Hour),
+			EndTime:   now.Add(25 * time.Hour),
+			Capacity:  1,
+			Track:     "technical",
+			Tags:      []string{"opening"},
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		alice: newTestRegistration("alice", "Liddell", "GB", now),
+		bob:   newTestRegistration("bob", "Builder", "IE", now.Add(time.Minute)),
+	}
+
+	if err := repos.TicketTypes.Create(ctx, f.ticketType); err != nil {
+		t.Fatalf("seed ticket type: %v", err)
+	}
+	if err := repos.PromoCodes.Create(ctx, f.promo); err != nil {
+		t.Fatalf("seed promo code: %v", err)
+	}
+	if err := repos.Sessions.Create(ctx, f.session); err != nil {
+		t.Fatalf("seed session: %v", err)
+	}
+	for _, reg := range []*models.Registration{f.alice, f.bob} {
+		if err := repos.Registrations.Create(ctx, reg); err != nil {
+			t.Fatalf("seed registration of %s: %v", reg.UserID, err)
+		}
+	}
+
+	return f
+}
+
+// newTestRegistration returns an unsaved pending registration of userID
+func newTestRegistration(userID, lastName, country string, createdAt time.Time) *models.Registration {
+	return &models.Registration{
+		UserID:           userID,
+		FirstName:        userID,
+		LastName:         lastName,
+		Email:            userID + "@example.com",
+		Country:          country,
+		TicketType:       "standard",
+		PaymentStatus:    models.PaymentStatusPending,
+		RegistrationDate: createdAt,
+		CreatedAt:        createdAt,
+		UpdatedAt:        createdAt,
+	}
+}
+
+func TestFirestoreRegistrations(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+	regs := repos.Registrations
+
+	got, err := regs.Get(ctx, f.alice.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.UserID != "alice" || got.ID != f.alice.ID {
+		t.Errorf("Get = %+v, want Alice's registration", got)
+	}
+	if _, err := regs.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing registration: got %v, want ErrNotFound", err)
+	}
+
+	// The userId query must pick the right document among several
+	got, err = regs.GetByUserID(ctx, "bob")
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if got.ID != f.bob.ID {
+		t.Errorf("GetByUserID(bob) = %s, want %s", got.ID, f.bob.ID)
+	}
+	if _, err := regs.GetByUserID(ctx, "carol"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByUserID of a user without registration: got %v, want ErrNotFound", err)
+	}
+
+	// Update replaces the document, so cleared fields do not survive
+	f.alice.City = "Oxford"
+	f.alice.Country = "FR"
+	if err := regs.Update(ctx, f.alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	f.alice.City = ""
+	if err := regs.Update(ctx, f.alice); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	got, err = regs.GetByUserID(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetByUserID after update: %v", err)
+	}
+	if got.City != "" || got.Country != "FR" {
+		t.Errorf("after updates city = %q, country = %q; want \"\", \"FR\"", got.City, got.Country)
+	}
+
+	list, err := regs.List(ctx, RegistrationFilter{Country: "FR"})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != f.alice.ID {
+		t.Errorf("List(country=FR) = %+v, want Alice's registration", list)
+	}
+
+	page, err := regs.ListPage(ctx, RegistrationFilter{}, PageRequest{SortBy: SortByLastName, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListPage: %v", err)
+	}
+	if page.Total != 2 || len(page.Registrations) != 1 || page.Registrations[0].ID != f.bob.ID || page.NextCursor == "" {
+		t.Fatalf("first page = %+v, want Builder of 2 with a cursor", page)
+	}
+	page, err = regs.ListPage(ctx, RegistrationFilter{}, PageRequest{SortBy: SortByLastName, Limit: 1, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("ListPage: %v", err)
+	}
+	if len(page.Registrations) != 1 || page.Registrations[0].ID != f.alice.ID || page.NextCursor != "" {
+		t.Errorf("second page = %+v, want Liddell without a cursor", page)
+	}
+
+	at := time.Now().UTC().Truncate(time.Second)
+	if _, err := regs.CheckIn(ctx, f.alice.ID, "staff", at); err != nil {
+		t.Fatalf("CheckIn: %v", err)
+	}
+	got, err = regs.CheckIn(ctx, f.alice.ID, "other-staff", at.Add(time.Minute))
+	if !errors.Is(err, ErrAlreadyCheckedIn) {
+		t.Fatalf("second CheckIn: got %v, want ErrAlreadyCheckedIn", err)
+	}
+	if got.CheckedInBy != "staff" || !got.CheckedInAt.Equal(at) {
+		t.Errorf("second CheckIn returned %s at %v, want the first check-in", got.CheckedInBy, got.CheckedInAt)
+	}
+
+	if err := regs.Delete(ctx, f.alice.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := regs.Get(ctx, f.alice.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+	if _, err := regs.GetByUserID(ctx, "alice"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetByUserID after Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestFirestoreUndecodableRegistrations checks that a document that does not
+// decode is skipped without cutting a page short
+func TestFirestoreUndecodableRegistrations(t *testing.T) {
+	repos, client := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+
+	// Sorts between Builder and Liddell; a number does not decode into firstName
+	_, err := client.Collection(registrationsCollection).Doc("broken").Set(ctx, map[string]interface{}{
+		"userId":    "broken",
+		"firstName": 42,
+		"lastName":  "Carroll",
+		"createdAt": time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := repos.Registrations.ListPage(ctx, RegistrationFilter{}, PageRequest{SortBy: SortByLastName, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListPage: %v", err)
+	}
+	if len(page.Registrations) != 1 || page.Registrations[0].ID != f.bob.ID || page.NextCursor == "" {
+		t.Fatalf("first page = %+v, want Builder with a cursor", page)
+	}
+	page, err = repos.Registrations.ListPage(ctx, RegistrationFilter{}, PageRequest{SortBy: SortByLastName, Limit: 1, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("ListPage: %v", err)
+	}
+	if len(page.Registrations) != 1 || page.Registrations[0].ID != f.alice.ID || page.NextCursor != "" {
+		t.Errorf("second page = %+v, want Liddell without a cursor", page)
+	}
+
+	list, err := repos.Registrations.List(ctx, RegistrationFilter{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("List returned %d registrations, want the 2 that decode", len(list))
+	}
+}
+
+// TestFirestoreFieldUpdates checks that editing a document writes only its
+// editable fields, leaving counters maintained by transactions untouched
+func TestFirestoreFieldUpdates(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+
+	if err := repos.TicketTypes.Reserve(ctx, f.ticketType.ID); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	f.ticketType.Name = "Standard pass"
+	if err := repos.TicketTypes.Update(ctx, f.ticketType); err != nil {
+		t.Fatalf("update ticket type: %v", err)
+	}
+	ticketType, err := repos.TicketTypes.Get(ctx, f.ticketType.ID)
+	if err != nil {
+		t.Fatalf("get ticket type: %v", err)
+	}
+	if ticketType.Name != "Standard pass" || ticketType.Sold != 1 {
+		t.Errorf("ticket type = %q with %d sold, want \"Standard pass\" with 1 sold", ticketType.Name, ticketType.Sold)
+	}
+
+	if err := repos.PromoCodes.Redeem(ctx, f.promo.ID); err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	f.promo.Description = "10% off"
+	if err := repos.PromoCodes.Update(ctx, f.promo); err != nil {
+		t.Fatalf("update promo code: %v", err)
+	}
+	promo, err := repos.PromoCodes.Get(ctx, f.promo.ID)
+	if err != nil {
+		t.Fatalf("get promo code: %v", err)
+	}
+	if promo.Description != "10% off" || promo.Uses != 1 {
+		t.Errorf("promo code = %q with %d uses, want \"10%% off\" with 1 use", promo.Description, promo.Uses)
+	}
+
+	if err := repos.Enrollments.Enroll(ctx, &models.Enrollment{SessionID: f.session.ID, UserID: "alice"}); err != nil {
+		t.Fatalf("Enroll: %v", err)
+	}
+	f.session.Location = "Main hall"
+	if err := repos.Sessions.Update(ctx, f.session); err != nil {
+		t.Fatalf("update session: %v", err)
+	}
+	session, err := repos.Sessions.Get(ctx, f.session.ID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if session.Location != "Main hall" || session.Enrolled != 1 {
+		t.Errorf("session = %q with %d enrolled, want \"Main hall\" with 1 enrolled", session.Location, session.Enrolled)
+	}
+
+	if _, err := repos.Registrations.CheckIn(ctx, f.alice.ID, "staff", time.Now()); err != nil {
+		t.Fatalf("CheckIn: %v", err)
+	}
+	f.alice.City = "Oxford"
+	if err := repos.Registrations.Update(ctx, f.alice); err != nil {
+		t.Fatalf("update registration: %v", err)
+	}
+	reg, err := repos.Registrations.Get(ctx, f.alice.ID)
+	if err != nil {
+		t.Fatalf("get registration: %v", err)
+	}
+	if reg.City != "Oxford" || reg.CheckedInBy != "staff" {
+		t.Errorf("registration = %q checked in by %q, want \"Oxford\" checked in by \"staff\"", reg.City, reg.CheckedInBy)
+	}
+
+	// Field updates never create documents
+	missing := *f.session
+	missing.ID = "missing"
+	if err := repos.Sessions.Update(ctx, &missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update of a missing session: got %v, want ErrNotFound", err)
+	}
+	missingReg := *f.alice
+	missingReg.ID = "missing"
+	if err := repos.Registrations.Update(ctx, &missingReg); !errors.Is(err, ErrNotFound) {
+		t.Errorf("update of a missing registration: got %v, want ErrNotFound", err)
+	}
+	if err := repos.TicketTypes.Create(ctx, f.ticketType); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("create of an existing ticket type: got %v, want ErrAlreadyExists", err)
+	}
+}
+
+func TestFirestoreInventory(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+
+	for i := 0; i < f.ticketType.Quantity; i++ {
+		if err := repos.TicketTypes.Reserve(ctx, f.ticketType.ID); err != nil {
+			t.Fatalf("Reserve %d: %v", i+1, err)
+		}
+	}
+	if err := repos.TicketTypes.Reserve(ctx, f.ticketType.ID); !errors.Is(err, ErrSoldOut) {
+		t.Fatalf("Reserve beyond quantity: got %v, want ErrSoldOut", err)
+	}
+	if err := repos.TicketTypes.Release(ctx, f.ticketType.ID); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := repos.TicketTypes.Reserve(ctx, f.ticketType.ID); err != nil {
+		t.Errorf("Reserve after Release: %v", err)
+	}
+	if err := repos.TicketTypes.Reserve(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Reserve of a missing ticket type: got %v, want ErrNotFound", err)
+	}
+
+	lowered := *f.ticketType
+	lowered.Quantity = f.ticketType.Quantity - 1
+	if err := repos.TicketTypes.Update(ctx, &lowered); !errors.Is(err, ErrQuantityBelowSold) {
+		t.Errorf("Update below the sold count: got %v, want ErrQuantityBelowSold", err)
+	}
+	if lowered.Sold != f.ticketType.Quantity {
+		t.Errorf("Update reported %d sold, want %d", lowered.Sold, f.ticketType.Quantity)
+	}
+	if err := repos.TicketTypes.Delete(ctx, f.ticketType.ID); !errors.Is(err, ErrInUse) {
+		t.Errorf("Delete of a sold ticket type: got %v, want ErrInUse", err)
+	}
+
+	if err := repos.PromoCodes.Redeem(ctx, f.promo.ID); err != nil {
+		t.Fatalf("Redeem: %v", err)
+	}
+	if err := repos.PromoCodes.Redeem(ctx, f.promo.ID); !errors.Is(err, ErrUsageLimitReached) {
+		t.Errorf("Redeem beyond the cap: got %v, want ErrUsageLimitReached", err)
+	}
+
+	raised := *f.promo
+	raised.MaxUses = 3
+	if err := repos.PromoCodes.Update(ctx, &raised); err != nil {
+		t.Fatalf("raise the cap: %v", err)
+	}
+	if err := repos.PromoCodes.Redeem(ctx, f.promo.ID); err != nil {
+		t.Fatalf("Redeem after raising the cap: %v", err)
+	}
+	raised.MaxUses = 1
+	if err := repos.PromoCodes.Update(ctx, &raised); !errors.Is(err, ErrMaxUsesBelowUses) {
+		t.Errorf("Update below the use count: got %v, want ErrMaxUsesBelowUses", err)
+	}
+	if raised.Uses != 2 {
+		t.Errorf("Update reported %d uses, want 2", raised.Uses)
+	}
+	if err := repos.PromoCodes.Delete(ctx, f.promo.ID); !errors.Is(err, ErrInUse) {
+		t.Errorf("Delete of a redeemed promo code: got %v, want ErrInUse", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := repos.PromoCodes.Release(ctx, f.promo.ID); err != nil {
+			t.Fatalf("Release: %v", err)
+		}
+	}
+	if err := repos.PromoCodes.Delete(ctx, f.promo.ID); err != nil {
+		t.Fatalf("delete promo code: %v", err)
+	}
+	if _, err := repos.PromoCodes.Get(ctx, f.promo.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestFirestoreEnrollments(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+	sessionID := f.session.ID
+
+	alice := &models.Enrollment{SessionID: sessionID, UserID: "alice", CreatedAt: time.Now()}
+	if err := repos.Enrollments.Enroll(ctx, alice); err != nil {
+		t.Fatalf("enroll Alice: %v", err)
+	}
+	bob := &models.Enrollment{SessionID: sessionID, UserID: "bob", CreatedAt: time.Now()}
+	if err := repos.Enrollments.Enroll(ctx, bob); err != nil {
+		t.Fatalf("enroll Bob: %v", err)
+	}
+	if alice.IsWaitlisted() || !bob.IsWaitlisted() {
+		t.Fatalf("statuses = %s, %s; want Alice enrolled and Bob waitlisted", alice.Status, bob.Status)
+	}
+	if err := repos.Enrollments.Enroll(ctx, &models.Enrollment{SessionID: sessionID, UserID: "alice"}); !errors.Is(err, ErrAlreadyEnrolled) {
+		t.Errorf("second enrollment: got %v, want ErrAlreadyEnrolled", err)
+	}
+
+	if position, err := repos.Enrollments.WaitlistPosition(ctx, sessionID, "bob"); err != nil || position != 1 {
+		t.Errorf("WaitlistPosition(bob) = %d, %v; want 1", position, err)
+	}
+
+	dropped, promoted, err := repos.Enrollments.Drop(ctx, sessionID, "alice")
+	if err != nil {
+		t.Fatalf("Drop: %v", err)
+	}
+	if dropped.UserID != "alice" || dropped.ID != enrollmentID(sessionID, "alice") {
+		t.Errorf("Drop returned %+v, want Alice's enrollment", dropped)
+	}
+	if len(promoted) != 1 || promoted[0].UserID != "bob" {
+		t.Fatalf("Drop promoted %+v, want Bob", promoted)
+	}
+
+	session, err := repos.Sessions.Get(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if session.Enrolled != 1 {
+		t.Errorf("enrolled = %d after drop and promotion, want 1", session.Enrolled)
+	}
+
+	roster, err := repos.Enrollments.ListBySession(ctx, sessionID)
+	if err != nil {
+		t.Fatalf("ListBySession: %v", err)
+	}
+	if len(roster) != 1 || roster[0].UserID != "bob" || roster[0].IsWaitlisted() {
+		t.Errorf("roster = %+v, want Bob enrolled", roster)
+	}
+
+	// Alice rejoins on the waitlist and takes Bob's seat when he leaves
+	if err := repos.Enrollments.Enroll(ctx, &models.Enrollment{SessionID: sessionID, UserID: "alice", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("re-enroll Alice: %v", err)
+	}
+	promoted, err = repos.Enrollments.DropAllForUser(ctx, "bob")
+	if err != nil {
+		t.Fatalf("DropAllForUser: %v", err)
+	}
+	if len(promoted) != 1 || promoted[0].UserID != "alice" {
+		t.Errorf("DropAllForUser promoted %+v, want Alice", promoted)
+	}
+
+	if err := repos.Enrollments.DeleteBySession(ctx, sessionID); err != nil {
+		t.Fatalf("DeleteBySession: %v", err)
+	}
+	if roster, err = repos.Enrollments.ListBySession(ctx, sessionID); err != nil || len(roster) != 0 {
+		t.Errorf("roster after DeleteBySession = %+v, %v; want empty", roster, err)
+	}
+}
+
+// TestFirestoreConcurrentReservations lets more attendees than there are
+// tickets reserve at once; transactions must not oversell
+func TestFirestoreConcurrentReservations(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+
+	const attempts = 4
+	results := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- repos.TicketTypes.Reserve(ctx, f.ticketType.ID)
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	reserved := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			reserved++
+		case !errors.Is(err, ErrSoldOut):
+			t.Errorf("Reserve: %v", err)
+		}
+	}
+	if reserved != f.ticketType.Quantity {
+		t.Errorf("%d reservations succeeded, want %d", reserved, f.ticketType.Quantity)
+	}
+}
+
+// TestFirestoreConcurrentCapacityUpdate lowers a session's capacity while
+// attendees enroll; the capacity must never end up below the enrollments
+func TestFirestoreConcurrentCapacityUpdate(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+
+	f.session.Capacity = 2
+	if err := repos.Sessions.Update(ctx, f.session); err != nil {
+		t.Fatalf("raise capacity: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for _, userID := range []string{"alice", "bob"} {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			enrollment := &models.Enrollment{SessionID: f.session.ID, UserID: userID, CreatedAt: time.Now()}
+			if err := repos.Enrollments.Enroll(ctx, enrollment); err != nil {
+				t.Errorf("enroll %s: %v", userID, err)
+			}
+		}(userID)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		lowered := *f.session
+		lowered.Capacity = 1
+		if err := repos.Sessions.Update(ctx, &lowered); err != nil && !errors.Is(err, ErrCapacityBelowEnrolled) {
+			t.Errorf("lower capacity: %v", err)
+		}
+	}()
+	wg.Wait()
+
+	session, err := repos.Sessions.Get(ctx, f.session.ID)
+	if err != nil {
+		t.Fatalf("get session: %v", err)
+	}
+	if session.Enrolled > session.Capacity {
+		t.Errorf("enrolled = %d with capacity %d", session.Enrolled, session.Capacity)
+	}
+
+	// Both enrollments are in now, one of them maybe waitlisted
+	lowered := *session
+	lowered.Capacity = 1
+	err = repos.Sessions.Update(ctx, &lowered)
+	if session.Enrolled == 2 && !errors.Is(err, ErrCapacityBelowEnrolled) {
+		t.Errorf("capacity 1 with 2 enrolled: got %v, want ErrCapacityBelowEnrolled", err)
+	}
+	if lowered.Enrolled != session.Enrolled {
+		t.Errorf("Update reported %d enrolled, want %d", lowered.Enrolled, session.Enrolled)
+	}
+}
+
+// TestFirestoreConcurrentRegistrations sends several registrations of the
+// same user at once, as a double-clicked form would, following the
+// check-then-create sequence of the registration handler; exactly one may be
+// stored even when every attempt passes the check
+func TestFirestoreConcurrentRegistrations(t *testing.T) {
+	repos, client := newEmulatorRepositories(t)
+	ctx := context.Background()
+
+	const attempts = 8
+	results := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repos.Registrations.GetByUserID(ctx, "carol"); err == nil {
+				results <- ErrAlreadyExists
+				return
+			} else if !errors.Is(err, ErrNotFound) {
+				results <- err
+				return
+			}
+			results <- repos.Registrations.Create(ctx, newTestRegistration("carol", "Danvers", "US", time.Now()))
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	created := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrAlreadyExists):
+			t.Errorf("Create: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d creations succeeded, want 1", created)
+	}
+
+	docs, err := client.Collection(registrationsCollection).Where("userId", "==", "carol").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Ref.ID != "carol" {
+		t.Errorf("stored %d registrations for one user, want 1 keyed by the UID", len(docs))
+	}
+}
+
+// TestFirestoreConcurrentPayments applies the same payment update several
+// times at once, as redelivered webhooks would; exactly one may apply and
+// the others must see the completed payment
+func TestFirestoreConcurrentPayments(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	f := seedFixtures(t, repos)
+	ctx := context.Background()
+
+	update := PaymentUpdate{
+		FromStatus: models.PaymentStatusPending,
+		Status:     models.PaymentStatusPending,
+		Ref:        "cs_1",
+		AmountDue:  5000,
+		Currency:   "EUR",
+		At:         time.Now().UTC().Truncate(time.Second),
+	}
+	if _, err := repos.Registrations.SetPaymentStatus(ctx, f.alice.ID, update); err != nil {
+		t.Fatalf("start checkout: %v", err)
+	}
+
+	update.FromRef = "cs_1"
+	update.Status = models.PaymentStatusCompleted
+	const attempts = 4
+	results := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reg, err := repos.Registrations.SetPaymentStatus(ctx, f.alice.ID, update)
+			if err == nil || errors.Is(err, ErrPaymentChanged) {
+				if reg.PaymentStatus != models.PaymentStatusCompleted {
+					t.Errorf("payment status = %q, want %q", reg.PaymentStatus, models.PaymentStatusCompleted)
+				}
+			}
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	applied := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			applied++
+		case !errors.Is(err, ErrPaymentChanged):
+			t.Errorf("SetPaymentStatus: %v", err)
+		}
+	}
+	if applied != 1 {
+		t.Errorf("%d payment updates applied, want 1", applied)
+	}
+
+	reg, err := repos.Registrations.Get(ctx, f.alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.PaymentRef != "cs_1" || reg.AmountDue != 5000 || !reg.PaidAt.Equal(update.At) || reg.FirstName != f.alice.FirstName {
+		t.Errorf("registration = %+v, want the completed payment of cs_1 and the original details", reg)
+	}
+
+	if _, err := repos.Registrations.SetPaymentStatus(ctx, "missing", update); !errors.Is(err, ErrNotFound) {
+		t.Errorf("payment update of a missing registration: got %v, want ErrNotFound", err)
+	}
+}
+
+// TestFirestoreAutoIDRegistrations checks that registrations stored under
+// auto IDs, before they were keyed by UID, are still found and still block
+// new registrations of their user
+func TestFirestoreAutoIDRegistrations(t *testing.T) {
+	repos, client := newEmulatorRepositories(t)
+	ctx := context.Background()
+
+	ref, _, err := client.Collection(registrationsCollection).Add(ctx, newTestRegistration("dave", "Bowman", "US", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repos.Registrations.GetByUserID(ctx, "dave")
+	if err != nil {
+		t.Fatalf("GetByUserID: %v", err)
+	}
+	if got.ID != ref.ID {
+		t.Errorf("GetByUserID(dave) = %s, want %s", got.ID, ref.ID)
+	}
+
+	if err := repos.Registrations.Create(ctx, newTestRegistration("dave", "Bowman", "US", time.Now())); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("Create for a user with an auto ID registration: got %v, want ErrAlreadyExists", err)
+	}
+
+	// Once it is deleted the user registers under their UID
+	if err := repos.Registrations.Delete(ctx, ref.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	reg := newTestRegistration("dave", "Bowman", "US", time.Now())
+	if err := repos.Registrations.Create(ctx, reg); err != nil {
+		t.Fatalf("Create after Delete: %v", err)
+	}
+	if reg.ID != "dave" {
+		t.Errorf("new registration ID = %s, want the UID", reg.ID)
+	}
+}
+
+func TestFirestoreUsersAndAudit(t *testing.T) {
+	repos, _ := newEmulatorRepositories(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	user := &models.User{UID: "alice", Email: "alice@example.com", Roles: []string{models.RoleAdmin}, CreatedAt: now}
+	if err := repos.Users.Save(ctx, user); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := repos.Users.Get(ctx, "alice")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Email != user.Email || len(got.Roles) != 0 {
+		t.Errorf("Get = %+v, want the profile without roles, which live in Firebase Auth", got)
+	}
+	if _, err := repos.Users.Get(ctx, "bob"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a missing user: got %v, want ErrNotFound", err)
+	}
+
+	// Entries sharing a timestamp must not be skipped between pages
+	times := []time.Time{now, now.Add(time.Second), now.Add(time.Second)}
+	for i, action := range []string{"registration.create", "registration.update", "registration.checkout"} {
+		entry := &models.AuditEntry{ActorUID: "alice", Action: action, TargetType: "registration", TargetID: "r1", CreatedAt: times[i]}
+		if err := repos.Audit.Append(ctx, entry); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	var actions []string
+	filter := AuditFilter{TargetID: "r1", Limit: 1}
+	for page := 0; page < 4; page++ {
+		result, err := repos.Audit.List(ctx, filter)
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(result.Entries) > 1 {
+			t.Fatalf("List returned %d entries, want at most 1", len(result.Entries))
+		}
+		for _, entry := range result.Entries {
+			actions = append(actions, entry.Action)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		filter.Cursor = result.NextCursor
+	}
+	if len(actions) != 3 || actions[2] != "registration.create" {
+		t.Errorf("paged actions = %v, want all three entries, oldest last", actions)
+	}
+
+	if _, err := repos.Audit.List(ctx, AuditFilter{Cursor: "bogus"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("List with a bad cursor: got %v, want ErrInvalidCursor", err)
+	}
+}
@@ -0,0 +1,875 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"backend-ITC/internal/models"
+)
+
+// NewMemory returns repositories that keep all data in process memory.
+// It is intended for tests and local development.
+func NewMemory() *Repositories {
+	sessions := &memorySessionRepository{sessions: make(map[string]models.Session)}
+	return &Repositories{
+		Users:         &memoryUserRepository{users: make(map[string]models.User)},
+		Registrations: &memoryRegistrationRepository{registrations: make(map[string]models.Registration)},
+		Sessions:      sessions,
+		Enrollments:   &memoryEnrollmentRepository{sessions: sessions, enrollments: make(map[string]models.Enrollment)},
+		TicketTypes:   &memoryTicketTypeRepository{ticketTypes: make(map[string]models.TicketType)},
+		PromoCodes:    &memoryPromoCodeRepository{promos: make(map[string]models.PromoCode)},
+		Audit:         &memoryAuditRepository{},
+	}
+}
+
+// memoryUserRepository is an in-memory UserRepository
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[string]models.User
+}
+
+func (r *memoryUserRepository) Get(_ context.Context, uid string) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[uid]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) Save(_ context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.users[user.UID] = *user
+	return nil
+}
+
+// memoryRegistrationRepository is an in-memory RegistrationRepository. Like
+// the Firestore one it keys registrations by the UID of their user.
+type memoryRegistrationRepository struct {
+	mu            sync.RWMutex
+	registrations map[string]models.Registration
+}
+
+func (r *memoryRegistrationRepository) Create(_ context.Context, reg *models.Registration) error {
+	if reg.UserID == "" {
+		return errors.New("repository: registration has no user ID")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.registrations {
+		if existing.UserID == reg.UserID {
+			return ErrAlreadyExists
+		}
+	}
+
+	reg.ID = reg.UserID
+	r.registrations[reg.ID] = cloneRegistration(*reg)
+	return nil
+}
+
+func (r *memoryRegistrationRepository) Get(_ context.Context, id string) (*models.Registration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	reg, ok := r.registrations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	reg = cloneRegistration(reg)
+	return &reg, nil
+}
+
+func (r *memoryRegistrationRepository) GetByUserID(_ context.Context, userID string) (*models.Registration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, reg := range r.registrations {
+		if reg.UserID == userID {
+			reg = cloneRegistration(reg)
+			return &reg, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryRegistrationRepository) Update(_ context.Context, reg *models.Registration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.registrations[reg.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	stored.FirstName = reg.FirstName
+	stored.LastName = reg.LastName
+	stored.Email = reg.Email
+	stored.Phone = reg.Phone
+	stored.Organization = reg.Organization
+	stored.JobTitle = reg.JobTitle
+	stored.Country = reg.Country
+	stored.City = reg.City
+	stored.DietaryReqs = reg.DietaryReqs
+	stored.SpecialNeeds = reg.SpecialNeeds
+	stored.TicketType = reg.TicketType
+	stored.SessionsOfInt = append([]string(nil), reg.SessionsOfInt...)
+	stored.PromoCode = reg.PromoCode
+	stored.Discount = reg.Discount
+	stored.UpdatedAt = reg.UpdatedAt
+	r.registrations[reg.ID] = stored
+	return nil
+}
+
+func (r *memoryRegistrationRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.registrations, id)
+	return nil
+}
+
+func (r *memoryRegistrationRepository) List(_ context.Context, filter RegistrationFilter) ([]models.Registration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	registrations := make([]models.Registration, 0, len(r.registrations))
+	for _, reg := range r.registrations {
+		if filter.TicketType != "" && reg.TicketType != filter.TicketType {
+			continue
+		}
+		if filter.PaymentStatus != "" && reg.PaymentStatus != filter.PaymentStatus {
+			continue
+		}
+		if filter.Country != "" && reg.Country != filter.Country {
+			continue
+		}
+		if !filter.CreatedFrom.IsZero() && reg.CreatedAt.Before(filter.CreatedFrom) {
+			continue
+		}
+		if !filter.CreatedBefore.IsZero() && !reg.CreatedAt.Before(filter.CreatedBefore) {
+			continue
+		}
+		registrations = append(registrations, cloneRegistration(reg))
+	}
+
+	// Map iteration order is random; keep results stable for callers
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].CreatedAt.Before(registrations[j].CreatedAt)
+	})
+
+	return registrations, nil
+}
+
+func (r *memoryRegistrationRepository) ListPage(ctx context.Context, filter RegistrationFilter, page PageRequest) (*RegistrationPage, error) {
+	page = page.normalize()
+
+	registrations, err := r.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	less := func(a, b *models.Registration) bool {
+		if page.Descending {
+			return compareRegistrations(a, b, page.SortBy) > 0
+		}
+		return compareRegistrations(a, b, page.SortBy) < 0
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return less(&registrations[i], &registrations[j])
+	})
+
+	start := 0
+	if page.Cursor != "" {
+		after, err := decodeCursor(page.Cursor, page.SortBy)
+		if err != nil {
+			return nil, err
+		}
+		start = sort.Search(len(registrations), func(i int) bool {
+			return less(after, &registrations[i])
+		})
+	}
+
+	end := start + page.Limit
+	result := &RegistrationPage{Total: len(registrations)}
+	if end < len(registrations) {
+		result.NextCursor = encodeCursor(&registrations[end-1], page.SortBy)
+	} else {
+		end = len(registrations)
+	}
+	result.Registrations = registrations[start:end]
+
+	return result, nil
+}
+
+func (r *memoryRegistrationRepository) Each(ctx context.Context, filter RegistrationFilter, fn func(*models.Registration) error) error {
+	// Iterate over a snapshot so fn may call back into the repository
+	registrations, err := r.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	for i := range registrations {
+		if err := fn(&registrations[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *memoryRegistrationRepository) CheckIn(_ context.Context, id, staffUID string, at time.Time) (*models.Registration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, ok := r.registrations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	if reg.IsCheckedIn() {
+		reg = cloneRegistration(reg)
+		return &reg, ErrAlreadyCheckedIn
+	}
+
+	reg.CheckedInAt = at
+	reg.CheckedInBy = staffUID
+	r.registrations[id] = reg
+
+	reg = cloneRegistration(reg)
+	return &reg, nil
+}
+
+func (r *memoryRegistrationRepository) SetPaymentStatus(_ context.Context, id string, update PaymentUpdate) (*models.Registration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, ok := r.registrations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	reg = cloneRegistration(reg)
+	if reg.PaymentStatus != update.FromStatus || reg.PaymentRef != update.FromRef {
+		return &reg, ErrPaymentChanged
+	}
+
+	update.apply(&reg)
+	r.registrations[id] = cloneRegistration(reg)
+	return &reg, nil
+}
+
+// memorySessionRepository is an in-memory SessionRepository
+type memorySessionRepository struct {
+	mu       sync.RWMutex
+	sessions map[string]models.Session
+}
+
+func (r *memorySessionRepository) Create(_ context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session.ID = newID()
+	r.sessions[session.ID] = cloneSession(*session)
+	return nil
+}
+
+func (r *memorySessionRepository) Get(_ context.Context, id string) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	session = cloneSession(session)
+	return &session, nil
+}
+
+func (r *memorySessionRepository) Update(_ context.Context, session *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.sessions[session.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	session.Enrolled = stored.Enrolled
+	if session.Capacity > 0 && session.Capacity < stored.Enrolled {
+		return ErrCapacityBelowEnrolled
+	}
+
+	updated := cloneSession(*session)
+	updated.CreatedAt = stored.CreatedAt
+	r.sessions[session.ID] = updated
+	return nil
+}
+
+func (r *memorySessionRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sessions, id)
+	return nil
+}
+
+func (r *memorySessionRepository) List(_ context.Context, filter SessionFilter) ([]models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := []models.Session{}
+	for _, session := range r.sessions {
+		if filter.Track != "" && session.Track != filter.Track {
+			continue
+		}
+		if filter.Tag != "" && !containsString(session.Tags, filter.Tag) {
+			continue
+		}
+		if !filter.StartFrom.IsZero() && session.StartTime.Before(filter.StartFrom) {
+			continue
+		}
+		if !filter.StartBefore.IsZero() && !session.StartTime.Before(filter.StartBefore) {
+			continue
+		}
+		sessions = append(sessions, cloneSession(session))
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartTime.Before(sessions[j].StartTime)
+	})
+
+	return sessions, nil
+}
+
+// memoryEnrollmentRepository is an in-memory EnrollmentRepository. It shares
+// the session repository's lock so seat counts change atomically.
+type memoryEnrollmentRepository struct {
+	sessions    *memorySessionRepository
+	enrollments map[string]models.Enrollment
+}
+
+func (r *memoryEnrollmentRepository) Enroll(_ context.Context, enrollment *models.Enrollment) error {
+	r.sessions.mu.Lock()
+	defer r.sessions.mu.Unlock()
+
+	session, ok := r.sessions.sessions[enrollment.SessionID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	id := enrollmentID(enrollment.SessionID, enrollment.UserID)
+	if _, exists := r.enrollments[id]; exists {
+		return ErrAlreadyEnrolled
+	}
+
+	enrollment.ID = id
+	if session.IsFull() {
+		enrollment.Status = models.EnrollmentStatusWaitlisted
+	} else {
+		enrollment.Status = models.EnrollmentStatusEnrolled
+		session.Enrolled++
+		r.sessions.sessions[session.ID] = session
+	}
+	r.enrollments[id] = *enrollment
+	return nil
+}
+
+func (r *memoryEnrollmentRepository) Drop(_ context.Context, sessionID, userID string) (*models.Enrollment, []models.Enrollment, error) {
+	r.sessions.mu.Lock()
+	defer r.sessions.mu.Unlock()
+
+	id := enrollmentID(sessionID, userID)
+	dropped, exists := r.enrollments[id]
+	if !exists {
+		return nil, nil, ErrNotFound
+	}
+	delete(r.enrollments, id)
+
+	session, ok := r.sessions.sessions[sessionID]
+	if dropped.IsWaitlisted() || !ok {
+		return &dropped, nil, nil
+	}
+	session.Enrolled--
+	r.sessions.sessions[sessionID] = session
+
+	return &dropped, r.promoteLocked(sessionID), nil
+}
+
+func (r *memoryEnrollmentRepository) DropAllForUser(ctx context.Context, userID string) ([]models.Enrollment, error) {
+	enrollments, err := r.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var promoted []models.Enrollment
+	for _, enrollment := range enrollments {
+		_, p, err := r.Drop(ctx, enrollment.SessionID, userID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return promoted, err
+		}
+		promoted = append(promoted, p...)
+	}
+
+	return promoted, nil
+}
+
+func (r *memoryEnrollmentRepository) DeleteBySession(_ context.Context, sessionID string) error {
+	r.sessions.mu.Lock()
+	defer r.sessions.mu.Unlock()
+
+	for id, e := range r.enrollments {
+		if e.SessionID == sessionID {
+			delete(r.enrollments, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryEnrollmentRepository) Promote(_ context.Context, sessionID string) ([]models.Enrollment, error) {
+	r.sessions.mu.Lock()
+	defer r.sessions.mu.Unlock()
+
+	if _, ok := r.sessions.sessions[sessionID]; !ok {
+		return nil, ErrNotFound
+	}
+	return r.promoteLocked(sessionID), nil
+}
+
+func (r *memoryEnrollmentRepository) WaitlistPosition(_ context.Context, sessionID, userID string) (int, error) {
+	r.sessions.mu.RLock()
+	defer r.sessions.mu.RUnlock()
+
+	id := enrollmentID(sessionID, userID)
+	for i, e := range r.waitlistLocked(sessionID) {
+		if e.ID == id {
+			return i + 1, nil
+		}
+	}
+	return 0, ErrNotFound
+}
+
+// promoteLocked fills free seats in sessionID from its waitlist. The caller
+// must hold the write lock.
+func (r *memoryEnrollmentRepository) promoteLocked(sessionID string) []models.Enrollment {
+	session := r.sessions.sessions[sessionID]
+	now := time.Now()
+
+	var promoted []models.Enrollment
+	for _, e := range r.waitlistLocked(sessionID) {
+		if session.IsFull() {
+			break
+		}
+		e.Status = models.EnrollmentStatusEnrolled
+		e.PromotedAt = now
+		r.enrollments[e.ID] = e
+		session.Enrolled++
+		promoted = append(promoted, e)
+	}
+
+	r.sessions.sessions[sessionID] = session
+	return promoted
+}
+
+// waitlistLocked returns the waitlist of sessionID in arrival order. The
+// caller must hold the lock.
+func (r *memoryEnrollmentRepository) waitlistLocked(sessionID string) []models.Enrollment {
+	var waitlist []models.Enrollment
+	for _, e := range r.enrollments {
+		if e.SessionID == sessionID && e.IsWaitlisted() {
+			waitlist = append(waitlist, e)
+		}
+	}
+
+	sort.Slice(waitlist, func(i, j int) bool {
+		return waitlist[i].CreatedAt.Before(waitlist[j].CreatedAt)
+	})
+
+	return waitlist
+}
+
+func (r *memoryEnrollmentRepository) ListByUser(_ context.Context, userID string) ([]models.Enrollment, error) {
+	return r.list(func(e models.Enrollment) bool { return e.UserID == userID }), nil
+}
+
+func (r *memoryEnrollmentRepository) ListBySession(_ context.Context, sessionID string) ([]models.Enrollment, error) {
+	return r.list(func(e models.Enrollment) bool { return e.SessionID == sessionID }), nil
+}
+
+func (r *memoryEnrollmentRepository) list(match func(models.Enrollment) bool) []models.Enrollment {
+	r.sessions.mu.RLock()
+	defer r.sessions.mu.RUnlock()
+
+	enrollments := []models.Enrollment{}
+	for _, e := range r.enrollments {
+		if match(e) {
+			enrollments = append(enrollments, e)
+		}
+	}
+
+	sort.Slice(enrollments, func(i, j int) bool {
+		return enrollments[i].CreatedAt.Before(enrollments[j].CreatedAt)
+	})
+
+	return enrollments
+}
+
+// memoryTicketTypeRepository is an in-memory TicketTypeRepository
+type memoryTicketTypeRepository struct {
+	mu          sync.RWMutex
+	ticketTypes map[string]models.TicketType
+}
+
+func (r *memoryTicketTypeRepository) Create(_ context.Context, ticketType *models.TicketType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.ticketTypes[ticketType.ID]; exists {
+		return ErrAlreadyExists
+	}
+	r.ticketTypes[ticketType.ID] = cloneTicketType(*ticketType)
+	return nil
+}
+
+func (r *memoryTicketTypeRepository) Get(_ context.Context, id string) (*models.TicketType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ticketType, ok := r.ticketTypes[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	ticketType = cloneTicketType(ticketType)
+	return &ticketType, nil
+}
+
+func (r *memoryTicketTypeRepository) Update(_ context.Context, ticketType *models.TicketType) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.ticketTypes[ticketType.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	ticketType.Sold = stored.Sold
+	if ticketType.Quantity > 0 && ticketType.Quantity < stored.Sold {
+		return ErrQuantityBelowSold
+	}
+
+	updated := cloneTicketType(*ticketType)
+	updated.CreatedAt = stored.CreatedAt
+	r.ticketTypes[ticketType.ID] = updated
+	return nil
+}
+
+func (r *memoryTicketTypeRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.ticketTypes[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Sold > 0 {
+		return ErrInUse
+	}
+
+	delete(r.ticketTypes, id)
+	return nil
+}
+
+func (r *memoryTicketTypeRepository) List(_ context.Context) ([]models.TicketType, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ticketTypes := make([]models.TicketType, 0, len(r.ticketTypes))
+	for _, ticketType := range r.ticketTypes {
+		ticketTypes = append(ticketTypes, cloneTicketType(ticketType))
+	}
+
+	sort.Slice(ticketTypes, func(i, j int) bool {
+		return ticketTypes[i].Price < ticketTypes[j].Price
+	})
+
+	return ticketTypes, nil
+}
+
+func (r *memoryTicketTypeRepository) Reserve(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ticketType, ok := r.ticketTypes[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if ticketType.IsSoldOut() {
+		return ErrSoldOut
+	}
+
+	ticketType.Sold++
+	r.ticketTypes[id] = ticketType
+	return nil
+}
+
+func (r *memoryTicketTypeRepository) Release(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ticketType, ok := r.ticketTypes[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if ticketType.Sold > 0 {
+		ticketType.Sold--
+		r.ticketTypes[id] = ticketType
+	}
+	return nil
+}
+
+// memoryPromoCodeRepository is an in-memory PromoCodeRepository
+type memoryPromoCodeRepository struct {
+	mu     sync.RWMutex
+	promos map[string]models.PromoCode
+}
+
+func (r *memoryPromoCodeRepository) Create(_ context.Context, promo *models.PromoCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.promos[promo.ID]; exists {
+		return ErrAlreadyExists
+	}
+	r.promos[promo.ID] = clonePromoCode(*promo)
+	return nil
+}
+
+func (r *memoryPromoCodeRepository) Get(_ context.Context, id string) (*models.PromoCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	promo, ok := r.promos[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	promo = clonePromoCode(promo)
+	return &promo, nil
+}
+
+func (r *memoryPromoCodeRepository) Update(_ context.Context, promo *models.PromoCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.promos[promo.ID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	promo.Uses = stored.Uses
+	if promo.MaxUses > 0 && promo.MaxUses < stored.Uses {
+		return ErrMaxUsesBelowUses
+	}
+
+	updated := clonePromoCode(*promo)
+	updated.CreatedAt = stored.CreatedAt
+	r.promos[promo.ID] = updated
+	return nil
+}
+
+func (r *memoryPromoCodeRepository) Delete(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.promos[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Uses > 0 {
+		return ErrInUse
+	}
+
+	delete(r.promos, id)
+	return nil
+}
+
+func (r *memoryPromoCodeRepository) List(_ context.Context) ([]models.PromoCode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	promos := make([]models.PromoCode, 0, len(r.promos))
+	for _, promo := range r.promos {
+		promos = append(promos, clonePromoCode(promo))
+	}
+
+	sort.Slice(promos, func(i, j int) bool {
+		return promos[i].ID < promos[j].ID
+	})
+
+	return promos, nil
+}
+
+func (r *memoryPromoCodeRepository) Redeem(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	promo, ok := r.promos[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if promo.IsExhausted() {
+		return ErrUsageLimitReached
+	}
+
+	promo.Uses++
+	r.promos[id] = promo
+	return nil
+}
+
+func (r *memoryPromoCodeRepository) Release(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	promo, ok := r.promos[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if promo.Uses > 0 {
+		promo.Uses--
+		r.promos[id] = promo
+	}
+	return nil
+}
+
+// memoryAuditRepository is an in-memory AuditRepository
+type memoryAuditRepository struct {
+	mu      sync.RWMutex
+	entries []models.AuditEntry
+}
+
+func (r *memoryAuditRepository) Append(_ context.Context, entry *models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry.ID = newID()
+	stored := *entry
+	stored.Changes = append([]models.AuditChange(nil), entry.Changes...)
+	r.entries = append(r.entries, stored)
+	return nil
+}
+
+func (r *memoryAuditRepository) List(_ context.Context, filter AuditFilter) (*AuditPage, error) {
+	var after *models.AuditEntry
+	if filter.Cursor != "" {
+		var err error
+		if after, err = decodeAuditCursor(filter.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	r.mu.RLock()
+	var matches []models.AuditEntry
+	for _, entry := range r.entries {
+		if filter.ActorUID != "" && entry.ActorUID != filter.ActorUID {
+			continue
+		}
+		if filter.TargetType != "" && entry.TargetType != filter.TargetType {
+			continue
+		}
+		if filter.TargetID != "" && entry.TargetID != filter.TargetID {
+			continue
+		}
+		if !filter.From.IsZero() && entry.CreatedAt.Before(filter.From) {
+			continue
+		}
+		if !filter.Before.IsZero() && !entry.CreatedAt.Before(filter.Before) {
+			continue
+		}
+		if after != nil && compareAuditEntries(&entry, after) >= 0 {
+			continue
+		}
+		entry.Changes = append([]models.AuditChange(nil), entry.Changes...)
+		matches = append(matches, entry)
+	}
+	r.mu.RUnlock()
+
+	// Newest first, as Firestore orders them
+	sort.Slice(matches, func(i, j int) bool {
+		return compareAuditEntries(&matches[i], &matches[j]) > 0
+	})
+
+	result := &AuditPage{Entries: []models.AuditEntry{}}
+	if len(matches) > limit {
+		matches = matches[:limit]
+		result.NextCursor = encodeAuditCursor(&matches[limit-1])
+	}
+	result.Entries = append(result.Entries, matches...)
+	return result, nil
+}
+
+// clonePromoCode copies a promo code so callers cannot mutate stored slices
+func clonePromoCode(promo models.PromoCode) models.PromoCode {
+	if promo.TicketTypes != nil {
+		promo.TicketTypes = append([]string(nil), promo.TicketTypes...)
+	}
+	return promo
+}
+
+// cloneTicketType copies a ticket type so callers cannot mutate stored slices
+func cloneTicketType(ticketType models.TicketType) models.TicketType {
+	if ticketType.EligibleEmailDomains != nil {
+		ticketType.EligibleEmailDomains = append([]string(nil), ticketType.EligibleEmailDomains...)
+	}
+	if ticketType.EligibleCountries != nil {
+		ticketType.EligibleCountries = append([]string(nil), ticketType.EligibleCountries...)
+	}
+	return ticketType
+}
+
+// cloneSession copies a session so callers cannot mutate stored slices
+func cloneSession(session models.Session) models.Session {
+	if session.Tags != nil {
+		session.Tags = append([]string(nil), session.Tags...)
+	}
+	return session
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneRegistration copies a registration so callers cannot mutate stored slices
+func cloneRegistration(reg models.Registration) models.Registration {
+	if reg.SessionsOfInt != nil {
+		reg.SessionsOfInt = append([]string(nil), reg.SessionsOfInt...)
+	}
+	return reg
+}
+
+// newID generates a random document ID similar to Firestore auto IDs
+func newID() string {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic("repository: failed to generate id: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
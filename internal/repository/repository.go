@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend-ITC/internal/models"
+)
+
+// Errors returned by repositories
+var (
+	// ErrNotFound is returned when a requested document does not exist.
+	ErrNotFound = errors.New("repository: not found")
+	// ErrAlreadyEnrolled is returned when a user is already enrolled in a session.
+	ErrAlreadyEnrolled = errors.New("repository: already enrolled")
+	// ErrAlreadyExists is returned when creating a document whose ID is taken.
+	ErrAlreadyExists = errors.New("repository: already exists")
+	// ErrSoldOut is returned when a ticket type has no inventory left.
+	ErrSoldOut = errors.New("repository: sold out")
+	// ErrUsageLimitReached is returned when a promo code has no uses left.
+	ErrUsageLimitReached = errors.New("repository: usage limit reached")
+	// ErrAlreadyCheckedIn is returned when a registration was already checked in.
+	ErrAlreadyCheckedIn = errors.New("repository: already checked in")
+	// ErrPaymentChanged is returned when a registration's payment no longer
+	// has the status and reference a payment update expects.
+	ErrPaymentChanged = errors.New("repository: payment changed")
+	// ErrCapacityBelowEnrolled is returned when a session's capacity would
+	// drop below its number of enrolled attendees.
+	ErrCapacityBelowEnrolled = errors.New("repository: capacity below enrollments")
+	// ErrQuantityBelowSold is returned when a ticket type's quantity would
+	// drop below its number of sold tickets.
+	ErrQuantityBelowSold = errors.New("repository: quantity below sold tickets")
+	// ErrMaxUsesBelowUses is returned when a promo code's usage cap would
+	// drop below its number of redemptions.
+	ErrMaxUsesBelowUses = errors.New("repository: max uses below uses")
+	// ErrInUse is returned when deleting a ticket type that has sold tickets
+	// or a promo code that has been redeemed.
+	ErrInUse = errors.New("repository: in use")
+	// ErrInvalidCursor is returned when a page cursor is malformed or was
+	// issued for a different sort order.
+	ErrInvalidCursor = errors.New("repository: invalid cursor")
+	// ErrMissingIndex is returned when Firestore rejects a query because a
+	// composite index from firestore.indexes.json is not deployed.
+	ErrMissingIndex = errors.New("repository: missing Firestore index, deploy firestore.indexes.json")
+)
+
+// UserRepository persists user profiles linked to Firebase Auth.
+type UserRepository interface {
+	// Get returns the user with the given UID or ErrNotFound.
+	Get(ctx context.Context, uid string) (*models.User, error)
+	// Save creates or replaces the user keyed by user.UID.
+	Save(ctx context.Context, user *models.User) error
+}
+
+// RegistrationRepository persists conference registrations.
+type RegistrationRepository interface {
+	// Create stores a new registration and sets its ID. It returns
+	// ErrAlreadyExists if reg.UserID already has a registration, even when
+	// called concurrently.
+	Create(ctx context.Context, reg *models.Registration) error
+	// Get returns the registration with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (*models.Registration, error)
+	// GetByUserID returns the registration owned by userID or ErrNotFound.
+	GetByUserID(ctx context.Context, userID string) (*models.Registration, error)
+	// Update writes the attendee details, ticket type and promo code of the
+	// registration identified by reg.ID or returns ErrNotFound. Payment and
+	// check-in fields are left to SetPaymentStatus and CheckIn.
+	Update(ctx context.Context, reg *models.Registration) error
+	// Delete removes the registration with the given ID.
+	Delete(ctx context.Context, id string) error
+	// List returns every registration matching filter.
+	List(ctx context.Context, filter RegistrationFilter) ([]models.Registration, error)
+	// ListPage returns one page of the registrations matching filter in the
+	// order requested by page, together with the total number of matches.
+	// It returns ErrInvalidCursor if page.Cursor was not issued for the same
+	// sort order.
+	ListPage(ctx context.Context, filter RegistrationFilter, page PageRequest) (*RegistrationPage, error)
+	// Each calls fn for every registration matching filter, reading them one
+	// at a time so callers can stream large result sets. Iteration stops at
+	// the first error returned by fn.
+	Each(ctx context.Context, filter RegistrationFilter, fn func(*models.Registration) error) error
+	// CheckIn atomically marks the registration as checked in by staffUID.
+	// If it was already checked in, the stored registration is returned
+	// together with ErrAlreadyCheckedIn.
+	CheckIn(ctx context.Context, id, staffUID string, at time.Time) (*models.Registration, error)
+	// SetPaymentStatus atomically applies update to the registration with
+	// the given ID and returns it. If its payment status or reference no
+	// longer match update.FromStatus and update.FromRef, the stored
+	// registration is returned together with ErrPaymentChanged.
+	SetPaymentStatus(ctx context.Context, id string, update PaymentUpdate) (*models.Registration, error)
+}
+
+// PaymentUpdate is a payment status transition applied by
+// RegistrationRepository.SetPaymentStatus.
+type PaymentUpdate struct {
+	// FromStatus and FromRef are the payment status and reference the
+	// registration must still have.
+	FromStatus string
+	FromRef    string
+
+	Status    string
+	Ref       string
+	AmountDue int64
+	Currency  string
+	// At is stored as the update time, and as the payment time when Status
+	// is completed.
+	At time.Time
+}
+
+// apply sets the payment fields of reg written by the update
+func (u PaymentUpdate) apply(reg *models.Registration) {
+	reg.PaymentStatus = u.Status
+	reg.PaymentRef = u.Ref
+	reg.AmountDue = u.AmountDue
+	reg.Currency = u.Currency
+	reg.UpdatedAt = u.At
+	if u.Status == models.PaymentStatusCompleted {
+		reg.PaidAt = u.At
+	}
+}
+
+// RegistrationFilter narrows the registrations returned by
+// RegistrationRepository.List and Each. Zero-valued fields are ignored.
+type RegistrationFilter struct {
+	TicketType    string
+	PaymentStatus string
+	Country       string
+	// Registrations created in [CreatedFrom, CreatedBefore) are returned
+	CreatedFrom   time.Time
+	CreatedBefore time.Time
+}
+
+// Sort keys accepted by RegistrationRepository.ListPage
+const (
+	SortByCreatedAt = "createdAt"
+	SortByLastName  = "lastName"
+)
+
+// DefaultPageSize is used when PageRequest.Limit is not positive.
+const DefaultPageSize = 50
+
+// PageRequest selects a page of a cursor-paginated list.
+type PageRequest struct {
+	// SortBy is one of the SortBy constants; ties are broken by ID
+	SortBy     string
+	Descending bool
+	Limit      int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// RegistrationPage is one page of registrations.
+type RegistrationPage struct {
+	Registrations []models.Registration
+	// NextCursor fetches the following page; empty on the last page
+	NextCursor string
+	// Total is the number of registrations matching the filter on all pages
+	Total int
+}
+
+// SessionFilter narrows the sessions returned by SessionRepository.List.
+// Zero-valued fields are ignored.
+type SessionFilter struct {
+	Track string
+	Tag   string
+	// Sessions starting in [StartFrom, StartBefore) are returned
+	StartFrom   time.Time
+	StartBefore time.Time
+}
+
+// SessionRepository persists conference sessions.
+type SessionRepository interface {
+	// Create stores a new session and sets its ID.
+	Create(ctx context.Context, session *models.Session) error
+	// Get returns the session with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (*models.Session, error)
+	// Update replaces the editable fields of the stored session identified by
+	// session.ID and sets session.Enrolled to the stored count, which is owned
+	// by EnrollmentRepository. It returns ErrCapacityBelowEnrolled, leaving
+	// the session unchanged, when a limited capacity is below that count.
+	Update(ctx context.Context, session *models.Session) error
+	// Delete removes the session with the given ID.
+	Delete(ctx context.Context, id string) error
+	// List returns sessions matching filter ordered by start time.
+	List(ctx context.Context, filter SessionFilter) ([]models.Session, error)
+}
+
+// EnrollmentRepository manages session enrollments and waitlists.
+// Implementations must update the session's enrollment count atomically with
+// the enrollment so that a session can never be oversubscribed.
+type EnrollmentRepository interface {
+	// Enroll adds enrollment to its session, or to the end of the session's
+	// waitlist when it is full, and sets enrollment.Status accordingly. It
+	// returns ErrNotFound if the session does not exist and
+	// ErrAlreadyEnrolled if the user is already enrolled or waitlisted.
+	Enroll(ctx context.Context, enrollment *models.Enrollment) error
+	// Drop removes userID from sessionID or its waitlist, or returns
+	// ErrNotFound. Seats freed by the drop are given to the head of the
+	// waitlist; the dropped and the promoted enrollments are returned.
+	Drop(ctx context.Context, sessionID, userID string) (*models.Enrollment, []models.Enrollment, error)
+	// DropAllForUser drops userID from every session and waitlist, giving
+	// the freed seats to the waitlists, and returns the promoted enrollments.
+	DropAllForUser(ctx context.Context, userID string) ([]models.Enrollment, error)
+	// DeleteBySession removes the enrollments and waitlist entries of
+	// sessionID, e.g. after the session was deleted.
+	DeleteBySession(ctx context.Context, sessionID string) error
+	// Promote fills any free seats in sessionID from its waitlist, e.g.
+	// after its capacity was raised, and returns the promoted enrollments.
+	Promote(ctx context.Context, sessionID string) ([]models.Enrollment, error)
+	// WaitlistPosition returns the 1-based waitlist position of userID in
+	// sessionID or ErrNotFound if the user is not waitlisted.
+	WaitlistPosition(ctx context.Context, sessionID, userID string) (int, error)
+	// ListByUser returns the enrollments held by userID.
+	ListByUser(ctx context.Context, userID string) ([]models.Enrollment, error)
+	// ListBySession returns the enrollments and waitlist entries of
+	// sessionID ordered by enrollment time.
+	ListBySession(ctx context.Context, sessionID string) ([]models.Enrollment, error)
+}
+
+// TicketTypeRepository persists the ticket type catalog and its inventory.
+type TicketTypeRepository interface {
+	// Create stores a new ticket type keyed by its ID or returns ErrAlreadyExists.
+	Create(ctx context.Context, ticketType *models.TicketType) error
+	// Get returns the ticket type with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (*models.TicketType, error)
+	// Update replaces the editable fields of the stored ticket type. The
+	// sold count is owned by Reserve and Release; Update sets it on
+	// ticketType and returns ErrQuantityBelowSold if a limited quantity is
+	// lower.
+	Update(ctx context.Context, ticketType *models.TicketType) error
+	// Delete removes the ticket type with the given ID. It returns
+	// ErrNotFound for unknown types and ErrInUse if tickets were sold.
+	Delete(ctx context.Context, id string) error
+	// List returns every ticket type ordered by price.
+	List(ctx context.Context) ([]models.TicketType, error)
+	// Reserve atomically takes one ticket from inventory. It returns
+	// ErrNotFound for unknown types and ErrSoldOut when none are left.
+	Reserve(ctx context.Context, id string) error
+	// Release atomically returns one ticket to inventory.
+	Release(ctx context.Context, id string) error
+}
+
+// PromoCodeRepository persists promo codes and their redemption counts.
+type PromoCodeRepository interface {
+	// Create stores a new promo code keyed by its ID or returns ErrAlreadyExists.
+	Create(ctx context.Context, promo *models.PromoCode) error
+	// Get returns the promo code with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (*models.PromoCode, error)
+	// Update replaces the editable fields of the stored promo code. The use
+	// count is owned by Redeem and Release; Update sets it on promo and
+	// returns ErrMaxUsesBelowUses if a usage cap is lower.
+	Update(ctx context.Context, promo *models.PromoCode) error
+	// Delete removes the promo code with the given ID. It returns
+	// ErrNotFound for unknown codes and ErrInUse if the code was redeemed.
+	Delete(ctx context.Context, id string) error
+	// List returns every promo code ordered by ID.
+	List(ctx context.Context) ([]models.PromoCode, error)
+	// Redeem atomically counts one use of the code. It returns ErrNotFound
+	// for unknown codes and ErrUsageLimitReached when the cap is reached.
+	Redeem(ctx context.Context, id string) error
+	// Release atomically gives back one use of the code.
+	Release(ctx context.Context, id string) error
+}
+
+// AuditFilter narrows the entries returned by AuditRepository.List.
+// Zero-valued fields are ignored.
+type AuditFilter struct {
+	ActorUID   string
+	TargetType string
+	TargetID   string
+	// Entries created in [From, Before) are returned
+	From   time.Time
+	Before time.Time
+	// Limit caps the number of entries returned; DefaultPageSize if not positive
+	Limit int
+	// Cursor is the NextCursor of the previous page, empty for the first page
+	Cursor string
+}
+
+// AuditPage is one page of audit entries.
+type AuditPage struct {
+	Entries []models.AuditEntry
+	// NextCursor fetches the following, older page; empty on the last page
+	NextCursor string
+}
+
+// AuditRepository stores the audit log. It is append-only by design: there
+// is no way to change or remove an entry once written.
+type AuditRepository interface {
+	// Append stores a new entry and sets its ID.
+	Append(ctx context.Context, entry *models.AuditEntry) error
+	// List returns a page of the entries matching filter, newest first and
+	// by descending ID within the same time. It returns ErrInvalidCursor if
+	// filter.Cursor is malformed.
+	List(ctx context.Context, filter AuditFilter) (*AuditPage, error)
+}
+
+// Repositories groups the storage backends used by the HTTP layer.
+type Repositories struct {
+	Users         UserRepository
+	Registrations RegistrationRepository
+	Sessions      SessionRepository
+	Enrollments   EnrollmentRepository
+	TicketTypes   TicketTypeRepository
+	PromoCodes    PromoCodeRepository
+	Audit         AuditRepository
+}
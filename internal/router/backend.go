@@ -0,0 +1,51 @@
+package router
+
+import (
+	"backend-ITC/internal/devauth"
+	"backend-ITC/internal/firebase"
+	"backend-ITC/internal/health"
+	"backend-ITC/internal/repository"
+)
+
+// Backend holds the storage and identity services the routes are served
+// from
+type Backend struct {
+	Repositories *repository.Repositories
+	Tokens       firebase.TokenVerifier
+	Users        firebase.UserDirectory
+
+	// ReadinessChecks are run by /readyz, keyed by dependency name
+	ReadinessChecks map[string]health.CheckFunc
+
+	// DevTokens mints tokens for /dev/token, which is only routed when set
+	DevTokens *devauth.Authenticator
+
+	// firebase reports call metrics, if the backend uses Firebase
+	firebase *firebase.Client
+}
+
+// NewFirebaseBackend serves from Firestore and verifies Firebase ID tokens
+func NewFirebaseBackend(fc *firebase.Client) *Backend {
+	return &Backend{
+		Repositories: repository.NewFirestore(fc.Firestore),
+		Tokens:       fc,
+		Users:        fc,
+		ReadinessChecks: map[string]health.CheckFunc{
+			"firestore": fc.PingFirestore,
+			"auth":      fc.PingAuth,
+		},
+		firebase: fc,
+	}
+}
+
+// NewDevBackend serves from process memory and accepts the dev tokens of
+// tokens, so the server runs without a Firebase project. Data is lost when
+// the process exits.
+func NewDevBackend(tokens *devauth.Authenticator) *Backend {
+	return &Backend{
+		Repositories: repository.NewMemory(),
+		Tokens:       tokens,
+		Users:        tokens,
+		DevTokens:    tokens,
+	}
+}
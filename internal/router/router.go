@@ -1,30 +1,63 @@
 package router
 
 import (
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"backend-ITC/internal/apidoc"
+	"backend-ITC/internal/audit"
 	"backend-ITC/internal/config"
-	"backend-ITC/internal/firebase"
 	"backend-ITC/internal/handlers"
+	"backend-ITC/internal/health"
+	"backend-ITC/internal/metrics"
 	"backend-ITC/internal/middleware"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/notify"
+	"backend-ITC/internal/payment"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// Setup initializes and returns the Gin router with all routes
-func Setup(cfg *config.Config, fc *firebase.Client) *gin.Engine {
+// Setup initializes and returns the Gin router with all routes served from
+// backend
+func Setup(cfg *config.Config, backend *Backend, notifier notify.Notifier) (*gin.Engine, error) {
 	// Set Gin mode based on environment
 	if cfg.IsProduction() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	r := gin.Default()
+	r := gin.New()
+
+	// Record Firebase call metrics alongside the request metrics below
+	metricsRegistry := metrics.NewRegistry()
+	if backend.firebase != nil {
+		backend.firebase.SetObserver(metrics.NewFirebase(metricsRegistry))
+	}
+
+	// Tag requests with an ID first so every later log record carries it.
+	// Logging and metrics wrap recovery so they see panics as 500s.
+	logger := slog.Default()
+	r.Use(
+		middleware.RequestID(),
+		middleware.AccessLog(logger),
+		middleware.Metrics(metrics.NewHTTP(metricsRegistry)),
+		middleware.Recovery(logger),
+	)
+
+	// Only trust X-Forwarded-For from known proxies so clients cannot spoof
+	// the IP used for rate limiting and audit entries
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("set trusted proxies: %w", err)
+	}
 
 	// Configure CORS
 	corsConfig := cors.Config{
 		AllowOrigins:     []string{cfg.FrontendURL},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader},
 		AllowCredentials: true,
 	}
 
@@ -36,35 +69,115 @@ func Setup(cfg *config.Config, fc *firebase.Client) *gin.Engine {
 
 	r.Use(cors.New(corsConfig))
 
-	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(fc)
-	registrationHandler := handlers.NewRegistrationHandler(fc)
+	// Initialize storage and the audit log
+	repos := backend.Repositories
+	auditLog := audit.NewLogger(repos.Audit)
+	metrics.RegisterRegistrations(metricsRegistry, repos.Registrations, cfg.MetricsRefreshInterval)
+
+	// Initialize payment provider
+	paymentProvider, err := payment.NewProvider(cfg.PaymentProvider, cfg.PaymentAPIKey, cfg.PaymentWebhookSecret, cfg.FrontendURL)
+	if err != nil {
+		return nil, fmt.Errorf("initialize payment provider: %w", err)
+	}
 
 	// Initialize middleware
-	authMiddleware := middleware.NewAuthMiddleware(fc)
-
-	// Health check endpoint
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "healthy",
-			"message": "Conference API is running",
-		})
+	authMiddleware := middleware.NewAuthMiddleware(backend.Tokens, backend.Users, repos.Users, middleware.AuthCacheConfig{
+		TTL:        cfg.AuthCacheTTL,
+		MaxEntries: cfg.AuthCacheSize,
 	})
+	rateLimits := middleware.NewMemoryRateLimitStore()
+	publicRateLimit := middleware.RateLimiter(rateLimits, "public", middleware.RateLimit{
+		Rate:  cfg.PublicRateLimit,
+		Burst: cfg.PublicRateBurst,
+	}, middleware.KeyByIP)
+	userRateLimit := middleware.RateLimiter(rateLimits, "user", middleware.RateLimit{
+		Rate:  cfg.UserRateLimit,
+		Burst: cfg.UserRateBurst,
+	}, middleware.KeyByUID)
+
+	// Initialize handlers
+	authHandler := handlers.NewAuthHandler(backend.Tokens, backend.Users, repos.Users, authMiddleware)
+	registrationHandler := handlers.NewRegistrationHandler(repos.Registrations, repos.TicketTypes, repos.PromoCodes, repos.Enrollments, repos.Sessions, notifier, auditLog)
+	promoCodeHandler := handlers.NewPromoCodeHandler(repos.PromoCodes, auditLog)
+	checkInHandler := handlers.NewCheckInHandler(repos.Registrations, cfg.SessionSecret, auditLog)
+	ticketTypeHandler := handlers.NewTicketTypeHandler(repos.TicketTypes, auditLog)
+	paymentHandler := handlers.NewPaymentHandler(repos.Registrations, repos.TicketTypes, paymentProvider, notifier, auditLog, cfg.FrontendURL)
+	enrollmentHandler := handlers.NewEnrollmentHandler(repos.Enrollments, repos.Registrations, repos.Sessions, notifier, auditLog)
+	sessionHandler := handlers.NewSessionHandler(repos.Sessions, repos.Enrollments, notifier, auditLog)
+	adminHandler := handlers.NewAdminHandler(backend.Users, authMiddleware, auditLog)
+	auditHandler := handlers.NewAuditHandler(repos.Audit)
+
+	// Readiness depends on the backend's services, checked in name order
+	checker := health.NewChecker(cfg.ReadinessTimeout)
+	checkNames := make([]string, 0, len(backend.ReadinessChecks))
+	for name := range backend.ReadinessChecks {
+		checkNames = append(checkNames, name)
+	}
+	sort.Strings(checkNames)
+	for _, name := range checkNames {
+		checker.Add(name, backend.ReadinessChecks[name])
+	}
+	healthHandler := handlers.NewHealthHandler(checker)
+
+	// Liveness and readiness probes. /health is kept for existing monitors
+	// and behaves like /livez.
+	r.GET("/livez", healthHandler.Livez)
+	r.GET("/readyz", healthHandler.Readyz)
+	r.GET("/health", healthHandler.Livez)
+
+	// Dev token minting for offline development
+	if backend.DevTokens != nil {
+		devHandler := handlers.NewDevHandler(backend.DevTokens)
+		r.GET("/dev/token", devHandler.IssueToken)
+	}
+
+	// Prometheus metrics endpoint
+	r.GET("/metrics", middleware.RequireBearerToken(cfg.MetricsToken), gin.WrapH(metrics.Handler(metricsRegistry)))
 
 	// API v1 routes
 	v1 := r.Group("/api/v1")
 	{
+		// API documentation (public)
+		v1.GET("/openapi.json", apidoc.SpecHandler)
+		v1.GET("/docs", apidoc.UIHandler)
+		v1.GET("/docs/assets/:file", apidoc.AssetHandler)
+
 		// Auth routes (public)
 		auth := v1.Group("/auth")
+		auth.Use(publicRateLimit)
 		{
 			auth.POST("/google", authHandler.GoogleLogin)
-			auth.POST("/verify", authHandler.VerifyToken)
+			auth.POST("/verify", authMiddleware.RequireAuth(), authHandler.VerifyToken)
 			auth.POST("/logout", authHandler.Logout)
 		}
 
+		// Session routes (public)
+		sessions := v1.Group("/sessions")
+		sessions.Use(publicRateLimit)
+		{
+			sessions.GET("", sessionHandler.ListSessions)
+			sessions.GET("/:id", sessionHandler.GetSession)
+		}
+
+		// Payment routes (public, verified by webhook signature). Not rate
+		// limited so provider retries are never rejected
+		payments := v1.Group("/payments")
+		{
+			payments.POST("/webhook", paymentHandler.Webhook)
+
+			// Offline payment simulation with the fake provider. It is
+			// unauthenticated, so it is only routed in dev mode.
+			if cfg.DevMode {
+				payments.POST("/fake/:sessionId", paymentHandler.SimulatePayment)
+			}
+		}
+
+		// Ticket type routes (public)
+		v1.GET("/ticket-types", publicRateLimit, ticketTypeHandler.ListOnSale)
+
 		// Protected routes
 		protected := v1.Group("")
-		protected.Use(authMiddleware.RequireAuth())
+		protected.Use(authMiddleware.RequireAuth(), userRateLimit)
 		{
 			// User routes
 			protected.GET("/me", authHandler.GetCurrentUser)
@@ -76,16 +189,90 @@ func Setup(cfg *config.Config, fc *firebase.Client) *gin.Engine {
 				registrations.GET("/me", registrationHandler.GetMyRegistration)
 				registrations.PUT("/me", registrationHandler.UpdateRegistration)
 				registrations.DELETE("/me", registrationHandler.DeleteRegistration)
+				registrations.POST("/me/checkout", paymentHandler.CreateCheckout)
+				registrations.GET("/me/ticket", checkInHandler.GetMyTicket)
 			}
+
+			// On-site check-in
+			checkIn := protected.Group("/checkin")
+			checkIn.Use(middleware.RequireRole(models.RoleCheckInStaff, models.RoleOrganizer, models.RoleAdmin))
+			{
+				checkIn.POST("/scan", checkInHandler.Scan)
+			}
+
+			// Enrollment routes
+			protected.POST("/sessions/:id/enrollment", enrollmentHandler.Enroll)
+			protected.DELETE("/sessions/:id/enrollment", enrollmentHandler.Drop)
+			protected.GET("/sessions/:id/waitlist/position", enrollmentHandler.GetWaitlistPosition)
+			protected.GET("/enrollments/me", enrollmentHandler.GetMyEnrollments)
 		}
 
-		// Admin routes (add admin middleware as needed)
+		// Admin routes
 		admin := v1.Group("/admin")
-		admin.Use(authMiddleware.RequireAuth())
+		admin.Use(authMiddleware.RequireAuth(), userRateLimit)
 		{
-			admin.GET("/registrations", registrationHandler.GetAllRegistrations)
+			staff := admin.Group("")
+			staff.Use(middleware.RequireRole(models.RoleAdmin, models.RoleOrganizer))
+			{
+				staff.GET("/registrations", registrationHandler.GetAllRegistrations)
+				staff.GET("/registrations/export", registrationHandler.ExportRegistrations)
+
+				staff.POST("/sessions", sessionHandler.CreateSession)
+				staff.PUT("/sessions/:id", sessionHandler.UpdateSession)
+				staff.DELETE("/sessions/:id", sessionHandler.DeleteSession)
+				staff.GET("/sessions/:id/roster", enrollmentHandler.GetSessionRoster)
+			}
+
+			// Ticket catalog (admins only)
+			ticketTypes := admin.Group("/ticket-types")
+			ticketTypes.Use(middleware.RequireRole(models.RoleAdmin))
+			{
+				ticketTypes.GET("", ticketTypeHandler.ListTicketTypes)
+				ticketTypes.POST("", ticketTypeHandler.CreateTicketType)
+				ticketTypes.PUT("/:id", ticketTypeHandler.UpdateTicketType)
+				ticketTypes.DELETE("/:id", ticketTypeHandler.DeleteTicketType)
+			}
+
+			// Promo codes (admins only)
+			promoCodes := admin.Group("/promo-codes")
+			promoCodes.Use(middleware.RequireRole(models.RoleAdmin))
+			{
+				promoCodes.GET("", promoCodeHandler.ListPromoCodes)
+				promoCodes.POST("", promoCodeHandler.CreatePromoCode)
+				promoCodes.PUT("/:id", promoCodeHandler.UpdatePromoCode)
+				promoCodes.DELETE("/:id", promoCodeHandler.DeletePromoCode)
+			}
+
+			// Role management (admins only)
+			users := admin.Group("/users")
+			users.Use(middleware.RequireRole(models.RoleAdmin))
+			{
+				users.GET("/:uid/roles", adminHandler.GetUserRoles)
+				users.POST("/:uid/roles", adminHandler.GrantRole)
+				users.DELETE("/:uid/roles/:role", adminHandler.RevokeRole)
+			}
+
+			// Audit log (admins only)
+			auditEntries := admin.Group("/audit")
+			auditEntries.Use(middleware.RequireRole(models.RoleAdmin))
+			{
+				auditEntries.GET("", auditHandler.ListAuditEntries)
+			}
+
+			// Cache effectiveness (admins only)
+			stats := admin.Group("/stats")
+			stats.Use(middleware.RequireRole(models.RoleAdmin))
+			{
+				stats.GET("/auth-cache", func(c *gin.Context) {
+					c.JSON(200, gin.H{
+						"success": true,
+						"message": "Auth cache statistics retrieved successfully",
+						"stats":   authMiddleware.CacheStats(),
+					})
+				})
+			}
 		}
 	}
 
-	return r
+	return r, nil
 }
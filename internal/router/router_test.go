@@ -0,0 +1,92 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"backend-ITC/internal/apidoc"
+	"backend-ITC/internal/config"
+	"backend-ITC/internal/devauth"
+	"backend-ITC/internal/notify"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestRoutesMatchOpenAPISpec fails when a route is added without documenting
+// it, or when the spec documents a route that no longer exists
+func TestRoutesMatchOpenAPISpec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Development mode with the dev backend registers every route,
+	// including the fake payments and dev tokens
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Environment = "development"
+	cfg.DevMode = true
+	r, err := Setup(cfg, NewDevBackend(devauth.New(cfg.SessionSecret)), notify.NewLogNotifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documented := make(map[string]bool)
+	for path, item := range apidoc.Spec().Paths {
+		for method := range item {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, route := range r.Routes() {
+		key := route.Method + " " + apidoc.OpenAPIPath(route.Path)
+		if !documented[key] {
+			t.Errorf("route %s is missing from the OpenAPI spec", key)
+		}
+		delete(documented, key)
+	}
+
+	for key := range documented {
+		t.Errorf("OpenAPI spec documents %s, which is not routed", key)
+	}
+}
+
+// TestSetupRejectsUnknownPaymentProvider reports a bad provider as an error
+// instead of exiting
+func TestSetupRejectsUnknownPaymentProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.PaymentProvider = "unknown"
+
+	if _, err := Setup(cfg, NewDevBackend(devauth.New(cfg.SessionSecret)), notify.NewLogNotifier()); err == nil {
+		t.Error("Setup accepted an unknown payment provider")
+	}
+}
+
+// TestSetupAcceptsProductionConfig makes sure a configuration that passes
+// the production checks also sets up a payment provider
+func TestSetupAcceptsProductionConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Environment = "production"
+	cfg.SessionSecret = "a-production-session-secret"
+	cfg.PaymentProvider = "stripe"
+	cfg.PaymentAPIKey = "sk_test_key"
+	cfg.PaymentWebhookSecret = "whsec_production"
+	cfg.FirebaseProjectID = "itc-conference"
+	cfg.MetricsToken = "a-metrics-token"
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if _, err := Setup(cfg, NewDevBackend(devauth.New(cfg.SessionSecret)), notify.NewLogNotifier()); err != nil {
+		t.Fatalf("Setup() = %v", err)
+	}
+}
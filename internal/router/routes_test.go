@@ -0,0 +1,761 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"backend-ITC/internal/apidoc"
+	"backend-ITC/internal/config"
+	"backend-ITC/internal/devauth"
+	"backend-ITC/internal/firebase/firebasetest"
+	"backend-ITC/internal/handlers"
+	"backend-ITC/internal/health"
+	"backend-ITC/internal/models"
+	"backend-ITC/internal/notify"
+	"backend-ITC/internal/payment"
+	"backend-ITC/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testMetricsToken protects /metrics in tests
+const testMetricsToken = "test-metrics-token"
+
+// testServer serves router.Setup from in-memory storage with a fake
+// Firebase Auth
+type testServer struct {
+	t        *testing.T
+	cfg      *config.Config
+	auth     *firebasetest.Auth
+	notifier *countingNotifier
+	engine   *gin.Engine
+}
+
+// countingNotifier is a LogNotifier that counts the payment receipts it sends
+type countingNotifier struct {
+	*notify.LogNotifier
+	receipts atomic.Int64
+}
+
+func (n *countingNotifier) PaymentReceived(ctx context.Context, reg *models.Registration) error {
+	n.receipts.Add(1)
+	return n.LogNotifier.PaymentReceived(ctx, reg)
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Environment = "development"
+	// Routes the fake payment simulation
+	cfg.DevMode = true
+	cfg.MetricsToken = testMetricsToken
+	// No rate limits or caches, so every request reaches the fake
+	cfg.PublicRateLimit = 0
+	cfg.UserRateLimit = 0
+	cfg.AuthCacheTTL = 0
+
+	fake := firebasetest.New()
+	backend := &Backend{
+		Repositories:    repository.NewMemory(),
+		Tokens:          fake,
+		Users:           fake,
+		ReadinessChecks: map[string]health.CheckFunc{"auth": fake.Ping},
+		DevTokens:       devauth.New(cfg.SessionSecret),
+	}
+
+	notifier := &countingNotifier{LogNotifier: notify.NewLogNotifier()}
+	engine, err := Setup(cfg, backend, notifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testServer{
+		t:        t,
+		cfg:      cfg,
+		auth:     fake,
+		notifier: notifier,
+		engine:   engine,
+	}
+}
+
+// serve sends req through the router
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.engine.ServeHTTP(w, req)
+	return w
+}
+
+// expect sends a request with an optional bearer token and JSON body and
+// fails the test unless the response has the wanted status
+func (s *testServer) expect(want int, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, bytes.NewReader(payload))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := s.serve(req)
+	if w.Code != want {
+		s.t.Fatalf("%s %s: got status %d, want %d; body: %s", method, path, w.Code, want, w.Body.String())
+	}
+	return w
+}
+
+// decode decodes the JSON body of w into out
+func decode(t *testing.T, w *httptest.ResponseRecorder, out interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+		t.Fatalf("decode %s: %v", w.Body.String(), err)
+	}
+}
+
+// TestRoutesEndToEnd walks through the life of a conference, from setting up
+// the catalog to check-in, and requires every route to succeed at least once
+func TestRoutesEndToEnd(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.auth.AddUser("admin", "admin@example.com", models.RoleAdmin)
+	organizer := s.auth.AddUser("organizer", "organizer@example.com", models.RoleOrganizer)
+	staff := s.auth.AddUser("staff", "staff@example.com", models.RoleCheckInStaff)
+	alice := s.auth.AddUser("alice", "alice@example.com")
+	bob := s.auth.AddUser("bob", "bob@example.com")
+
+	// Operations
+	s.expect(http.StatusOK, "GET", "/livez", "", nil)
+	s.expect(http.StatusOK, "GET", "/readyz", "", nil)
+	s.expect(http.StatusOK, "GET", "/health", "", nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/openapi.json", "", nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/docs", "", nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/docs/assets/swagger-ui-bundle.js", "", nil)
+	s.expect(http.StatusNotFound, "GET", "/api/v1/docs/assets/swagger.html", "", nil)
+	s.expect(http.StatusOK, "GET", "/dev/token?uid=dev", "", nil)
+	s.expect(http.StatusUnauthorized, "GET", "/metrics", "", nil)
+
+	// Ticket catalog
+	standard := gin.H{"id": "standard", "name": "Standard", "price": 5000, "currency": "EUR", "active": true}
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/ticket-types", admin, standard)
+	standard["description"] = "Full conference access"
+	s.expect(http.StatusOK, "PUT", "/api/v1/admin/ticket-types/standard", admin, standard)
+	s.expect(http.StatusOK, "GET", "/api/v1/admin/ticket-types", admin, nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/ticket-types", "", nil)
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/ticket-types", admin,
+		gin.H{"id": "retired", "name": "Retired", "currency": "EUR"})
+	s.expect(http.StatusOK, "DELETE", "/api/v1/admin/ticket-types/retired", admin, nil)
+
+	// Promo codes
+	save10 := gin.H{"id": "SAVE10", "discountType": "percent", "value": 10, "active": true}
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/promo-codes", admin, save10)
+	save10["description"] = "10% off"
+	s.expect(http.StatusOK, "PUT", "/api/v1/admin/promo-codes/SAVE10", admin, save10)
+	s.expect(http.StatusOK, "GET", "/api/v1/admin/promo-codes", admin, nil)
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/promo-codes", admin,
+		gin.H{"id": "UNUSED", "discountType": "fixed", "value": 500, "currency": "EUR"})
+	s.expect(http.StatusOK, "DELETE", "/api/v1/admin/promo-codes/UNUSED", admin, nil)
+
+	// Sessions, with a single seat so the second enrollment is waitlisted
+	start := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	session := gin.H{
+		"title":     "Keynote",
+		"speaker":   "Ada",
+		"startTime": start,
+		"endTime":   start.Add(time.Hour),
+		"capacity":  1,
+	}
+	var created handlers.SessionResponse
+	decode(t, s.expect(http.StatusCreated, "POST", "/api/v1/admin/sessions", organizer, session), &created)
+	sessionID := created.Session.ID
+	session["location"] = "Main hall"
+	s.expect(http.StatusOK, "PUT", "/api/v1/admin/sessions/"+sessionID, organizer, session)
+	s.expect(http.StatusOK, "GET", "/api/v1/sessions", "", nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/sessions/"+sessionID, "", nil)
+
+	// Authentication
+	s.expect(http.StatusOK, "POST", "/api/v1/auth/google", "", gin.H{"idToken": alice})
+	s.expect(http.StatusOK, "POST", "/api/v1/auth/verify", alice, nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/me", alice, nil)
+	s.expect(http.StatusOK, "POST", "/api/v1/auth/logout", "", nil)
+
+	// Registration
+	registration := gin.H{
+		"firstName":  "Alice",
+		"lastName":   "Liddell",
+		"email":      "alice@example.com",
+		"country":    "GB",
+		"ticketType": "standard",
+		"promoCode":  "save10",
+	}
+	s.expect(http.StatusCreated, "POST", "/api/v1/registrations", alice, registration)
+	s.expect(http.StatusConflict, "POST", "/api/v1/registrations", alice, registration)
+	s.expect(http.StatusConflict, "DELETE", "/api/v1/admin/promo-codes/SAVE10", admin, nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/registrations/me", alice, nil)
+	registration["city"] = "Oxford"
+	s.expect(http.StatusOK, "PUT", "/api/v1/registrations/me", alice, registration)
+	s.expect(http.StatusPaymentRequired, "GET", "/api/v1/registrations/me/ticket", alice, nil)
+
+	// Payment through the fake provider, then a redelivery to the webhook
+	var checkout handlers.PaymentResponse
+	decode(t, s.expect(http.StatusOK, "POST", "/api/v1/registrations/me/checkout", alice, nil), &checkout)
+	if checkout.Registration.AmountDue != 4500 {
+		t.Fatalf("amount due = %d, want 4500 after the promo code", checkout.Registration.AmountDue)
+	}
+
+	var paid handlers.PaymentResponse
+	decode(t, s.expect(http.StatusOK, "POST", "/api/v1/payments/fake/"+checkout.Checkout.ID, "",
+		gin.H{"type": payment.EventPaymentSucceeded}), &paid)
+	if paid.Registration.PaymentStatus != models.PaymentStatusCompleted {
+		t.Fatalf("payment status = %q, want %q", paid.Registration.PaymentStatus, models.PaymentStatusCompleted)
+	}
+
+	event, err := json.Marshal(payment.Event{
+		ID:                "evt_redelivery",
+		Type:              payment.EventPaymentSucceeded,
+		CheckoutSessionID: checkout.Checkout.ID,
+		RegistrationID:    checkout.Registration.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(event))
+	if w := s.serve(unsigned); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unsigned webhook: got status %d, want 401", w.Code)
+	}
+	signed := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(event))
+	signed.Header.Set(payment.SignatureHeader, payment.Sign(s.cfg.PaymentWebhookSecret, event, time.Now()))
+	if w := s.serve(signed); w.Code != http.StatusOK {
+		t.Fatalf("signed webhook: got status %d, want 200; body: %s", w.Code, w.Body.String())
+	}
+
+	s.expect(http.StatusConflict, "DELETE", "/api/v1/registrations/me", alice, nil)
+
+	// Check-in
+	var ticket handlers.CheckInResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/registrations/me/ticket", alice, nil), &ticket)
+	s.expect(http.StatusOK, "POST", "/api/v1/checkin/scan", staff, gin.H{"token": ticket.Token})
+	s.expect(http.StatusConflict, "POST", "/api/v1/checkin/scan", staff, gin.H{"token": ticket.Token})
+
+	// Enrollment: Alice takes the only seat, Bob is waitlisted and promoted
+	// once Alice drops
+	enrollment := "/api/v1/sessions/" + sessionID + "/enrollment"
+	s.expect(http.StatusForbidden, "POST", enrollment, bob, nil)
+	s.expect(http.StatusCreated, "POST", enrollment, alice, nil)
+	s.expect(http.StatusCreated, "POST", "/api/v1/registrations", bob, gin.H{
+		"firstName":  "Bob",
+		"lastName":   "Builder",
+		"email":      "bob@example.com",
+		"country":    "IE",
+		"ticketType": "standard",
+	})
+	s.expect(http.StatusAccepted, "POST", enrollment, bob, nil)
+
+	var position handlers.EnrollmentResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/sessions/"+sessionID+"/waitlist/position", bob, nil), &position)
+	if position.Position != 1 {
+		t.Fatalf("waitlist position = %d, want 1", position.Position)
+	}
+	s.expect(http.StatusOK, "GET", "/api/v1/enrollments/me", alice, nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/admin/sessions/"+sessionID+"/roster", organizer, nil)
+	s.expect(http.StatusOK, "DELETE", enrollment, alice, nil)
+
+	var bobs handlers.EnrollmentResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/enrollments/me", bob, nil), &bobs)
+	if len(bobs.Enrollments) != 1 || bobs.Enrollments[0].IsWaitlisted() {
+		t.Fatalf("Bob's enrollments = %+v, want one promoted enrollment", bobs.Enrollments)
+	}
+
+	// The drop and the promotion are audited with the enrollments
+	var entries handlers.AuditResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/admin/audit?targetType=session&target="+sessionID, admin, nil), &entries)
+	audited := make(map[string]bool)
+	for _, entry := range entries.Entries {
+		if len(entry.Changes) > 0 {
+			audited[entry.Action] = true
+		}
+	}
+	if !audited["enrollment.delete"] || !audited["enrollment.promote"] {
+		t.Errorf("audit entries = %+v, want enrollment.delete and enrollment.promote with changes", entries.Entries)
+	}
+
+	// Reports
+	var list handlers.RegistrationListResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/admin/registrations", organizer, nil), &list)
+	s.expect(http.StatusBadRequest, "GET", "/api/v1/admin/registrations?sort=lastName&from=2024-01-01", organizer, nil)
+	if len(list.Registrations) != 2 {
+		t.Fatalf("listed %d registrations, want 2", len(list.Registrations))
+	}
+	csv := s.expect(http.StatusOK, "GET", "/api/v1/admin/registrations/export?format=csv", organizer, nil)
+	if !strings.Contains(csv.Body.String(), "alice@example.com") {
+		t.Fatalf("export is missing Alice's registration:\n%s", csv.Body.String())
+	}
+
+	// Role management; revoking a role signs the user out
+	carol := s.auth.AddUser("carol", "carol@example.com", models.RoleOrganizer)
+	s.expect(http.StatusOK, "GET", "/api/v1/admin/users/carol/roles", admin, nil)
+	s.expect(http.StatusOK, "POST", "/api/v1/admin/users/carol/roles", admin, gin.H{"role": models.RoleCheckInStaff})
+	s.expect(http.StatusOK, "GET", "/api/v1/admin/registrations", carol, nil)
+	s.expect(http.StatusOK, "DELETE", "/api/v1/admin/users/carol/roles/"+models.RoleOrganizer, admin, nil)
+	s.expect(http.StatusUnauthorized, "GET", "/api/v1/admin/registrations", carol, nil)
+	s.expect(http.StatusUnauthorized, "POST", "/api/v1/auth/verify", carol, nil)
+	s.expect(http.StatusUnauthorized, "POST", "/api/v1/auth/google", "", gin.H{"idToken": carol})
+	dave := s.auth.AddUser("dave", "dave@example.com")
+	s.expect(http.StatusBadRequest, "DELETE", "/api/v1/admin/users/dave/roles/superuser", admin, nil)
+	s.expect(http.StatusOK, "DELETE", "/api/v1/admin/users/dave/roles/"+models.RoleOrganizer, admin, nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/me", dave, nil)
+	s.expect(http.StatusNotFound, "GET", "/api/v1/admin/users/nobody/roles", admin, nil)
+
+	var all handlers.AuditResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/admin/audit?limit=500", admin, nil), &all)
+	var paged []string
+	for cursor := ""; ; {
+		var page handlers.AuditResponse
+		decode(t, s.expect(http.StatusOK, "GET", "/api/v1/admin/audit?limit=7&cursor="+cursor, admin, nil), &page)
+		for _, entry := range page.Entries {
+			paged = append(paged, entry.ID)
+		}
+		if cursor = page.NextCursor; cursor == "" || len(paged) > len(all.Entries) {
+			break
+		}
+	}
+	if len(paged) != len(all.Entries) {
+		t.Errorf("paged through %d audit entries, want %d", len(paged), len(all.Entries))
+	}
+	s.expect(http.StatusBadRequest, "GET", "/api/v1/admin/audit?cursor=bogus", admin, nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/admin/stats/auth-cache", admin, nil)
+
+	// Cleanup
+	s.expect(http.StatusOK, "DELETE", "/api/v1/registrations/me", bob, nil)
+	s.expect(http.StatusNotFound, "GET", "/api/v1/registrations/me", bob, nil)
+	s.expect(http.StatusOK, "DELETE", "/api/v1/admin/sessions/"+sessionID, organizer, nil)
+	s.expect(http.StatusNotFound, "GET", "/api/v1/sessions/"+sessionID, "", nil)
+
+	// Every route must have been served successfully. The request metrics
+	// report which route patterns were; the first scrape is itself counted
+	// by the second.
+	s.expect(http.StatusOK, "GET", "/metrics", testMetricsToken, nil)
+	metrics := s.expect(http.StatusOK, "GET", "/metrics", testMetricsToken, nil)
+
+	served := make(map[string]bool)
+	sample := regexp.MustCompile(`^http_requests_total\{method="([A-Z]+)",route="([^"]+)",status="2\d\d"\}`)
+	scanner := bufio.NewScanner(metrics.Body)
+	for scanner.Scan() {
+		if m := sample.FindStringSubmatch(scanner.Text()); m != nil {
+			served[m[1]+" "+m[2]] = true
+		}
+	}
+
+	for _, route := range s.engine.Routes() {
+		if !served[route.Method+" "+route.Path] {
+			t.Errorf("route %s %s was never served successfully", route.Method, route.Path)
+		}
+	}
+}
+
+// TestAuthFailures sends bad credentials to every route documented as
+// requiring a Firebase ID token
+func TestAuthFailures(t *testing.T) {
+	s := newTestServer(t)
+	s.auth.AddUser("alice", "alice@example.com")
+	expired := s.auth.ExpiredToken("alice")
+
+	cases := []struct {
+		name   string
+		header string
+	}{
+		{"missing header", ""},
+		{"not a bearer token", "Basic YWxpY2U6c2VjcmV0"},
+		{"bearer without token", "Bearer"},
+		{"unknown token", "Bearer not-a-token"},
+		{"expired token", "Bearer " + expired},
+	}
+
+	for _, route := range authenticatedRoutes() {
+		for _, tc := range cases {
+			t.Run(route.method+" "+route.path+"/"+tc.name, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, route.path, nil)
+				if tc.header != "" {
+					req.Header.Set("Authorization", tc.header)
+				}
+				if w := s.serve(req); w.Code != http.StatusUnauthorized {
+					t.Errorf("got status %d, want 401; body: %s", w.Code, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+// TestAuthOutage rejects valid tokens while Firebase Auth is unavailable,
+// and reports it through the readiness probe
+func TestAuthOutage(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.auth.AddUser("alice", "alice@example.com")
+
+	s.expect(http.StatusOK, "GET", "/api/v1/me", alice, nil)
+	s.expect(http.StatusOK, "GET", "/readyz", "", nil)
+
+	s.auth.SetError(errors.New("auth unavailable"))
+	for _, route := range authenticatedRoutes() {
+		s.expect(http.StatusUnauthorized, route.method, route.path, alice, nil)
+	}
+
+	var report health.Report
+	decode(t, s.expect(http.StatusServiceUnavailable, "GET", "/readyz", "", nil), &report)
+	if report.Checks["auth"].Status != health.StatusDown {
+		t.Errorf("auth check = %+v, want down", report.Checks["auth"])
+	}
+	s.expect(http.StatusOK, "GET", "/livez", "", nil)
+
+	s.auth.SetError(nil)
+	s.expect(http.StatusOK, "GET", "/api/v1/me", alice, nil)
+	s.expect(http.StatusOK, "GET", "/readyz", "", nil)
+}
+
+// TestDeletedUser rejects the still valid token of a user that no longer
+// exists in Firebase Auth
+func TestDeletedUser(t *testing.T) {
+	s := newTestServer(t)
+	alice := s.auth.AddUser("alice", "alice@example.com")
+
+	s.expect(http.StatusOK, "GET", "/api/v1/me", alice, nil)
+	s.auth.DeleteUser("alice")
+	s.expect(http.StatusUnauthorized, "GET", "/api/v1/me", alice, nil)
+	s.expect(http.StatusUnauthorized, "GET", "/api/v1/registrations/me", alice, nil)
+}
+
+// TestRoleChecks keeps attendees out of staff routes and organizers out of
+// admin-only routes
+func TestRoleChecks(t *testing.T) {
+	s := newTestServer(t)
+	attendee := s.auth.AddUser("alice", "alice@example.com")
+	organizer := s.auth.AddUser("olivia", "olivia@example.com", models.RoleOrganizer)
+	staff := s.auth.AddUser("sam", "sam@example.com", models.RoleCheckInStaff)
+
+	adminOnly := []string{
+		"/api/v1/admin/ticket-types",
+		"/api/v1/admin/promo-codes",
+		"/api/v1/admin/users",
+		"/api/v1/admin/audit",
+		"/api/v1/admin/stats",
+	}
+
+	for _, route := range authenticatedRoutes() {
+		staffOnly := strings.HasPrefix(route.path, "/api/v1/admin/") || strings.HasPrefix(route.path, "/api/v1/checkin/")
+		if !staffOnly {
+			continue
+		}
+
+		s.expect(http.StatusForbidden, route.method, route.path, attendee, nil)
+
+		isAdminOnly := false
+		for _, prefix := range adminOnly {
+			isAdminOnly = isAdminOnly || strings.HasPrefix(route.path, prefix)
+		}
+		if isAdminOnly {
+			s.expect(http.StatusForbidden, route.method, route.path, organizer, nil)
+		}
+		if strings.HasPrefix(route.path, "/api/v1/admin/") {
+			s.expect(http.StatusForbidden, route.method, route.path, staff, nil)
+		}
+	}
+}
+
+// TestConcurrentRegistrations submits the same registration several times at
+// once; one must succeed and the others must give their ticket back
+func TestConcurrentRegistrations(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.auth.AddUser("admin", "admin@example.com", models.RoleAdmin)
+	alice := s.auth.AddUser("alice", "alice@example.com")
+
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/ticket-types", admin,
+		gin.H{"id": "standard", "name": "Standard", "currency": "EUR", "active": true})
+
+	body, err := json.Marshal(gin.H{
+		"firstName":  "Alice",
+		"lastName":   "Liddell",
+		"email":      "alice@example.com",
+		"country":    "GB",
+		"ticketType": "standard",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const attempts = 8
+	statuses := make(chan int, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/api/v1/registrations", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+alice)
+			statuses <- s.serve(req).Code
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	created := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("got status %d, want 201 or 409", status)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d registrations created, want 1", created)
+	}
+
+	var catalog handlers.TicketTypeResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/admin/ticket-types", admin, nil), &catalog)
+	if len(catalog.TicketTypes) != 1 || catalog.TicketTypes[0].Sold != 1 {
+		t.Errorf("ticket types = %+v, want one ticket sold", catalog.TicketTypes)
+	}
+	s.expect(http.StatusConflict, "DELETE", "/api/v1/admin/ticket-types/standard", admin, nil)
+}
+
+// TestConcurrentPaymentWebhooks delivers the same payment event several
+// times at once; the payment must be recorded and receipted exactly once
+func TestConcurrentPaymentWebhooks(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.auth.AddUser("admin", "admin@example.com", models.RoleAdmin)
+	alice := s.auth.AddUser("alice", "alice@example.com")
+
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/ticket-types", admin,
+		gin.H{"id": "standard", "name": "Standard", "price": 5000, "currency": "EUR", "active": true})
+	s.expect(http.StatusCreated, "POST", "/api/v1/registrations", alice, gin.H{
+		"firstName":  "Alice",
+		"lastName":   "Liddell",
+		"email":      "alice@example.com",
+		"country":    "GB",
+		"ticketType": "standard",
+	})
+
+	var checkout handlers.PaymentResponse
+	decode(t, s.expect(http.StatusOK, "POST", "/api/v1/registrations/me/checkout", alice, nil), &checkout)
+
+	event, err := json.Marshal(payment.Event{
+		ID:                "evt_succeeded",
+		Type:              payment.EventPaymentSucceeded,
+		CheckoutSessionID: checkout.Checkout.ID,
+		RegistrationID:    checkout.Registration.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := payment.Sign(s.cfg.PaymentWebhookSecret, event, time.Now())
+
+	const deliveries = 8
+	var wg sync.WaitGroup
+	for i := 0; i < deliveries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(event))
+			req.Header.Set(payment.SignatureHeader, signature)
+			if w := s.serve(req); w.Code != http.StatusOK {
+				t.Errorf("webhook: got status %d, want 200; body: %s", w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := s.notifier.receipts.Load(); n != 1 {
+		t.Errorf("%d receipts sent, want 1", n)
+	}
+
+	var mine handlers.RegistrationResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/registrations/me", alice, nil), &mine)
+	if mine.Registration.PaymentStatus != models.PaymentStatusCompleted {
+		t.Errorf("payment status = %q, want %q", mine.Registration.PaymentStatus, models.PaymentStatusCompleted)
+	}
+}
+
+// TestSupersededCheckout starts a second checkout: the first one must no
+// longer be payable, and a payment that still arrives for it is flagged in
+// the audit log for a refund
+func TestSupersededCheckout(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.auth.AddUser("admin", "admin@example.com", models.RoleAdmin)
+	alice := s.auth.AddUser("alice", "alice@example.com")
+
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/ticket-types", admin,
+		gin.H{"id": "standard", "name": "Standard", "price": 5000, "currency": "EUR", "active": true})
+	s.expect(http.StatusCreated, "POST", "/api/v1/registrations", alice, gin.H{
+		"firstName":  "Alice",
+		"lastName":   "Liddell",
+		"email":      "alice@example.com",
+		"country":    "GB",
+		"ticketType": "standard",
+	})
+
+	var first, second handlers.PaymentResponse
+	decode(t, s.expect(http.StatusOK, "POST", "/api/v1/registrations/me/checkout", alice, nil), &first)
+	decode(t, s.expect(http.StatusOK, "POST", "/api/v1/registrations/me/checkout", alice, nil), &second)
+	if first.Checkout.ID == second.Checkout.ID {
+		t.Fatal("the second checkout reused the first session")
+	}
+
+	// The first session has been expired with the provider
+	s.expect(http.StatusBadRequest, "POST", "/api/v1/payments/fake/"+first.Checkout.ID, "",
+		gin.H{"type": payment.EventPaymentSucceeded})
+
+	// A payment made just before it expired is reported but not applied
+	event, err := json.Marshal(payment.Event{
+		ID:                "evt_superseded",
+		Type:              payment.EventPaymentSucceeded,
+		CheckoutSessionID: first.Checkout.ID,
+		RegistrationID:    first.Registration.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/payments/webhook", bytes.NewReader(event))
+	req.Header.Set(payment.SignatureHeader, payment.Sign(s.cfg.PaymentWebhookSecret, event, time.Now()))
+	if w := s.serve(req); w.Code != http.StatusOK {
+		t.Fatalf("webhook: got status %d, want 200; body: %s", w.Code, w.Body.String())
+	}
+
+	var mine handlers.RegistrationResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/registrations/me", alice, nil), &mine)
+	if mine.Registration.PaymentStatus != models.PaymentStatusPending || mine.Registration.PaymentRef != second.Checkout.ID {
+		t.Errorf("payment = %q with %q, want pending with %q", mine.Registration.PaymentStatus, mine.Registration.PaymentRef, second.Checkout.ID)
+	}
+
+	var entries handlers.AuditResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/admin/audit?targetType=registration&target="+first.Registration.ID, admin, nil), &entries)
+	var flagged bool
+	for _, entry := range entries.Entries {
+		flagged = flagged || entry.Action == "registration.payment_superseded"
+	}
+	if !flagged {
+		t.Errorf("audit entries = %+v, want a registration.payment_superseded entry", entries.Entries)
+	}
+
+	// The current session still takes the payment
+	var paid handlers.PaymentResponse
+	decode(t, s.expect(http.StatusOK, "POST", "/api/v1/payments/fake/"+second.Checkout.ID, "",
+		gin.H{"type": payment.EventPaymentSucceeded}), &paid)
+	if paid.Registration.PaymentStatus != models.PaymentStatusCompleted {
+		t.Errorf("payment status = %q, want %q", paid.Registration.PaymentStatus, models.PaymentStatusCompleted)
+	}
+}
+
+// TestEnrollmentCleanup checks that cancelling a registration gives its seats
+// to the waitlist and that deleting a session removes its enrollments
+func TestEnrollmentCleanup(t *testing.T) {
+	s := newTestServer(t)
+	admin := s.auth.AddUser("admin", "admin@example.com", models.RoleAdmin)
+	organizer := s.auth.AddUser("organizer", "organizer@example.com", models.RoleOrganizer)
+	alice := s.auth.AddUser("alice", "alice@example.com")
+	bob := s.auth.AddUser("bob", "bob@example.com")
+
+	s.expect(http.StatusCreated, "POST", "/api/v1/admin/ticket-types", admin,
+		gin.H{"id": "standard", "name": "Standard", "currency": "EUR", "active": true})
+	for _, attendee := range []struct{ token, name string }{{alice, "Alice"}, {bob, "Bob"}} {
+		s.expect(http.StatusCreated, "POST", "/api/v1/registrations", attendee.token, gin.H{
+			"firstName":  attendee.name,
+			"lastName":   "Tester",
+			"email":      strings.ToLower(attendee.name) + "@example.com",
+			"country":    "GB",
+			"ticketType": "standard",
+		})
+	}
+
+	start := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	var created handlers.SessionResponse
+	decode(t, s.expect(http.StatusCreated, "POST", "/api/v1/admin/sessions", organizer, gin.H{
+		"title":     "Keynote",
+		"speaker":   "Ada",
+		"startTime": start,
+		"endTime":   start.Add(time.Hour),
+		"capacity":  1,
+	}), &created)
+	sessionID := created.Session.ID
+	enrollment := "/api/v1/sessions/" + sessionID + "/enrollment"
+	s.expect(http.StatusCreated, "POST", enrollment, alice, nil)
+	s.expect(http.StatusAccepted, "POST", enrollment, bob, nil)
+
+	s.expect(http.StatusOK, "DELETE", "/api/v1/registrations/me", alice, nil)
+
+	var alices handlers.EnrollmentResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/enrollments/me", alice, nil), &alices)
+	if len(alices.Enrollments) != 0 {
+		t.Errorf("Alice's enrollments = %+v after cancelling her registration, want none", alices.Enrollments)
+	}
+	var bobs handlers.EnrollmentResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/enrollments/me", bob, nil), &bobs)
+	if len(bobs.Enrollments) != 1 || bobs.Enrollments[0].IsWaitlisted() {
+		t.Errorf("Bob's enrollments = %+v, want one promoted enrollment", bobs.Enrollments)
+	}
+	var session handlers.SessionResponse
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/sessions/"+sessionID, "", nil), &session)
+	if session.Session.Enrolled != 1 {
+		t.Errorf("session has %d enrolled, want 1", session.Session.Enrolled)
+	}
+
+	s.expect(http.StatusOK, "DELETE", "/api/v1/admin/sessions/"+sessionID, organizer, nil)
+
+	bobs = handlers.EnrollmentResponse{}
+	decode(t, s.expect(http.StatusOK, "GET", "/api/v1/enrollments/me", bob, nil), &bobs)
+	if len(bobs.Enrollments) != 0 {
+		t.Errorf("Bob's enrollments = %+v after the session was deleted, want none", bobs.Enrollments)
+	}
+}
+
+// testRoute is a request to send to a route
+type testRoute struct {
+	method string
+	path   string
+}
+
+// authenticatedRoutes returns a request for every route the OpenAPI spec
+// documents as requiring a Firebase ID token, with placeholder path
+// parameters
+func authenticatedRoutes() []testRoute {
+	param := regexp.MustCompile(`\{([^}]+)\}`)
+
+	var routes []testRoute
+	for path, item := range apidoc.Spec().Paths {
+		for method, op := range item {
+			for _, requirement := range op.Security {
+				if _, ok := requirement["firebaseAuth"]; ok {
+					routes = append(routes, testRoute{
+						method: strings.ToUpper(method),
+						path:   param.ReplaceAllString(path, "test-$1"),
+					})
+				}
+			}
+		}
+	}
+	return routes
+}
@@ -0,0 +1,56 @@
+package ticket
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	token := Sign("secret", "reg-1")
+	id, sig, _ := strings.Cut(token, ".")
+
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		want   string
+	}{
+		{"valid", "secret", token, "reg-1"},
+		{"dotted registration ID", "secret", Sign("secret", "reg.1"), "reg.1"},
+		{"wrong secret", "other", token, ""},
+		{"tampered registration ID", "secret", "reg-2." + sig, ""},
+		{"tampered signature", "secret", id + "." + strings.ToUpper(sig), ""},
+		{"truncated signature", "secret", token[:len(token)-1], ""},
+		{"signature of another ID", "secret", id + "." + signature("secret", "reg-2"), ""},
+		{"no separator", "secret", id + sig, ""},
+		{"empty registration ID", "secret", "." + sig, ""},
+		{"empty signature", "secret", id + ".", ""},
+		{"empty", "secret", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Verify(tt.secret, tt.token)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidTicket) {
+					t.Errorf("Verify() = %q, %v, want ErrInvalidTicket", got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Verify() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	png, err := QRCode(Sign("secret", "reg-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(png, []byte("\x89PNG")) {
+		t.Error("QRCode() did not return a PNG")
+	}
+}